@@ -0,0 +1,23 @@
+// Autometrics-vet reports problems in autometrics directives and generated code.
+//
+// It runs on its own, with package patterns as arguments, or through go vet:
+//
+//	autometrics-vet ./...
+//	go vet -vettool=$(which autometrics-vet) ./...
+//
+// The -fix flag applies the suggested fixes, like regenerating the outdated code, when running on its own.
+//
+// See [analyzer.Analyzer] for the list of problems reported.
+//
+// [analyzer.Analyzer]: https://godoc.org/github.com/autometrics-dev/autometrics-go/pkg/analyzer#Analyzer
+package main
+
+import (
+	"github.com/autometrics-dev/autometrics-go/pkg/analyzer"
+
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
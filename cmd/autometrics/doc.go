@@ -7,30 +7,115 @@
 //
 // By default, when activating Service Level Objectives (SLOs) `autometrics`
 // does not allow to use latency targets that are outside the default latencies
-// defined in [autometrics.DefBuckets]. If you want to use custom latencies for
-// your latency SLOs, pass the `--custom-latency` flag to the invocation.
+// defined in [autometrics.DefBuckets], nor objectives that are not covered by the
+// generated rules. If you use custom buckets in the Init call, declare them with
+// the `--buckets` flag (and the objectives with `--objectives`), or in the
+// configuration file. The `buckets` subcommand then generates a Go file declaring
+// the same buckets, to pass to the Init call:
+//
+//	//go:generate autometrics --buckets 0.01,0.05,0.1,0.5,1 buckets -o autometrics_buckets.go
+//
+// The `--custom-latency` flag, which disables the validation of latencies, is deprecated.
+//
+// The links in the documentation point to the Prometheus UI by default. To open the same
+// queries in Grafana Explore instead, pass the base URL of Grafana with `--grafana_url`, and
+// optionally the datasource UID and organization ID with `--grafana_datasource` and `--grafana_org`.
 //
 // It is meant to be used in a Go generator context. As such, it takes mandatory arguments in the form of environment variables.
 // You can also control the base URL of the prometheus instance in doc comments with an environment variable.
 //
-//	Note: If you do not use the custom latencies in the SLO, the allowed latencies (in seconds) are in [autometrics.DefBuckets].
+// To transform whole packages at once instead of a single file, pass package patterns to the
+// `--packages` flag. A single generator invocation at the root of a module is then enough to
+// keep the whole tree instrumented:
+//
+//	//go:generate autometrics --packages ./...
+//
+// To detect drift in CI (for example a directive that was added or changed without running
+// `go generate` afterwards), pass the `--check` flag: no file is written, and the command exits
+// with a non-zero status if a file is not up-to-date. `--diff` also prints the unified diff
+// between the files and what the generator would write.
+//
+// The `rules` subcommand generates the Prometheus recording rules and the multi-window,
+// multi-burn-rate alerts for the default objectives and all the objectives used in the
+// directives of the given packages (`./...` by default):
+//
+//	autometrics rules -o autometrics.rules.yml ./...
+//
+// The `dashboard` subcommand generates a Grafana dashboard with an overview panel for each
+// objective, and a row for each instrumented function of the given packages:
+//
+//	autometrics dashboard -o dashboard.json ./...
+//
+// The `manifest` subcommand writes a JSON manifest of all the functions with an autometrics
+// directive in the given packages, with their position, alerting configuration, tracking
+// flags and detected context:
+//
+//	autometrics manifest -o autometrics.json ./...
+//
+// The `strip` subcommand removes the defer statements, the generated documentation and the
+// autometrics imports that are not used anymore from the given packages. The directives are
+// kept unless `--directives` is passed. With `--check` or `--diff`, no file is written:
+//
+//	autometrics strip --directives ./...
+//
+// The settings shared by all the invocations can be set in an autometrics.yaml (or autometrics.yml,
+// or autometrics.toml) file: the first one found in the directory of the transformed file (or the
+// working directory with `--packages` and the subcommands) and its parents is used, unless another
+// file is given with `--config`. It sets the implementation, the Prometheus (or Grafana) URL, the histogram
+// buckets and objectives the directives are validated against, and whether documentation is
+// generated. The command line flags override the values of the configuration file:
+//
+//	implementation: otel
+//	prometheus_url: https://prometheus.example.com/
+//	grafana_url: https://grafana.example.com/
+//	grafana_datasource_uid: mimir
+//	grafana_org_id: 1
+//	buckets: [0.01, 0.05, 0.1, 0.25, 0.5, 1]
+//	objectives: [99, 99.9]
+//	no_doc: false
+//
+//	Note: If you do not declare custom buckets with --buckets or in the configuration file, the allowed latencies (in seconds) are in [autometrics.DefBuckets].
 //
 // Check https://github.com/autometrics-dev/autometrics-go for more help (including examples) and information.
 // Autometrics is built by Fiberplane -- https://autometrics.dev
 //
-// Usage: autometrics -f FILE_NAME -m MODULE_NAME [--prom_url PROMETHEUS_URL] [--otel] [--custom-latency]
+// Usage: autometrics [-f FILE_NAME] [-m MODULE_NAME] [--packages PATTERN] [--config CONFIG_FILE] [--prom_url PROMETHEUS_URL] [--links prometheus|grafana] [--grafana_url GRAFANA_URL] [--grafana_datasource UID] [--grafana_org ID] [--otel] [--buckets SECONDS,...] [--objectives PERCENT,...] [--custom-latency] [--no-doc] [--check] [--diff] <command> [<args>]
 //
 // Options:
 //
-//	-f FILE_NAME           File to transform. [env: GOFILE]
-//	-m MODULE_NAME         Module containing the file to transform. [env: GOPACKAGE]
+//	-f FILE_NAME           File to transform. Required unless --packages is used. [env: GOFILE]
+//	-m MODULE_NAME         Module containing the file to transform. Required unless --packages is used. [env: GOPACKAGE]
+//	--packages PATTERN, -p PATTERN
+//	                       Package patterns (like ./...) to transform instead of a single file. Test files and vendored files are skipped.
+//	--config CONFIG_FILE   Configuration file to use. By default, the first autometrics.yaml, autometrics.yml or autometrics.toml file found in the directory of the transformed file or its parents is used. [env: AM_CONFIG]
 //	--prom_url PROMETHEUS_URL
-//	                       Base URL of the Prometheus instance to generate links to. [default: http://localhost:9090, env: AM_PROMETHEUS_URL]
-//	--otel                 Use [OpenTelemetry client library] to instrument code instead of default [Prometheus client library]. [default: false]
-//	--custom-latency       Allow non-default latencies to be used in latency-based SLOs. [default: false]
+//	                       Base URL of the Prometheus instance to generate links to. Overrides the configuration file. [default: http://localhost:9090, env: AM_PROMETHEUS_URL]
+//	--links prometheus|grafana
+//	                       Kind of links to generate in the documentation. Overrides the configuration file. [default: grafana if a Grafana URL is set, prometheus otherwise, env: AM_LINKS]
+//	--grafana_url GRAFANA_URL
+//	                       Base URL of the Grafana instance to generate Explore links to. Overrides the configuration file. [env: AM_GRAFANA_URL]
+//	--grafana_datasource UID
+//	                       UID of the Prometheus datasource used in the Grafana links. Overrides the configuration file. [default: the default datasource, env: AM_GRAFANA_DATASOURCE_UID]
+//	--grafana_org ID       ID of the Grafana organization of the datasource. Overrides the configuration file. [default: the current organization, env: AM_GRAFANA_ORG_ID]
+//	--otel                 Use [OpenTelemetry client library] to instrument code instead of default [Prometheus client library]. Overrides the configuration file. [default: false]
+//	--buckets SECONDS,...  Comma-separated histogram buckets (in seconds) that the latency thresholds of the directives must match. Overrides the configuration file. [default: the default buckets of autometrics, env: AM_BUCKETS]
+//	--objectives PERCENT,...
+//	                       Comma-separated objectives (in percents) allowed in the directives. Overrides the configuration file. [default: 90,95,99,99.9, env: AM_OBJECTIVES]
+//	--custom-latency       Deprecated: declare the buckets with --buckets or in the configuration file instead. Allow any latency to be used in latency-based SLOs. [default: false]
+//	--no-doc               Disable documentation links generation for all instrumented functions. Overrides the configuration file. [default: false, env: AM_NO_DOCGEN]
+//	--check                Do not write any file, and exit with a non-zero status if a file is not up-to-date with its autometrics directives. [default: false]
+//	--diff                 Same as --check, and also print the unified diff between the files and what the generator would write. [default: false]
 //	--help, -h             display this help and exit
 //	--version              display version and exit
 //
+// Commands:
+//
+//	rules                  Generate the Prometheus recording rules and alerts for the objectives used in the autometrics directives.
+//	dashboard              Generate a Grafana dashboard for the instrumented functions and the objectives used in the autometrics directives.
+//	manifest               Generate a JSON manifest of all the functions with an autometrics directive.
+//	buckets                Generate a Go file declaring the histogram buckets and objectives, to pass to the Init call of autometrics.
+//	strip                  Remove the code and documentation generated by autometrics. Honors --check and --diff.
+//
 // [Prometheus client library]: https://github.com/prometheus/client_golang
 // [OpenTelemetry metrics]: https://opentelemetry.io/docs/instrumentation/go/
 // [autometrics.DefBuckets]: https://godoc.org/github.com/autometrics-dev/autometrics-go/pkg/autometrics#DefBuckets
@@ -3,6 +3,9 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
@@ -14,16 +17,80 @@ import (
 )
 
 const (
-	DefaultPrometheusInstanceUrl = "http://localhost:9090/"
+	DefaultPrometheusInstanceUrl = internal.DefaultPrometheusUrl
 )
 
 type args struct {
-	FileName             string `arg:"-f,--,required,env:GOFILE" placeholder:"FILE_NAME" help:"File to transform."`
-	ModuleName           string `arg:"-m,--,required,env:GOPACKAGE" placeholder:"MODULE_NAME" help:"Module containing the file to transform."`
-	PrometheusUrl        string `arg:"--prom_url,env:AM_PROMETHEUS_URL" placeholder:"PROMETHEUS_URL" default:"http://localhost:9090" help:"Base URL of the Prometheus instance to generate links to."`
-	UseOtel              bool   `arg:"--otel" default:"false" help:"Use OpenTelemetry client library to instrument code instead of default Prometheus."`
-	AllowCustomLatencies bool   `arg:"--custom-latency" default:"false" help:"Allow non-default latencies to be used in latency-based SLOs."`
-	DisableDocGeneration bool   `arg:"--no-doc,env:AM_NO_DOCGEN" default:"false" help:"Disable documentation links generation for all instrumented functions. Has the same effect as --no-doc in the //autometrics:inst directive."`
+	FileName             string        `arg:"-f,--,env:GOFILE" placeholder:"FILE_NAME" help:"File to transform. Required unless --packages is used."`
+	ModuleName           string        `arg:"-m,--,env:GOPACKAGE" placeholder:"MODULE_NAME" help:"Module containing the file to transform. Required unless --packages is used."`
+	Packages             []string      `arg:"-p,--packages" placeholder:"PATTERN" help:"Package patterns (like ./...) to transform instead of a single file. Test files and vendored files are skipped."`
+	Config               string        `arg:"--config,env:AM_CONFIG" placeholder:"CONFIG_FILE" help:"Configuration file to use. By default, the first autometrics.yaml, autometrics.yml or autometrics.toml file found in the directory of the transformed file or its parents is used."`
+	PrometheusUrl        *string       `arg:"--prom_url,env:AM_PROMETHEUS_URL" placeholder:"PROMETHEUS_URL" help:"Base URL of the Prometheus instance to generate links to. Overrides the configuration file. [default: http://localhost:9090]"`
+	Links                *string       `arg:"--links,env:AM_LINKS" placeholder:"prometheus|grafana" help:"Kind of links to generate in the documentation. Overrides the configuration file. [default: grafana if a Grafana URL is set, prometheus otherwise]"`
+	GrafanaUrl           *string       `arg:"--grafana_url,env:AM_GRAFANA_URL" placeholder:"GRAFANA_URL" help:"Base URL of the Grafana instance to generate Explore links to. Overrides the configuration file."`
+	GrafanaDatasource    *string       `arg:"--grafana_datasource,env:AM_GRAFANA_DATASOURCE_UID" placeholder:"UID" help:"UID of the Prometheus datasource used in the Grafana links. Overrides the configuration file. [default: the default datasource]"`
+	GrafanaOrg           *int          `arg:"--grafana_org,env:AM_GRAFANA_ORG_ID" placeholder:"ID" help:"ID of the Grafana organization of the datasource. Overrides the configuration file. [default: the current organization]"`
+	UseOtel              *bool         `arg:"--otel" help:"Use OpenTelemetry client library to instrument code instead of default Prometheus. Overrides the configuration file. [default: false]"`
+	Buckets              *floatList    `arg:"--buckets,env:AM_BUCKETS" placeholder:"SECONDS,..." help:"Comma-separated histogram buckets (in seconds) that the latency thresholds of the directives must match. Overrides the configuration file. [default: the default buckets of autometrics]"`
+	Objectives           *floatList    `arg:"--objectives,env:AM_OBJECTIVES" placeholder:"PERCENT,..." help:"Comma-separated objectives (in percents) allowed in the directives. Overrides the configuration file. [default: 90,95,99,99.9]"`
+	AllowCustomLatencies bool          `arg:"--custom-latency" default:"false" help:"Deprecated: declare the buckets with --buckets or in the configuration file instead. Allow any latency to be used in latency-based SLOs."`
+	DisableDocGeneration *bool         `arg:"--no-doc,env:AM_NO_DOCGEN" help:"Disable documentation links generation for all instrumented functions. Has the same effect as --no-doc in the //autometrics:inst directive. Overrides the configuration file. [default: false]"`
+	ErrorTypes           *bool         `arg:"--error-types,env:AM_ERROR_TYPES" help:"Add the breakdown of the error ratio by error type to the documentation links, for code that enables the error type label in Init. Overrides the configuration file. [default: false]"`
+	Check                bool          `arg:"--check" default:"false" help:"Do not write any file, and exit with a non-zero status if a file is not up-to-date with its autometrics directives."`
+	Diff                 bool          `arg:"--diff" default:"false" help:"Same as --check, and also print the unified diff between the files and what the generator would write."`
+	Rules                *rulesCmd     `arg:"subcommand:rules" help:"Generate the Prometheus recording rules and alerts for the objectives used in the autometrics directives."`
+	Dashboard            *dashboardCmd `arg:"subcommand:dashboard" help:"Generate a Grafana dashboard for the instrumented functions and the objectives used in the autometrics directives."`
+	Manifest             *manifestCmd  `arg:"subcommand:manifest" help:"Generate a JSON manifest of all the functions with an autometrics directive."`
+	BucketsFile          *bucketsCmd   `arg:"subcommand:buckets" help:"Generate a Go file declaring the histogram buckets and objectives, to pass to the Init call of autometrics."`
+	Strip                *stripCmd     `arg:"subcommand:strip" help:"Remove the code and documentation generated by autometrics. Honors --check and --diff."`
+}
+
+type rulesCmd struct {
+	Packages []string `arg:"positional" placeholder:"PATTERN" help:"Package patterns to look for autometrics directives in. [default: ./...]"`
+	Output   string   `arg:"-o,--output" placeholder:"FILE" help:"File to write the rules to. The rules are written on the standard output by default."`
+}
+
+type dashboardCmd struct {
+	Packages []string `arg:"positional" placeholder:"PATTERN" help:"Package patterns to look for autometrics directives in. [default: ./...]"`
+	Output   string   `arg:"-o,--output" placeholder:"FILE" help:"File to write the dashboard to. The dashboard is written on the standard output by default."`
+	Title    string   `arg:"--title" placeholder:"TITLE" default:"Autometrics" help:"Title of the dashboard."`
+	UID      string   `arg:"--uid" placeholder:"UID" default:"autometrics-functions" help:"Unique identifier of the dashboard in Grafana. Keep it stable to update provisioned dashboards in place."`
+}
+
+type manifestCmd struct {
+	Packages []string `arg:"positional" placeholder:"PATTERN" help:"Package patterns to look for autometrics directives in. [default: ./...]"`
+	Output   string   `arg:"-o,--output" placeholder:"FILE" help:"File to write the manifest to. The manifest is written on the standard output by default."`
+}
+
+type bucketsCmd struct {
+	Output  string `arg:"-o,--output" placeholder:"FILE" help:"File to write the Go code to. The code is written on the standard output by default."`
+	Package string `arg:"--package,env:GOPACKAGE" placeholder:"PACKAGE" default:"main" help:"Name of the package of the generated file."`
+}
+
+type stripCmd struct {
+	Packages   []string `arg:"positional" placeholder:"PATTERN" help:"Package patterns to strip. [default: ./...]"`
+	Directives bool     `arg:"--directives" default:"false" help:"Also remove the autometrics directives, to stop instrumenting the functions altogether."`
+}
+
+// floatList is a comma-separated list of numbers on the command line.
+type floatList []float64
+
+func (l *floatList) UnmarshalText(text []byte) error {
+	*l = nil
+	for _, field := range strings.Split(string(text), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q: %w", field, err)
+		}
+		*l = append(*l, value)
+	}
+
+	return nil
 }
 
 func (args) Version() string {
@@ -44,7 +111,11 @@ func (args) Description() string {
 		"It is meant to be used in a Go generator context. As such, it takes mandatory arguments in the form of environment variables.\n"+
 			"You can also control the base URL of the prometheus instance in doc comments with an environment variable.\n")
 	fmt.Fprintf(&buf,
-		"\tNote: If you do not use the custom latencies in the SLO, the allowed latencies (in seconds) are %v\n\n",
+		"To transform whole packages at once (for example with a single generator invocation at the root of a module), pass package patterns to --packages.\n")
+	fmt.Fprintf(&buf,
+		"The settings shared by all the invocations can be set in an autometrics.yaml or autometrics.toml file at the root of the project, the flags override them.\n")
+	fmt.Fprintf(&buf,
+		"\tNote: If you do not declare custom buckets with --buckets or in the configuration file, the allowed latencies (in seconds) are %v\n\n",
 		autometrics.DefBuckets)
 
 	fmt.Fprintln(&buf,
@@ -57,24 +128,371 @@ func (args) Description() string {
 
 func main() {
 	var args args
-	arg.MustParse(&args)
+	p := arg.MustParse(&args)
 
-	implementation := autometrics.PROMETHEUS
-	if args.UseOtel {
-		implementation = autometrics.OTEL
+	if p.Subcommand() == nil && len(args.Packages) == 0 && (args.FileName == "" || args.ModuleName == "") {
+		p.Fail("FILE_NAME and MODULE_NAME are required (or environment variables GOFILE and GOPACKAGE) when --packages is not used")
 	}
 
-	ctx, err := internal.NewGeneratorContext(
-		implementation,
-		args.PrometheusUrl,
-		args.AllowCustomLatencies,
-		args.DisableDocGeneration,
-	)
+	if args.AllowCustomLatencies {
+		log.Printf("Warning: -%s is deprecated, declare the buckets used in the Init call with --buckets or in the %s configuration file instead", autometrics.AllowCustomLatenciesFlag, internal.ConfigFileNames[0])
+	}
+
+	cfg := loadConfig(args, p.Subcommand() == nil && len(args.Packages) == 0)
+
+	ctx, err := internal.NewGeneratorContextFromConfig(cfg, args.AllowCustomLatencies)
 	if err != nil {
 		log.Fatalf("error initialising autometrics context: %s", err)
 	}
 
+	if args.Rules != nil {
+		generateRules(ctx, *args.Rules)
+		return
+	}
+
+	if args.Dashboard != nil {
+		generateDashboard(ctx, *args.Dashboard)
+		return
+	}
+
+	if args.Manifest != nil {
+		generateManifest(ctx, *args.Manifest)
+		return
+	}
+
+	if args.BucketsFile != nil {
+		generateBucketsFile(ctx, *args.BucketsFile)
+		return
+	}
+
+	checkOnly := args.Check || args.Diff
+
+	if args.Strip != nil {
+		stripPackages(*args.Strip, checkOnly, args.Diff)
+		return
+	}
+
+	if len(args.Packages) > 0 {
+		transformPackages(ctx, args.Packages, checkOnly, args.Diff)
+		return
+	}
+
+	if checkOnly {
+		report, err := generate.CheckFile(ctx, args.FileName, args.ModuleName)
+		if err != nil {
+			log.Fatalf("error checking %s: %s", args.FileName, err)
+		}
+		checkReports([]generate.FileReport{report}, args.Diff, "not up-to-date with their directives, run 'go generate' to update them")
+		return
+	}
+
 	if err := generate.TransformFile(ctx, args.FileName, args.ModuleName); err != nil {
 		log.Fatalf("error transforming %s: %s", args.FileName, err)
 	}
 }
+
+// loadConfig reads the project configuration file, and overrides its values with the flags that are set.
+//
+// In single file mode, the configuration file is looked for from the directory of the file, otherwise
+// it is looked for from the working directory.
+func loadConfig(args args, singleFile bool) internal.Config {
+	var cfg internal.Config
+
+	path := args.Config
+	if path == "" {
+		dir := "."
+		if singleFile {
+			dir = filepath.Dir(args.FileName)
+		}
+
+		var err error
+		path, err = internal.FindConfig(dir)
+		if err != nil {
+			log.Fatalf("error looking for a configuration file: %s", err)
+		}
+	}
+
+	if path != "" {
+		var err error
+		cfg, err = internal.LoadConfig(path)
+		if err != nil {
+			log.Fatalf("error loading the configuration: %s", err)
+		}
+	}
+
+	if args.PrometheusUrl != nil {
+		cfg.PrometheusUrl = *args.PrometheusUrl
+	}
+	if cfg.PrometheusUrl == "" {
+		cfg.PrometheusUrl = DefaultPrometheusInstanceUrl
+	}
+
+	if args.Links != nil {
+		cfg.Links = *args.Links
+	}
+	if args.GrafanaUrl != nil {
+		cfg.GrafanaUrl = *args.GrafanaUrl
+	}
+	if args.GrafanaDatasource != nil {
+		cfg.GrafanaDatasourceUID = *args.GrafanaDatasource
+	}
+	if args.GrafanaOrg != nil {
+		cfg.GrafanaOrgID = *args.GrafanaOrg
+	}
+
+	if args.UseOtel != nil {
+		cfg.Implementation = "prometheus"
+		if *args.UseOtel {
+			cfg.Implementation = "otel"
+		}
+	}
+
+	if args.DisableDocGeneration != nil {
+		cfg.DisableDocGeneration = *args.DisableDocGeneration
+	}
+	if args.ErrorTypes != nil {
+		cfg.ErrorTypes = *args.ErrorTypes
+	}
+
+	if args.Buckets != nil {
+		cfg.Buckets = *args.Buckets
+	}
+	if args.Objectives != nil {
+		cfg.Objectives = *args.Objectives
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid configuration: %s", err)
+	}
+
+	return cfg
+}
+
+func transformPackages(ctx internal.GeneratorContext, patterns []string, checkOnly, showDiff bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("error getting a working directory: %s", err)
+	}
+
+	if checkOnly {
+		reports, err := generate.CheckPackages(ctx, cwd, patterns)
+		if err != nil {
+			log.Fatalf("error checking packages %v: %s", patterns, err)
+		}
+		if !showDiff {
+			printReports(cwd, reports, "instrumented", true)
+		}
+		checkReports(reports, showDiff, "not up-to-date with their directives, run 'go generate' to update them")
+		return
+	}
+
+	reports, err := generate.TransformPackages(ctx, cwd, patterns)
+	printReports(cwd, reports, "instrumented", false)
+	if err != nil {
+		log.Fatalf("error transforming packages %v: %s", patterns, err)
+	}
+}
+
+// stripPackages removes the generated code and documentation from the packages, so that the
+// code compiles without autometrics.
+func stripPackages(cmd stripCmd, checkOnly, showDiff bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("error getting a working directory: %s", err)
+	}
+
+	patterns := cmd.Packages
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	if checkOnly {
+		reports, err := generate.CheckStripPackages(cwd, patterns, cmd.Directives)
+		if err != nil {
+			log.Fatalf("error checking packages %v: %s", patterns, err)
+		}
+		if !showDiff {
+			printReports(cwd, reports, "stripped", true)
+		}
+		checkReports(reports, showDiff, "still contain autometrics code, run 'autometrics strip' to remove it")
+		return
+	}
+
+	reports, err := generate.StripPackages(cwd, patterns, cmd.Directives)
+	printReports(cwd, reports, "stripped", false)
+	if err != nil {
+		log.Fatalf("error stripping packages %v: %s", patterns, err)
+	}
+}
+
+// generateRules writes the rules covering the default objectives, and all the objectives found in the directives.
+func generateRules(ctx internal.GeneratorContext, cmd rulesCmd) {
+	functions := inspectPackages(ctx, cmd.Packages)
+
+	confs := make([]*autometrics.AlertConfiguration, 0, len(functions))
+	for _, function := range functions {
+		confs = append(confs, function.RuntimeCtx.AlertConf)
+	}
+
+	spec := internal.NewRulesSpec(ctx.Objectives, confs)
+	spec.Implementation = ctx.Implementation
+
+	rules, err := internal.GenerateRules(spec)
+	if err != nil {
+		log.Fatalf("error generating the rules: %s", err)
+	}
+
+	writeOutput(cmd.Output, rules)
+}
+
+// generateDashboard writes a dashboard with a row for each instrumented function, and an overview
+// of each objective found in the directives.
+func generateDashboard(ctx internal.GeneratorContext, cmd dashboardCmd) {
+	functions := inspectPackages(ctx, cmd.Packages)
+
+	spec := internal.DashboardSpec{
+		Title:          cmd.Title,
+		UID:            cmd.UID,
+		Implementation: ctx.Implementation,
+	}
+	for _, function := range functions {
+		spec.Functions = append(spec.Functions, internal.DashboardFunction{
+			FunctionName: function.FunctionName,
+			ModuleName:   function.QualifiedModuleName,
+		})
+		if function.RuntimeCtx.AlertConf != nil {
+			spec.ObjectiveNames = append(spec.ObjectiveNames, function.RuntimeCtx.AlertConf.ServiceName)
+		}
+	}
+
+	dashboard, err := internal.GenerateDashboard(spec)
+	if err != nil {
+		log.Fatalf("error generating the dashboard: %s", err)
+	}
+
+	writeOutput(cmd.Output, dashboard)
+}
+
+// generateManifest writes the manifest of all the functions with a directive. The paths in the
+// manifest are relative to the directory of the output file.
+func generateManifest(ctx internal.GeneratorContext, cmd manifestCmd) {
+	functions := inspectPackages(ctx, cmd.Packages)
+
+	dir, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("error getting a working directory: %s", err)
+	}
+	if cmd.Output != "" {
+		dir, err = filepath.Abs(filepath.Dir(cmd.Output))
+		if err != nil {
+			log.Fatalf("error resolving the directory of %s: %s", cmd.Output, err)
+		}
+	}
+
+	manifest, err := generate.NewManifest(dir, functions).JSON()
+	if err != nil {
+		log.Fatalf("error generating the manifest: %s", err)
+	}
+
+	writeOutput(cmd.Output, manifest)
+}
+
+// generateBucketsFile writes a Go file declaring the buckets and objectives the directives are validated
+// against, so that the same buckets are used in the Init call.
+func generateBucketsFile(ctx internal.GeneratorContext, cmd bucketsCmd) {
+	source, err := internal.GenerateBucketsFile(cmd.Package, ctx.Buckets, ctx.Objectives)
+	if err != nil {
+		log.Fatalf("error generating the buckets file: %s", err)
+	}
+
+	writeOutput(cmd.Output, source)
+}
+
+// inspectPackages lists the functions with an autometrics directive in the packages matching the
+// patterns, or in all the packages under the working directory if there are no patterns.
+func inspectPackages(ctx internal.GeneratorContext, patterns []string) []generate.FunctionInfo {
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("error getting a working directory: %s", err)
+	}
+
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	functions, err := generate.InspectPackages(ctx, cwd, patterns)
+	if err != nil {
+		log.Fatalf("error inspecting packages %v: %s", patterns, err)
+	}
+
+	return functions
+}
+
+// writeOutput writes the content to the file at path, or to the standard output if path is empty.
+func writeOutput(path string, content []byte) {
+	if path == "" {
+		os.Stdout.Write(content)
+		return
+	}
+
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		log.Fatalf("error writing %s: %s", path, err)
+	}
+}
+
+// checkReports prints the diffs if asked to, and exits with a non-zero status if any file is out of date.
+// The reason completes the message listing the files that are out of date.
+func checkReports(reports []generate.FileReport, showDiff bool, reason string) {
+	var outdated []string
+
+	for _, report := range reports {
+		if !report.Modified {
+			continue
+		}
+		outdated = append(outdated, report.Path)
+
+		if showDiff {
+			fmt.Print(report.Diff)
+		}
+	}
+
+	if len(outdated) > 0 {
+		log.Printf("autometrics: %d file(s) are %s:", len(outdated), reason)
+		for _, path := range outdated {
+			log.Printf("\t%s", path)
+		}
+		os.Exit(1)
+	}
+}
+
+// printReports prints a summary line for each file, and the totals. The action tells what was done
+// to the functions counted in the reports.
+func printReports(cwd string, reports []generate.FileReport, action string, checkOnly bool) {
+	var functions, modified int
+
+	for _, report := range reports {
+		path := report.Path
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			path = rel
+		}
+
+		status := "unchanged"
+		if report.Modified {
+			status = "updated"
+			if checkOnly {
+				status = "out of date"
+			}
+			modified++
+		}
+		functions += report.InstrumentedFunctions
+
+		fmt.Printf("%s: %d %s function(s), %s\n", path, report.InstrumentedFunctions, action, status)
+	}
+
+	verb := "updated"
+	if checkOnly {
+		verb = "out of date"
+	}
+
+	fmt.Printf("autometrics: %d file(s) processed, %d %s, %d %s function(s)\n", len(reports), modified, verb, functions, action)
+}
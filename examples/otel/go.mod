@@ -1,6 +1,6 @@
 module autometrics-dev/example/otel
 
-go 1.20
+go 1.22.0
 
 require (
 	github.com/autometrics-dev/autometrics-go v0.0.0-20230222105517-4997cc8aa1e4
@@ -0,0 +1,60 @@
+package autometrics // import "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"strconv"
+)
+
+const (
+	// BucketsVariableName is the name of the variable holding the histogram buckets in the generated buckets file.
+	BucketsVariableName = "AutometricsBuckets"
+	// ObjectivesVariableName is the name of the variable holding the objectives in the generated buckets file.
+	ObjectivesVariableName = "AutometricsObjectives"
+)
+
+// GenerateBucketsFile generates the source of a Go file of package packageName, that declares the
+// histogram buckets and the objectives the directives are validated against.
+//
+// The buckets variable is meant to be given as the histogramBuckets argument of Init, so that the
+// generated code, the generated rules and the metrics collected at runtime all use the same buckets.
+func GenerateBucketsFile(packageName string, buckets, objectives []float64) ([]byte, error) {
+	if !token.IsIdentifier(packageName) {
+		return nil, fmt.Errorf("invalid package name %q", packageName)
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "// Code generated by autometrics; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", packageName)
+	fmt.Fprintf(&buf, "// %s are the histogram buckets (in seconds) the latency thresholds of the autometrics\n", BucketsVariableName)
+	fmt.Fprintf(&buf, "// directives are validated against. Pass them to the Init call of autometrics.\n")
+	fmt.Fprintf(&buf, "var %s = %s\n\n", BucketsVariableName, floatSliceLiteral(buckets))
+	fmt.Fprintf(&buf, "// %s are the objectives (in percents) allowed in the autometrics directives,\n", ObjectivesVariableName)
+	fmt.Fprintf(&buf, "// and covered by the generated rules.\n")
+	fmt.Fprintf(&buf, "var %s = %s\n", ObjectivesVariableName, floatSliceLiteral(objectives))
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting the buckets file: %w", err)
+	}
+
+	return source, nil
+}
+
+func floatSliceLiteral(values []float64) string {
+	var buf bytes.Buffer
+
+	buf.WriteString("[]float64{")
+	for i, value := range values {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	}
+	buf.WriteString("}")
+
+	return buf.String()
+}
@@ -0,0 +1,32 @@
+package autometrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateBucketsFile(t *testing.T) {
+	source, err := GenerateBucketsFile("main", []float64{0.005, 0.1, 1, 2.5}, []float64{99, 99.9})
+	if err != nil {
+		t.Fatalf("error generating the buckets file: %s", err)
+	}
+
+	want := `// Code generated by autometrics; DO NOT EDIT.
+
+package main
+
+// AutometricsBuckets are the histogram buckets (in seconds) the latency thresholds of the autometrics
+// directives are validated against. Pass them to the Init call of autometrics.
+var AutometricsBuckets = []float64{0.005, 0.1, 1, 2.5}
+
+// AutometricsObjectives are the objectives (in percents) allowed in the autometrics directives,
+// and covered by the generated rules.
+var AutometricsObjectives = []float64{99, 99.9}
+`
+
+	assert.Equal(t, want, string(source), "The generated buckets file is not as expected.")
+
+	_, err = GenerateBucketsFile("not a package", nil, nil)
+	assert.Error(t, err, "Invalid package names must be rejected.")
+}
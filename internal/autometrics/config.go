@@ -0,0 +1,249 @@
+package autometrics // import "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileNames are the names of the project configuration files, in order of precedence
+// when a directory contains more than one of them.
+var ConfigFileNames = []string{"autometrics.yaml", "autometrics.yml", "autometrics.toml"}
+
+// DefaultPrometheusUrl is the base URL of the Prometheus instance links point to when the
+// configuration and the flags do not set one.
+const DefaultPrometheusUrl = "http://localhost:9090/"
+
+// Config is the project-level configuration of the generator.
+//
+// It is read from one of the [ConfigFileNames] files, so that the settings do not need to be
+// repeated in every `//go:generate` invocation. The command line flags override its values.
+type Config struct {
+	// Implementation is the metrics library used in the instrumented code, "prometheus" (the default) or "otel".
+	Implementation string `yaml:"implementation" toml:"implementation"`
+	// PrometheusUrl is the base URL of the Prometheus instance to generate links to.
+	PrometheusUrl string `yaml:"prometheus_url" toml:"prometheus_url"`
+	// Links is the kind of links generated in the documentation, "prometheus" or "grafana".
+	//
+	// It defaults to "grafana" if GrafanaUrl is set, and "prometheus" otherwise.
+	Links string `yaml:"links" toml:"links"`
+	// GrafanaUrl is the base URL of the Grafana instance to generate Explore links to.
+	GrafanaUrl string `yaml:"grafana_url" toml:"grafana_url"`
+	// GrafanaDatasourceUID is the UID of the Prometheus datasource used in the Grafana links.
+	//
+	// The default datasource of the organization is used if it is empty.
+	GrafanaDatasourceUID string `yaml:"grafana_datasource_uid" toml:"grafana_datasource_uid"`
+	// GrafanaOrgID is the ID of the Grafana organization of the datasource.
+	//
+	// The current organization of the user is used if it is 0.
+	GrafanaOrgID int `yaml:"grafana_org_id" toml:"grafana_org_id"`
+	// Buckets are the histogram buckets (in seconds) of the instrumented code, the latency
+	// thresholds of the directives must match one of them.
+	//
+	// It defaults to [autometrics.DefBuckets].
+	Buckets []float64 `yaml:"buckets" toml:"buckets"`
+	// Objectives are the percentages allowed as success rate and latency objectives in the directives.
+	//
+	// It defaults to [autometrics.DefObjectives].
+	Objectives []float64 `yaml:"objectives" toml:"objectives"`
+	// DisableDocGeneration disables the documentation links generation for all instrumented functions.
+	DisableDocGeneration bool `yaml:"no_doc" toml:"no_doc"`
+	// ErrorTypes adds the breakdown of the error ratio by error type to the documentation links. It is
+	// meant for the code that enables the error type label with the WithErrorTypes option of Init.
+	ErrorTypes bool `yaml:"error_types" toml:"error_types"`
+
+	// Path is the path of the file the configuration has been read from, it is empty
+	// if there is no configuration file.
+	Path string `yaml:"-" toml:"-"`
+}
+
+// FindConfig looks for a configuration file in dir and all its parent directories, and returns
+// the path of the closest one.
+//
+// It returns an empty path (and no error) if there is no configuration file.
+func FindConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("error resolving %s: %w", dir, err)
+	}
+
+	for {
+		for _, name := range ConfigFileNames {
+			path := filepath.Join(dir, name)
+			info, err := os.Stat(path)
+			if err == nil && !info.IsDir() {
+				return path, nil
+			}
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return "", fmt.Errorf("error looking for a configuration file: %w", err)
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig reads and validates the configuration file at path.
+//
+// The format of the file is chosen from its extension. Unknown keys are rejected to catch typos.
+func LoadConfig(path string) (Config, error) {
+	cfg := Config{Path: path}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("error reading the configuration file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return cfg, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	case ".toml":
+		metadata, err := toml.Decode(string(content), &cfg)
+		if err != nil {
+			return cfg, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return cfg, fmt.Errorf("error parsing %s: unknown key %q", path, undecoded[0].String())
+		}
+	default:
+		return cfg, fmt.Errorf("unsupported configuration file format for %s: expecting YAML or TOML", path)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid configuration in %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks that the values in the configuration can be used by the generator.
+func (c Config) Validate() error {
+	if _, err := ParseImplementation(c.Implementation); err != nil {
+		return err
+	}
+
+	links, err := c.links()
+	if err != nil {
+		return err
+	}
+	if links == GrafanaLinks && c.GrafanaUrl == "" {
+		return errors.New("grafana links need the URL of the Grafana instance")
+	}
+	if c.GrafanaOrgID < 0 {
+		return fmt.Errorf("the Grafana organization ID must be positive, got %v", c.GrafanaOrgID)
+	}
+
+	if !sort.Float64sAreSorted(c.Buckets) {
+		return fmt.Errorf("buckets must be sorted in increasing order, got %v", c.Buckets)
+	}
+	for i, bucket := range c.Buckets {
+		if bucket <= 0 {
+			return fmt.Errorf("buckets must be positive durations in seconds, got %v", bucket)
+		}
+		if i > 0 && bucket == c.Buckets[i-1] {
+			return fmt.Errorf("buckets must be unique, got %v twice", bucket)
+		}
+	}
+
+	for _, objective := range c.Objectives {
+		if objective <= 0 || objective > 100 {
+			return fmt.Errorf("objectives must be percentages between 0 (excluded) and 100, got %v", objective)
+		}
+	}
+
+	return nil
+}
+
+const (
+	// PrometheusLinks is the value of [Config.Links] to generate links to the Prometheus UI.
+	PrometheusLinks = "prometheus"
+	// GrafanaLinks is the value of [Config.Links] to generate links to Grafana Explore.
+	GrafanaLinks = "grafana"
+)
+
+// links returns the kind of links to generate.
+func (c Config) links() (string, error) {
+	switch strings.ToLower(c.Links) {
+	case "":
+		if c.GrafanaUrl != "" {
+			return GrafanaLinks, nil
+		}
+		return PrometheusLinks, nil
+	case PrometheusLinks:
+		return PrometheusLinks, nil
+	case GrafanaLinks:
+		return GrafanaLinks, nil
+	default:
+		return "", fmt.Errorf("unknown links %q: expecting %q or %q", c.Links, PrometheusLinks, GrafanaLinks)
+	}
+}
+
+// ParseImplementation returns the implementation matching the name used in the configuration file.
+//
+// An empty name is the default implementation, Prometheus.
+func ParseImplementation(name string) (autometrics.Implementation, error) {
+	switch strings.ToLower(name) {
+	case "", "prometheus":
+		return autometrics.PROMETHEUS, nil
+	case "otel", "opentelemetry":
+		return autometrics.OTEL, nil
+	default:
+		return autometrics.PROMETHEUS, fmt.Errorf("unknown implementation %q: expecting \"prometheus\" or \"otel\"", name)
+	}
+}
+
+// NewGeneratorContextFromConfig builds a generator context with the settings of the configuration.
+func NewGeneratorContextFromConfig(cfg Config, allowCustomLatencies bool) (GeneratorContext, error) {
+	implementation, err := ParseImplementation(cfg.Implementation)
+	if err != nil {
+		return GeneratorContext{}, err
+	}
+
+	ctx, err := NewGeneratorContext(implementation, cfg.PrometheusUrl, allowCustomLatencies, cfg.DisableDocGeneration)
+	if err != nil {
+		return ctx, err
+	}
+
+	links, err := cfg.links()
+	if err != nil {
+		return ctx, err
+	}
+	if links == GrafanaLinks {
+		grafanaUrl, err := url.Parse(cfg.GrafanaUrl)
+		if err != nil {
+			return ctx, fmt.Errorf("failed to parse grafana URL: %w", err)
+		}
+
+		ctx.DocumentationGenerator = NewGrafanaDoc(*grafanaUrl, cfg.GrafanaDatasourceUID, cfg.GrafanaOrgID)
+	}
+
+	ctx.ErrorTypes = cfg.ErrorTypes
+
+	if len(cfg.Buckets) > 0 {
+		ctx.Buckets = cfg.Buckets
+	}
+	if len(cfg.Objectives) > 0 {
+		ctx.Objectives = cfg.Objectives
+	}
+
+	return ctx, nil
+}
@@ -0,0 +1,147 @@
+package autometrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("error creating %s: %s", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("error writing %s: %s", path, err)
+	}
+}
+
+// TestFindConfig tests that the closest configuration file is found when walking up the directories.
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "service", "api", "handlers")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("error creating directories: %s", err)
+	}
+
+	path, err := FindConfig(nested)
+	assert.NoError(t, err)
+	assert.Empty(t, path, "No configuration file must be found.")
+
+	writeFile(t, filepath.Join(root, "autometrics.toml"), "")
+	path, err = FindConfig(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "autometrics.toml"), path)
+
+	writeFile(t, filepath.Join(root, "service", "autometrics.yaml"), "")
+	path, err = FindConfig(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "service", "autometrics.yaml"), path, "The closest configuration file must win.")
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := filepath.Join(dir, "autometrics.yaml")
+	writeFile(t, yamlPath, `implementation: otel
+prometheus_url: https://prometheus.example.com
+buckets: [0.1, 0.5, 1]
+objectives: [99, 99.5]
+no_doc: true
+`)
+
+	tomlPath := filepath.Join(dir, "autometrics.toml")
+	writeFile(t, tomlPath, `implementation = "otel"
+prometheus_url = "https://prometheus.example.com"
+buckets = [0.1, 0.5, 1]
+objectives = [99, 99.5]
+no_doc = true
+`)
+
+	for _, path := range []string{yamlPath, tomlPath} {
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("error loading %s: %s", path, err)
+		}
+
+		assert.Equal(t, Config{
+			Implementation:       "otel",
+			PrometheusUrl:        "https://prometheus.example.com",
+			Buckets:              []float64{0.1, 0.5, 1},
+			Objectives:           []float64{99, 99.5},
+			DisableDocGeneration: true,
+			Path:                 path,
+		}, cfg, "The configuration in %s is not as expected.", path)
+
+		ctx, err := NewGeneratorContextFromConfig(cfg, false)
+		if err != nil {
+			t.Fatalf("error creating the generation context: %s", err)
+		}
+		assert.Equal(t, autometrics.OTEL, ctx.Implementation)
+		assert.True(t, ctx.DisableDocGeneration)
+		assert.Equal(t, []float64{0.1, 0.5, 1}, ctx.Buckets)
+		assert.Equal(t, []float64{99, 99.5}, ctx.Objectives)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	invalid := map[string]string{
+		"unknown.yaml":        "prom_url: http://localhost:9090\n",
+		"unknown.toml":        "prom_url = \"http://localhost:9090\"\n",
+		"implementation.yaml": "implementation: statsd\n",
+		"unsorted.yaml":       "buckets: [1, 0.5]\n",
+		"objective.toml":      "objectives = [120]\n",
+		"format.json":         "{}\n",
+		"grafana.yaml":        "links: grafana\n",
+		"links.toml":          "links = \"jaeger\"\n",
+	}
+
+	for name, content := range invalid {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, content)
+
+		_, err := LoadConfig(path)
+		assert.Error(t, err, "Loading %s must fail.", name)
+	}
+}
+
+func TestGrafanaConfig(t *testing.T) {
+	ctx, err := NewGeneratorContextFromConfig(Config{
+		PrometheusUrl:        "http://localhost:9090/",
+		GrafanaUrl:           "https://grafana.example.com/",
+		GrafanaDatasourceUID: "prom",
+		GrafanaOrgID:         2,
+	}, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+	assert.IsType(t, Grafana{}, ctx.DocumentationGenerator, "Setting a Grafana URL must generate Grafana links.")
+
+	ctx, err = NewGeneratorContextFromConfig(Config{
+		PrometheusUrl: "http://localhost:9090/",
+		Links:         PrometheusLinks,
+		GrafanaUrl:    "https://grafana.example.com/",
+	}, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+	assert.IsType(t, Prometheus{}, ctx.DocumentationGenerator, "The links setting must select the generator.")
+}
+
+func TestDefaultConfig(t *testing.T) {
+	ctx, err := NewGeneratorContextFromConfig(Config{}, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	assert.Equal(t, autometrics.PROMETHEUS, ctx.Implementation)
+	assert.Equal(t, autometrics.DefBuckets, ctx.Buckets)
+	assert.Equal(t, autometrics.DefObjectives, ctx.Objectives)
+}
@@ -26,14 +26,41 @@ type GeneratorContext struct {
 	DocumentationGenerator AutometricsLinkCommentGenerator
 	// Allow the autometrics directive to have latency targets outside the default buckets.
 	AllowCustomLatencies bool
+	// Buckets are the histogram buckets (in seconds) that latency targets must match.
+	Buckets []float64
+	// Objectives are the percentages allowed as success rate and latency objectives.
+	Objectives []float64
 	// Flag to disable/remove the documentation links when calling the generator.
 	//
 	// This can be set in the command for the generator or through the environment.
 	DisableDocGeneration bool
+	// ErrorTypes adds the breakdown of the error ratio by error type to the documentation links, for the
+	// instrumented code that enables the error type label.
+	ErrorTypes bool
+	// PackagePath is the import path of the package of the transformed file.
+	//
+	// It is empty if the generator could not find it, in which case the generated code does not set the
+	// module name of the instrumented functions, and the module name is found at runtime instead.
+	PackagePath string
+	// ErrorResults maps the functions declared in the transformed file to their result holding the returned
+	// error, when the generator has the type information of the package. The functions are keyed by their
+	// name, prefixed with the name of their receiver type for methods, like "Server.Handle".
+	//
+	// It is nil without type information, in which case the results of custom error types are detected by
+	// the name of their type.
+	ErrorResults map[string]ErrorResult
 	// ImportMap maps the alias to import in the current file, to canonical names associated with that name.
 	ImportsMap map[string]string
 }
 
+// ErrorResult is the result of a function that holds the returned error.
+type ErrorResult struct {
+	// Index is the position of the result in the flattened list of results of the function.
+	Index int
+	// Custom is true if the type of the result is a custom type implementing error instead of error.
+	Custom bool
+}
+
 // This is almost a carbon copy of the autometrics.Context structure, except that
 // non-literal types are transcribed to strings to make it possible to reason about
 // the runtime context within generator logic.
@@ -65,7 +92,9 @@ func DefaultRuntimeCtxInfo() RuntimeCtxInfo {
 	}
 }
 
-func (c RuntimeCtxInfo) Validate(allowCustomLatencies bool) error {
+// Validate checks the alerting configuration against the objectives and buckets supported
+// by the generated rules and the instrumented code.
+func (c RuntimeCtxInfo) Validate(allowCustomLatencies bool, buckets, objectives []float64) error {
 	if c.AlertConf != nil {
 		if c.AlertConf.ServiceName == "" {
 			return errors.New("Cannot have an AlertConfiguration without a service name")
@@ -83,8 +112,8 @@ func (c RuntimeCtxInfo) Validate(allowCustomLatencies bool) error {
 			return errors.New("Cannot have a target success rate that is strictly greater than 100 (more than 100%)")
 		}
 
-		if c.AlertConf.Success != nil && !contains(autometrics.DefObjectives, c.AlertConf.Success.Objective) {
-			return fmt.Errorf("Cannot have a target success rate that is not one of the predetermined ones by generated rules files (valid targets are %v)", autometrics.DefObjectives)
+		if c.AlertConf.Success != nil && !contains(objectives, c.AlertConf.Success.Objective) {
+			return fmt.Errorf("Cannot have a target success rate that is not one of the predetermined ones by generated rules files (valid targets are %v)", objectives)
 		}
 
 		if c.AlertConf.Latency != nil {
@@ -97,17 +126,17 @@ func (c RuntimeCtxInfo) Validate(allowCustomLatencies bool) error {
 			if c.AlertConf.Latency.Objective > 100 {
 				return errors.New("Cannot have a target for latency SLO that is greater than 100 (more than 100%)")
 			}
-			if !contains(autometrics.DefObjectives, c.AlertConf.Latency.Objective) {
-				return fmt.Errorf("Cannot have a target for latency SLO that is not one of the predetermined in the generated rules files (valid targets are %v)", autometrics.DefObjectives)
+			if !contains(objectives, c.AlertConf.Latency.Objective) {
+				return fmt.Errorf("Cannot have a target for latency SLO that is not one of the predetermined in the generated rules files (valid targets are %v)", objectives)
 			}
 			if c.AlertConf.Latency.Target <= 0 {
 				return errors.New("Cannot have a target latency SLO threshold that is negative (responses expected before the query)")
 			}
-			if !allowCustomLatencies && !contains(autometrics.DefBuckets, c.AlertConf.Latency.Target.Seconds()) {
+			if !allowCustomLatencies && !contains(buckets, c.AlertConf.Latency.Target.Seconds()) {
 				return fmt.Errorf(
-					"Cannot have a target latency SLO threshold that does not match a bucket (valid threshold in seconds are %v). If you set custom latencies in your Init call, then you can add the %v flag to the //go:generate invocation to remove this error",
-					autometrics.DefBuckets,
-					autometrics.AllowCustomLatenciesFlag,
+					"Cannot have a target latency SLO threshold that does not match a bucket (valid threshold in seconds are %v). If you set custom buckets in your Init call, then declare them in the %v configuration file or with the --buckets flag of the //go:generate invocation",
+					buckets,
+					ConfigFileNames[0],
 				)
 			}
 		}
@@ -126,17 +155,34 @@ func contains[T comparable](s []T, e T) bool {
 }
 
 type GeneratorFunctionContext struct {
-	CommentIndex         int
-	FunctionName         string
-	ModuleName           string
-	ImplImportName       string
+	CommentIndex   int
+	FunctionName   string
+	ModuleName     string
+	ImplImportName string
+	// QualifiedModuleName is the module name of the function in the metrics: the package path, followed by
+	// the receiver type name for methods. It is empty if the path of the package is unknown.
+	QualifiedModuleName string
+	// TimeImportName is the name the generated code uses to refer to the time package.
+	TimeImportName       string
 	DisableDocGeneration bool
+	// UnknownArguments are the arguments of the directive that the generator does not know, and ignores.
+	UnknownArguments []string
 }
 
 func (c *GeneratorContext) ResetFuncCtx() {
 	c.FuncCtx.CommentIndex = -1
 	c.FuncCtx.FunctionName = ""
 	c.FuncCtx.ModuleName = ""
+	c.FuncCtx.QualifiedModuleName = ""
+	c.FuncCtx.UnknownArguments = nil
+}
+
+// ResetFileCtx clears all the information the context gathered while transforming a file,
+// so that the context can be reused for another file.
+func (c *GeneratorContext) ResetFileCtx() {
+	c.RuntimeCtx = DefaultRuntimeCtxInfo()
+	c.FuncCtx = GeneratorFunctionContext{CommentIndex: -1}
+	c.ImportsMap = make(map[string]string)
 }
 
 func (c *GeneratorContext) SetCommentIdx(i int) {
@@ -148,6 +194,8 @@ func NewGeneratorContext(implementation autometrics.Implementation, prometheusUr
 		Implementation:       implementation,
 		AllowCustomLatencies: allowCustomLatencies,
 		DisableDocGeneration: disableDocGeneration,
+		Buckets:              autometrics.DefBuckets,
+		Objectives:           autometrics.DefObjectives,
 		RuntimeCtx:           DefaultRuntimeCtxInfo(),
 		FuncCtx:              GeneratorFunctionContext{},
 		ImportsMap:           make(map[string]string),
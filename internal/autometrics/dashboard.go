@@ -0,0 +1,318 @@
+package autometrics // import "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+const (
+	// DefaultDashboardTitle is the title of the generated dashboards when none is given.
+	DefaultDashboardTitle = "Autometrics"
+	// DefaultDashboardUID is the UID of the generated dashboards when none is given.
+	//
+	// Keeping the UID stable allows Grafana provisioning to update the dashboard in place.
+	DefaultDashboardUID = "autometrics-functions"
+
+	// dashboardDatasourceVariable is the name of the dashboard variable holding the Prometheus datasource.
+	dashboardDatasourceVariable = "datasource"
+	// dashboardSchemaVersion is the version of the Grafana dashboard JSON model used.
+	dashboardSchemaVersion = 38
+
+	gridWidth          = 24
+	panelHeight        = 8
+	functionPanelWidth = gridWidth / 4
+	sloPanelWidth      = gridWidth / 2
+)
+
+// DashboardFunction is an instrumented function that gets its own row in the dashboard.
+type DashboardFunction struct {
+	FunctionName string
+	// ModuleName is the module label of the function in the metrics: the fully qualified path of the
+	// package, followed by the receiver type name for methods.
+	//
+	// The panels only filter on the function name if it is empty, and then mix the functions of the same
+	// name from different packages.
+	ModuleName string
+}
+
+// DashboardSpec lists the content of the generated dashboard.
+type DashboardSpec struct {
+	// Title is the title of the dashboard.
+	Title string
+	// UID is the unique identifier of the dashboard in Grafana.
+	UID string
+	// Functions are the instrumented functions, each of them gets a row of panels.
+	Functions []DashboardFunction
+	// ObjectiveNames are the names of the Service Level Objectives, each of them gets an overview panel.
+	ObjectiveNames []string
+	// Implementation is the metrics library used in the instrumented code, the panels query the
+	// series it produces.
+	Implementation autometrics.Implementation
+}
+
+type dashboard struct {
+	UID           string           `json:"uid"`
+	Title         string           `json:"title"`
+	Tags          []string         `json:"tags"`
+	Editable      bool             `json:"editable"`
+	SchemaVersion int              `json:"schemaVersion"`
+	Time          dashboardTime    `json:"time"`
+	Templating    templating       `json:"templating"`
+	Panels        []dashboardPanel `json:"panels"`
+}
+
+type dashboardTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []templateVariable `json:"list"`
+}
+
+type templateVariable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type datasourceRef struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type dashboardPanel struct {
+	ID          int            `json:"id"`
+	Type        string         `json:"type"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	GridPos     gridPos        `json:"gridPos"`
+	Collapsed   bool           `json:"collapsed,omitempty"`
+	Datasource  *datasourceRef `json:"datasource,omitempty"`
+	Targets     []panelTarget  `json:"targets,omitempty"`
+	FieldConfig *fieldConfig   `json:"fieldConfig,omitempty"`
+}
+
+type panelTarget struct {
+	RefID        string         `json:"refId"`
+	Datasource   *datasourceRef `json:"datasource"`
+	Expr         string         `json:"expr"`
+	LegendFormat string         `json:"legendFormat"`
+}
+
+type fieldConfig struct {
+	Defaults fieldDefaults `json:"defaults"`
+}
+
+type fieldDefaults struct {
+	Unit string   `json:"unit"`
+	Min  *float64 `json:"min,omitempty"`
+}
+
+// dashboardBuilder lays out the panels of the dashboard from top to bottom.
+type dashboardBuilder struct {
+	panels []dashboardPanel
+	nextID int
+	x, y   int
+}
+
+func (b *dashboardBuilder) addRow(title string) {
+	if b.x > 0 {
+		b.x = 0
+		b.y += panelHeight
+	}
+
+	b.nextID++
+	b.panels = append(b.panels, dashboardPanel{
+		ID:      b.nextID,
+		Type:    "row",
+		Title:   title,
+		GridPos: gridPos{H: 1, W: gridWidth, X: 0, Y: b.y},
+	})
+	b.y++
+}
+
+func (b *dashboardBuilder) addPanel(width int, panel dashboardPanel) {
+	if b.x+width > gridWidth {
+		b.x = 0
+		b.y += panelHeight
+	}
+
+	b.nextID++
+	panel.ID = b.nextID
+	panel.GridPos = gridPos{H: panelHeight, W: width, X: b.x, Y: b.y}
+	b.panels = append(b.panels, panel)
+	b.x += width
+}
+
+// GenerateDashboard builds a Grafana dashboard JSON model for the spec.
+//
+// The dashboard starts with an overview panel for each Service Level Objective, and then has one
+// row per instrumented function, with the same queries as the links in the generated documentation.
+func GenerateDashboard(spec DashboardSpec) ([]byte, error) {
+	title := spec.Title
+	if title == "" {
+		title = DefaultDashboardTitle
+	}
+	uid := spec.UID
+	if uid == "" {
+		uid = DefaultDashboardUID
+	}
+
+	var builder dashboardBuilder
+	names := seriesNamesFor(spec.Implementation)
+
+	objectiveNames := append([]string(nil), spec.ObjectiveNames...)
+	sort.Strings(objectiveNames)
+	if len(objectiveNames) > 0 {
+		builder.addRow("Service Level Objectives")
+		for i, name := range objectiveNames {
+			if i > 0 && name == objectiveNames[i-1] {
+				continue
+			}
+			builder.addPanel(sloPanelWidth, sloOverviewPanel(names, name))
+		}
+	}
+
+	seen := make(map[DashboardFunction]bool)
+	for _, function := range spec.Functions {
+		if seen[function] {
+			continue
+		}
+		seen[function] = true
+
+		title := function.FunctionName
+		if function.ModuleName != "" {
+			title = fmt.Sprintf("%s.%s", function.ModuleName, function.FunctionName)
+		}
+		builder.addRow(title)
+		for _, panel := range functionPanels(names, function) {
+			builder.addPanel(functionPanelWidth, panel)
+		}
+	}
+
+	board := dashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          []string{"autometrics"},
+		Editable:      true,
+		SchemaVersion: dashboardSchemaVersion,
+		Time:          dashboardTime{From: "now-6h", To: "now"},
+		Templating: templating{List: []templateVariable{{
+			Name:  dashboardDatasourceVariable,
+			Label: "Data source",
+			Type:  "datasource",
+			Query: "prometheus",
+		}}},
+		Panels: builder.panels,
+	}
+	if board.Panels == nil {
+		board.Panels = []dashboardPanel{}
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// PromQL uses characters like '<' and '&' that do not need escaping in the dashboard.
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(board); err != nil {
+		return nil, fmt.Errorf("error encoding the dashboard: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func datasource() *datasourceRef {
+	return &datasourceRef{Type: "prometheus", UID: fmt.Sprintf("${%s}", dashboardDatasourceVariable)}
+}
+
+func timeseriesPanel(title, description, unit string, targets ...panelTarget) dashboardPanel {
+	var zero float64
+
+	for i := range targets {
+		targets[i].RefID = string(rune('A' + i))
+		targets[i].Datasource = datasource()
+	}
+
+	return dashboardPanel{
+		Type:        "timeseries",
+		Title:       title,
+		Description: description,
+		Datasource:  datasource(),
+		Targets:     targets,
+		FieldConfig: &fieldConfig{Defaults: fieldDefaults{Unit: unit, Min: &zero}},
+	}
+}
+
+// functionPanels returns the panels of the row of an instrumented function.
+func functionPanels(names seriesNames, function DashboardFunction) []dashboardPanel {
+	funcName := function.FunctionName
+	legend := fmt.Sprintf("{{%s}} {{%s}}", names.moduleLabel, names.versionLabel)
+
+	selector := labelMatcher(names.functionLabel, funcName)
+	if function.ModuleName != "" {
+		selector += "," + labelMatcher(names.moduleLabel, function.ModuleName)
+	}
+
+	return []dashboardPanel{
+		timeseriesPanel("Request Rate",
+			fmt.Sprintf("Rate of calls to the `%s` function per second, averaged over 5 minute windows", funcName),
+			"reqps",
+			panelTarget{
+				Expr:         requestRateQuery(names, selector),
+				LegendFormat: legend,
+			}),
+		timeseriesPanel("Error Ratio",
+			fmt.Sprintf("Percentage of calls to the `%s` function that return errors, averaged over 5 minute windows", funcName),
+			"percentunit",
+			panelTarget{
+				Expr:         errorRatioQuery(names, selector),
+				LegendFormat: legend,
+			}),
+		timeseriesPanel("Latency (95th and 99th percentiles)",
+			fmt.Sprintf("95th and 99th percentile latencies (in seconds) for the `%s` function", funcName),
+			"s",
+			panelTarget{
+				Expr:         latencyQuery(names, selector),
+				LegendFormat: fmt.Sprintf("p{{percentile_latency}} %s", legend),
+			}),
+		timeseriesPanel("Concurrent Calls",
+			fmt.Sprintf("Concurrent calls to the `%s` function", funcName),
+			"short",
+			panelTarget{
+				Expr:         concurrentCallsQuery(names, selector),
+				LegendFormat: legend,
+			}),
+	}
+}
+
+// sloOverviewPanel returns the panel showing how well all the objectives with the given name are met.
+func sloOverviewPanel(names seriesNames, objectiveName string) dashboardPanel {
+	// Functions that only have one kind of objective still have the other objective labels, but empty.
+	selector := fmt.Sprintf("%s=\"%s\",%s!=\"\"", names.sloNameLabel, objectiveName, names.targetSuccessRateLabel)
+
+	return timeseriesPanel(objectiveName,
+		fmt.Sprintf("Percentage of calls meeting the `%s` objectives, averaged over 5 minute windows", objectiveName),
+		"percentunit",
+		panelTarget{
+			Expr:         fmt.Sprintf("1 - (\n%s\n)", successRateErrorRatioQuery(names, selector, "5m")),
+			LegendFormat: fmt.Sprintf("Success rate (objective: {{%s}}%%)", names.targetSuccessRateLabel),
+		},
+		panelTarget{
+			Expr:         fmt.Sprintf("1 - (\n%s\n)", latencyErrorRatioQuery(names, selector, "5m")),
+			LegendFormat: fmt.Sprintf("Calls below the latency threshold (objective: {{%s}}%%)", names.targetSuccessRateLabel),
+		},
+	)
+}
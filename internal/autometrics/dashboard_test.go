@@ -0,0 +1,73 @@
+package autometrics
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateDashboard(t *testing.T) {
+	spec := DashboardSpec{
+		Functions: []DashboardFunction{
+			{FunctionName: "AddUser", ModuleName: "example.com/service/api"},
+			{FunctionName: "RemoveUser", ModuleName: "example.com/service/api"},
+			{FunctionName: "AddUser", ModuleName: "example.com/service/api"},
+			{FunctionName: "AddUser", ModuleName: "example.com/service/admin"},
+		},
+		ObjectiveNames: []string{"Users", "API", "Users"},
+	}
+
+	content, err := GenerateDashboard(spec)
+	if err != nil {
+		t.Fatalf("error generating the dashboard: %s", err)
+	}
+
+	var board dashboard
+	if err := json.Unmarshal(content, &board); err != nil {
+		t.Fatalf("the generated dashboard is not valid JSON: %s", err)
+	}
+
+	assert.Equal(t, DefaultDashboardTitle, board.Title)
+	assert.Equal(t, DefaultDashboardUID, board.UID)
+
+	var titles []string
+	for _, panel := range board.Panels {
+		if panel.Type == "row" {
+			titles = append(titles, panel.Title)
+		}
+	}
+	assert.Equal(t,
+		[]string{"Service Level Objectives", "example.com/service/api.AddUser", "example.com/service/api.RemoveUser", "example.com/service/admin.AddUser"},
+		titles,
+		"There must be one row for the objectives, then one row per function.")
+
+	// 1 row and 2 objectives, then 3 rows of 4 panels.
+	if !assert.Len(t, board.Panels, 1+2+3*(1+4)) {
+		return
+	}
+
+	api := board.Panels[1]
+	assert.Equal(t, "API", api.Title)
+	assert.Equal(t, gridPos{H: panelHeight, W: sloPanelWidth, X: 0, Y: 1}, api.GridPos)
+	assert.Contains(t, api.Targets[0].Expr, `function_calls_total{objective_name="API",objective_percentile!="",result="error"}`)
+	assert.Contains(t, api.Targets[1].Expr, `function_calls_duration_seconds_bucket{objective_name="API",objective_percentile!=""}`)
+	assert.Equal(t, "${datasource}", api.Targets[0].Datasource.UID)
+	assert.Equal(t, gridPos{H: panelHeight, W: sloPanelWidth, X: sloPanelWidth, Y: 1}, board.Panels[2].GridPos)
+
+	addUserRow := board.Panels[3]
+	assert.Equal(t, gridPos{H: 1, W: gridWidth, X: 0, Y: 1 + panelHeight}, addUserRow.GridPos)
+
+	requestRate := board.Panels[4]
+	assert.Equal(t, "Request Rate", requestRate.Title)
+	assert.Equal(t,
+		requestRateQuery(prometheusSeriesNames, `function="AddUser",module="example.com/service/api"`),
+		requestRate.Targets[0].Expr,
+		"The panels must use the same queries as the documentation links, for the function of the row only.")
+	for _, panel := range board.Panels[14:] {
+		assert.Contains(t, panel.Targets[0].Expr, `{function="AddUser",module="example.com/service/admin"`,
+			"The functions with the same name in other packages must not be mixed.")
+	}
+	assert.Equal(t, "Concurrent Calls", board.Panels[7].Title)
+	assert.Equal(t, gridPos{H: panelHeight, W: functionPanelWidth, X: 3 * functionPanelWidth, Y: 2 + panelHeight}, board.Panels[7].GridPos)
+}
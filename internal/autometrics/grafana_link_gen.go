@@ -0,0 +1,88 @@
+package autometrics // import "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+type Grafana struct {
+	instanceUrl   url.URL
+	datasourceUID string
+	orgID         int
+}
+
+// NewGrafanaDoc builds a documentation comment generator that creates Grafana Explore links.
+//
+// The queries are run against the Prometheus datasource with the given UID, in the organization
+// with the given ID. An empty datasourceUID uses the default datasource of the organization, and
+// an orgID of 0 uses the current organization of the user.
+//
+// The document generator implements the AutometricsLinkCommentGenerator interface.
+func NewGrafanaDoc(instanceUrl url.URL, datasourceUID string, orgID int) Grafana {
+	return Grafana{instanceUrl: instanceUrl, datasourceUID: datasourceUID, orgID: orgID}
+}
+
+// grafanaExploreState is the state of an Explore pane, as encoded in the "left" parameter of Explore URLs.
+type grafanaExploreState struct {
+	Datasource string                `json:"datasource,omitempty"`
+	Queries    []grafanaExploreQuery `json:"queries"`
+	Range      grafanaExploreRange   `json:"range"`
+}
+
+type grafanaExploreQuery struct {
+	RefID      string                    `json:"refId"`
+	Datasource *grafanaExploreDatasource `json:"datasource,omitempty"`
+	Expr       string                    `json:"expr"`
+}
+
+type grafanaExploreDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaExploreRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (g Grafana) makeGrafanaUrl(query, comment string) url.URL {
+	ret := g.instanceUrl
+
+	state := grafanaExploreState{
+		Datasource: g.datasourceUID,
+		Queries: []grafanaExploreQuery{{
+			RefID: "A",
+			Expr:  fmt.Sprintf("# %s\n\n%s", comment, query),
+		}},
+		Range: grafanaExploreRange{From: "now-1h", To: "now"},
+	}
+	if g.datasourceUID != "" {
+		state.Queries[0].Datasource = &grafanaExploreDatasource{Type: "prometheus", UID: g.datasourceUID}
+	}
+
+	// Marshalling a structure of strings cannot fail.
+	left, _ := json.Marshal(state)
+
+	params := ret.Query()
+	params.Add("left", string(left))
+	if g.orgID > 0 {
+		params.Add("orgId", strconv.Itoa(g.orgID))
+	}
+
+	ret.RawQuery = params.Encode()
+	// Grafana is often served under a sub path, so the path of the instance URL is kept
+	ret.Path = path.Join("/", ret.Path, "explore")
+
+	return ret
+}
+
+func (g Grafana) GenerateAutometricsComment(ctx GeneratorContext, funcName, moduleName string) []string {
+	return generateLinksComment(ctx, "Grafana", funcName, g.makeGrafanaUrl)
+}
+
+func (g Grafana) GeneratedLinks() []string {
+	return generatedLinks
+}
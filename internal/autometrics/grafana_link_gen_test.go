@@ -0,0 +1,69 @@
+package autometrics
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGrafanaLinks tests that the Grafana Explore links use the same queries as the Prometheus links,
+// against the configured datasource and organization.
+func TestGrafanaLinks(t *testing.T) {
+	instanceUrl, err := url.Parse("https://example.com/grafana/")
+	if err != nil {
+		t.Fatalf("error parsing the instance URL: %s", err)
+	}
+
+	ctx := GeneratorContext{RuntimeCtx: DefaultRuntimeCtxInfo()}
+	lines := NewGrafanaDoc(*instanceUrl, "mimir-uid", 3).GenerateAutometricsComment(ctx, "main", "main")
+
+	assert.Equal(t, "// # Grafana", lines[0])
+
+	var link string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "// [Request Rate]: ") {
+			link = strings.TrimPrefix(line, "// [Request Rate]: ")
+		}
+	}
+	if link == "" {
+		t.Fatalf("no request rate link in the comment: %v", lines)
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("error parsing the generated link %s: %s", link, err)
+	}
+	assert.Equal(t, "example.com", parsed.Host)
+	assert.Equal(t, "/grafana/explore", parsed.Path)
+	assert.Equal(t, "3", parsed.Query().Get("orgId"))
+
+	var state grafanaExploreState
+	if err := json.Unmarshal([]byte(parsed.Query().Get("left")), &state); err != nil {
+		t.Fatalf("error decoding the Explore state: %s", err)
+	}
+
+	assert.Equal(t, "mimir-uid", state.Datasource)
+	assert.Equal(t, grafanaExploreRange{From: "now-1h", To: "now"}, state.Range)
+	if assert.Len(t, state.Queries, 1) {
+		assert.Equal(t, &grafanaExploreDatasource{Type: "prometheus", UID: "mimir-uid"}, state.Queries[0].Datasource)
+		assert.True(t,
+			strings.HasSuffix(state.Queries[0].Expr, requestRateQuery(prometheusSeriesNames, labelMatcher(prometheusSeriesNames.functionLabel, "main"))),
+			"The Grafana link must use the same query as the Prometheus link, got %s", state.Queries[0].Expr)
+	}
+
+	assert.ElementsMatch(t, NewPrometheusDoc(url.URL{}).GeneratedLinks(), Grafana{}.GeneratedLinks(),
+		"All the generators must create the same links.")
+}
+
+// TestGrafanaLinksDefaults tests that the datasource and organization are omitted when they are not set.
+func TestGrafanaLinksDefaults(t *testing.T) {
+	g := NewGrafanaDoc(url.URL{Scheme: "http", Host: "localhost:3000"}, "", 0)
+	link := g.makeGrafanaUrl("up", "comment")
+
+	assert.Equal(t, "/explore", link.Path)
+	assert.False(t, link.Query().Has("orgId"))
+	assert.Equal(t, `{"queries":[{"refId":"A","expr":"# comment\n\nup"}],"range":{"from":"now-1h","to":"now"}}`, link.Query().Get("left"))
+}
@@ -3,8 +3,6 @@ package autometrics // import "github.com/autometrics-dev/autometrics-go/interna
 import (
 	"fmt"
 	"net/url"
-
-	prometheus "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
 )
 
 type AutometricsLinkCommentGenerator interface {
@@ -46,55 +44,75 @@ func (p Prometheus) makePrometheusUrl(query, comment string) url.URL {
 	return ret
 }
 
-func addBuildInfoLabels() string {
+func addBuildInfoLabels(names seriesNames) string {
 	return fmt.Sprintf("* on (instance, job) group_left(%s, %s) last_over_time(%s[1s])",
-		prometheus.VersionLabel,
-		prometheus.CommitLabel,
-		prometheus.BuildInfoName,
+		names.versionLabel,
+		names.commitLabel,
+		names.buildInfo,
 	)
 }
 
-func requestRateQuery(counterName, labelKey, labelValue string) string {
-	return fmt.Sprintf("sum by (%s, %s, %s, %s, %s) (rate(%s{%s=\"%s\"}[5m]) %s)",
-		prometheus.FunctionLabel,
-		prometheus.ModuleLabel,
-		prometheus.ServiceNameLabel,
-		prometheus.VersionLabel,
-		prometheus.CommitLabel,
-		counterName,
-		labelKey,
-		labelValue,
-		addBuildInfoLabels(),
+// labelMatcher returns the PromQL matcher of the label equal to the value, to use in the selectors of the queries.
+func labelMatcher(label, value string) string {
+	return fmt.Sprintf("%s=\"%s\"", label, value)
+}
+
+func requestRateQuery(names seriesNames, selector string) string {
+	return fmt.Sprintf("sum by (%s, %s, %s, %s, %s) (rate(%s{%s}[5m]) %s)",
+		names.functionLabel,
+		names.moduleLabel,
+		names.serviceNameLabel,
+		names.versionLabel,
+		names.commitLabel,
+		names.callsCount,
+		selector,
+		addBuildInfoLabels(names),
 	)
 }
 
-func errorRatioQuery(counterName, labelKey, labelValue string) string {
-	return fmt.Sprintf("(sum by (%s, %s, %s, %s, %s) (rate(%s{%s=\"%s\",%s=\"error\"}[5m]) %s)) / (%s)",
-		prometheus.FunctionLabel,
-		prometheus.ModuleLabel,
-		prometheus.ServiceNameLabel,
-		prometheus.VersionLabel,
-		prometheus.CommitLabel,
-		counterName,
-		labelKey,
-		labelValue,
-		prometheus.ResultLabel,
-		addBuildInfoLabels(),
-		requestRateQuery(counterName, labelKey, labelValue),
+func errorRatioQuery(names seriesNames, selector string) string {
+	return fmt.Sprintf("(sum by (%s, %s, %s, %s, %s) (rate(%s{%s,%s=\"error\"}[5m]) %s)) / (%s)",
+		names.functionLabel,
+		names.moduleLabel,
+		names.serviceNameLabel,
+		names.versionLabel,
+		names.commitLabel,
+		names.callsCount,
+		selector,
+		names.resultLabel,
+		addBuildInfoLabels(names),
+		requestRateQuery(names, selector),
 	)
 }
 
-func latencyQuery(bucketName, labelKey, labelValue string) string {
-	latency := fmt.Sprintf("sum by (le, %s, %s, %s, %s, %s) (rate(%s_bucket{%s=\"%s\"}[5m]) %s)",
-		prometheus.FunctionLabel,
-		prometheus.ModuleLabel,
-		prometheus.ServiceNameLabel,
-		prometheus.VersionLabel,
-		prometheus.CommitLabel,
-		bucketName,
-		labelKey,
-		labelValue,
-		addBuildInfoLabels(),
+// errorTypeRatioQuery is [errorRatioQuery] broken down by the error type of the failed calls.
+func errorTypeRatioQuery(names seriesNames, selector string) string {
+	return fmt.Sprintf("(sum by (%s, %s, %s, %s, %s, %s) (rate(%s{%s,%s=\"error\"}[5m]) %s)) / ignoring (%s) group_left (%s)",
+		names.errorTypeLabel,
+		names.functionLabel,
+		names.moduleLabel,
+		names.serviceNameLabel,
+		names.versionLabel,
+		names.commitLabel,
+		names.callsCount,
+		selector,
+		names.resultLabel,
+		addBuildInfoLabels(names),
+		names.errorTypeLabel,
+		requestRateQuery(names, selector),
+	)
+}
+
+func latencyQuery(names seriesNames, selector string) string {
+	latency := fmt.Sprintf("sum by (le, %s, %s, %s, %s, %s) (rate(%s_bucket{%s}[5m]) %s)",
+		names.functionLabel,
+		names.moduleLabel,
+		names.serviceNameLabel,
+		names.versionLabel,
+		names.commitLabel,
+		names.callsDuration,
+		selector,
+		addBuildInfoLabels(names),
 	)
 
 	return fmt.Sprintf(
@@ -105,43 +123,94 @@ func latencyQuery(bucketName, labelKey, labelValue string) string {
 	)
 }
 
-func concurrentCallsQuery(gaugeName, labelKey, labelValue string) string {
-	return fmt.Sprintf("sum by (%s, %s, %s, %s, %s) (%s{%s=\"%s\"} %s)",
-		prometheus.FunctionLabel,
-		prometheus.ModuleLabel,
-		prometheus.ServiceNameLabel,
-		prometheus.VersionLabel,
-		prometheus.CommitLabel,
-		gaugeName,
-		labelKey,
-		labelValue,
-		addBuildInfoLabels(),
+func concurrentCallsQuery(names seriesNames, selector string) string {
+	return fmt.Sprintf("sum by (%s, %s, %s, %s, %s) (%s{%s} %s)",
+		names.functionLabel,
+		names.moduleLabel,
+		names.serviceNameLabel,
+		names.versionLabel,
+		names.commitLabel,
+		names.callsConcurrent,
+		selector,
+		addBuildInfoLabels(names),
 	)
 }
 
 func (p Prometheus) GenerateAutometricsComment(ctx GeneratorContext, funcName, moduleName string) []string {
-	requestRateUrl := p.makePrometheusUrl(
-		requestRateQuery(prometheus.FunctionCallsCountName, prometheus.FunctionLabel, funcName), fmt.Sprintf("Rate of calls to the `%s` function per second, averaged over 5 minute windows", funcName))
-	calleeRequestRateUrl := p.makePrometheusUrl(
-		requestRateQuery(prometheus.FunctionCallsCountName, prometheus.CallerFunctionLabel, funcName), fmt.Sprintf("Rate of function calls emanating from `%s` function per second, averaged over 5 minute windows", funcName))
-	errorRatioUrl := p.makePrometheusUrl(
-		errorRatioQuery(prometheus.FunctionCallsCountName, prometheus.FunctionLabel, funcName), fmt.Sprintf("Percentage of calls to the `%s` function that return errors, averaged over 5 minute windows", funcName))
-	calleeErrorRatioUrl := p.makePrometheusUrl(
-		errorRatioQuery(prometheus.FunctionCallsCountName, prometheus.CallerFunctionLabel, funcName), fmt.Sprintf("Percentage of function emanating from `%s` function that return errors, averaged over 5 minute windows", funcName))
-	latencyUrl := p.makePrometheusUrl(
-		latencyQuery(prometheus.FunctionCallsDurationName, prometheus.FunctionLabel, funcName), fmt.Sprintf("95th and 99th percentile latencies (in seconds) for the `%s` function", funcName))
-	concurrentCallsUrl := p.makePrometheusUrl(
-		concurrentCallsQuery(prometheus.FunctionCallsConcurrentName, prometheus.FunctionLabel, funcName), fmt.Sprintf("Concurrent calls to the `%s` function", funcName))
+	return generateLinksComment(ctx, "Prometheus", funcName, p.makePrometheusUrl)
+}
+
+func (p Prometheus) GeneratedLinks() []string {
+	return generatedLinks
+}
+
+// generatedLinks are the names of the links added by all the documentation generators.
+var generatedLinks = []string{"Request Rate", "Error Ratio", "Error Ratio by Error Type", "Latency (95th and 99th percentiles)", "Concurrent Calls", "Request Rate Callee", "Error Ratio Callee"}
+
+// KnownGeneratedLinks returns the names of the links created by all the documentation generators.
+//
+// The cleanup of former passes uses all of them, so that the links of a generator are removed
+// even if another generator is used in the next pass.
+func KnownGeneratedLinks() []string {
+	var links []string
+	for _, generator := range []AutometricsLinkCommentGenerator{Prometheus{}, Grafana{}} {
+		for _, link := range generator.GeneratedLinks() {
+			if !containsString(links, link) {
+				links = append(links, link)
+			}
+		}
+	}
+
+	return links
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generateLinksComment builds the documentation comment for funcName, with makeUrl building the link
+// to the graph of each query. The title is the name of the tool the links point to.
+func generateLinksComment(ctx GeneratorContext, title, funcName string, makeUrl func(query, comment string) url.URL) []string {
+	// The queries must match the names of the series produced by the implementation used in the code.
+	names := seriesNamesFor(ctx.Implementation)
+
+	requestRateUrl := makeUrl(
+		requestRateQuery(names, labelMatcher(names.functionLabel, funcName)), fmt.Sprintf("Rate of calls to the `%s` function per second, averaged over 5 minute windows", funcName))
+	calleeRequestRateUrl := makeUrl(
+		requestRateQuery(names, labelMatcher(names.callerFunctionLabel, funcName)), fmt.Sprintf("Rate of function calls emanating from `%s` function per second, averaged over 5 minute windows", funcName))
+	errorRatioUrl := makeUrl(
+		errorRatioQuery(names, labelMatcher(names.functionLabel, funcName)), fmt.Sprintf("Percentage of calls to the `%s` function that return errors, averaged over 5 minute windows", funcName))
+	calleeErrorRatioUrl := makeUrl(
+		errorRatioQuery(names, labelMatcher(names.callerFunctionLabel, funcName)), fmt.Sprintf("Percentage of function emanating from `%s` function that return errors, averaged over 5 minute windows", funcName))
+	errorTypeRatioUrl := makeUrl(
+		errorTypeRatioQuery(names, labelMatcher(names.functionLabel, funcName)), fmt.Sprintf("Percentage of calls to the `%s` function that return errors, by error type, averaged over 5 minute windows", funcName))
+	latencyUrl := makeUrl(
+		latencyQuery(names, labelMatcher(names.functionLabel, funcName)), fmt.Sprintf("95th and 99th percentile latencies (in seconds) for the `%s` function", funcName))
+	concurrentCallsUrl := makeUrl(
+		concurrentCallsQuery(names, labelMatcher(names.functionLabel, funcName)), fmt.Sprintf("Concurrent calls to the `%s` function", funcName))
 
 	// Not using raw `` strings because it's impossible to escape ` within those
 	retval := []string{
-		"// # Prometheus",
+		fmt.Sprintf("// # %s", title),
 		"//",
 		fmt.Sprintf("// View the live metrics for the `%s` function:", funcName),
 		"//   - [Request Rate]",
 		"//   - [Error Ratio]",
-		"//   - [Latency (95th and 99th percentiles)]",
 	}
+	if ctx.ErrorTypes {
+		retval = append(retval,
+			"//   - [Error Ratio by Error Type]",
+		)
+	}
+	retval = append(retval,
+		"//   - [Latency (95th and 99th percentiles)]",
+	)
 	if ctx.RuntimeCtx.TrackConcurrentCalls {
 		retval = append(retval,
 			"//   - [Concurrent Calls]",
@@ -158,6 +227,13 @@ func (p Prometheus) GenerateAutometricsComment(ctx GeneratorContext, funcName, m
 		"//",
 		fmt.Sprintf("// [Request Rate]: %s", requestRateUrl.String()),
 		fmt.Sprintf("// [Error Ratio]: %s", errorRatioUrl.String()),
+	)
+	if ctx.ErrorTypes {
+		retval = append(retval,
+			fmt.Sprintf("// [Error Ratio by Error Type]: %s", errorTypeRatioUrl.String()),
+		)
+	}
+	retval = append(retval,
 		fmt.Sprintf("// [Latency (95th and 99th percentiles)]: %s", latencyUrl.String()),
 	)
 
@@ -175,7 +251,3 @@ func (p Prometheus) GenerateAutometricsComment(ctx GeneratorContext, funcName, m
 
 	return retval
 }
-
-func (p Prometheus) GeneratedLinks() []string {
-	return []string{"Request Rate", "Error Ratio", "Latency (95th and 99th percentiles)", "Concurrent Calls", "Request Rate Callee", "Error Ratio Callee"}
-}
@@ -0,0 +1,324 @@
+package autometrics // import "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SuccessRateErrorRatioRecord is the prefix of the recording rules that compute the ratio of
+	// failed calls in success rate objectives.
+	//
+	// The full name of the recorded metric is suffixed with the window, like "slo:function_calls_error:ratio_rate5m".
+	SuccessRateErrorRatioRecord = "slo:function_calls_error:ratio_rate"
+	// LatencyErrorRatioRecord is the prefix of the recording rules that compute the ratio of
+	// calls slower than the threshold in latency objectives.
+	//
+	// The full name of the recorded metric is suffixed with the window, like "slo:function_calls_slow:ratio_rate5m".
+	LatencyErrorRatioRecord = "slo:function_calls_slow:ratio_rate"
+
+	// SeverityLabel is the label added to the generated alerts to route them.
+	SeverityLabel = "severity"
+
+	// equalityCheckLabel is a temporary label used to only keep the buckets whose upper bound
+	// matches the latency threshold of the objective.
+	equalityCheckLabel = "autometrics_check_label_equality"
+)
+
+// burnRateAlert is one of the multi-window burn rate conditions of an alert.
+//
+// The windows and burn rates come from "The Site Reliability Workbook", chapter 5,
+// and assume a 30 days SLO period.
+type burnRateAlert struct {
+	longWindow  string
+	shortWindow string
+	burnRate    float64
+}
+
+var (
+	// ruleWindows are the windows of all the recording rules.
+	ruleWindows = []string{"5m", "30m", "1h", "2h", "6h", "1d", "3d"}
+
+	pageBurnRates = []burnRateAlert{
+		{longWindow: "1h", shortWindow: "5m", burnRate: 14.4},
+		{longWindow: "6h", shortWindow: "30m", burnRate: 6},
+	}
+	ticketBurnRates = []burnRateAlert{
+		{longWindow: "1d", shortWindow: "2h", burnRate: 3},
+		{longWindow: "3d", shortWindow: "6h", burnRate: 1},
+	}
+)
+
+// RulesSpec lists the Service Level Objectives that the generated rules must cover.
+type RulesSpec struct {
+	// SuccessObjectives are the percentages of successful calls used in success rate objectives.
+	SuccessObjectives []float64
+	// LatencyObjectives are the percentages of fast enough calls used in latency objectives.
+	LatencyObjectives []float64
+	// LatencyThresholds are the latency thresholds (in seconds) used in latency objectives.
+	//
+	// The generated rules work for any threshold that matches a histogram bucket, so the thresholds
+	// are only used to document the rules file.
+	LatencyThresholds []float64
+	// Implementation is the metrics library used in the instrumented code, the rules query the
+	// series it produces.
+	Implementation autometrics.Implementation
+}
+
+// NewRulesSpec builds the specification of the rules covering the default objectives and
+// all the objectives in the alert configurations.
+func NewRulesSpec(defaultObjectives []float64, confs []*autometrics.AlertConfiguration) RulesSpec {
+	spec := RulesSpec{
+		SuccessObjectives: append([]float64(nil), defaultObjectives...),
+		LatencyObjectives: append([]float64(nil), defaultObjectives...),
+	}
+
+	for _, conf := range confs {
+		if conf == nil {
+			continue
+		}
+		if conf.Success != nil {
+			spec.SuccessObjectives = append(spec.SuccessObjectives, conf.Success.Objective)
+		}
+		if conf.Latency != nil {
+			spec.LatencyObjectives = append(spec.LatencyObjectives, conf.Latency.Objective)
+			spec.LatencyThresholds = append(spec.LatencyThresholds, conf.Latency.Target.Seconds())
+		}
+	}
+
+	spec.SuccessObjectives = sortedUnique(spec.SuccessObjectives)
+	spec.LatencyObjectives = sortedUnique(spec.LatencyObjectives)
+	spec.LatencyThresholds = sortedUnique(spec.LatencyThresholds)
+
+	return spec
+}
+
+func sortedUnique(values []float64) []float64 {
+	sort.Float64s(values)
+
+	unique := values[:0]
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			unique = append(unique, value)
+		}
+	}
+
+	return unique
+}
+
+type rulesFile struct {
+	Groups []rulesGroup `yaml:"groups"`
+}
+
+type rulesGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// GenerateRules builds a Prometheus rules file for the objectives in the spec.
+//
+// For each objective, the file contains recording rules that compute the ratio of calls that
+// do not meet the objective over multiple windows, and the multi-window, multi-burn-rate alerts
+// built on top of those.
+func GenerateRules(spec RulesSpec) ([]byte, error) {
+	var file rulesFile
+
+	names := seriesNamesFor(spec.Implementation)
+	for _, objective := range spec.SuccessObjectives {
+		file.Groups = append(file.Groups, successRateGroup(names, objective))
+	}
+	for _, objective := range spec.LatencyObjectives {
+		file.Groups = append(file.Groups, latencyGroup(names, objective))
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintln(&buf, "# Code generated by autometrics; DO NOT EDIT.")
+	fmt.Fprintln(&buf, "#")
+	fmt.Fprintf(&buf, "# Success rate objectives: %s\n", formatList(spec.SuccessObjectives))
+	fmt.Fprintf(&buf, "# Latency objectives: %s\n", formatList(spec.LatencyObjectives))
+	if len(spec.LatencyThresholds) > 0 {
+		fmt.Fprintf(&buf, "# Latency thresholds used in the code (in seconds): %s\n", formatList(spec.LatencyThresholds))
+	}
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(file); err != nil {
+		return nil, fmt.Errorf("error encoding the rules: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("error encoding the rules: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func successRateGroup(names seriesNames, objective float64) rulesGroup {
+	percentile := formatFloat(objective)
+	group := rulesGroup{Name: fmt.Sprintf("autometrics-success-rate-%s", percentile)}
+
+	for _, window := range ruleWindows {
+		group.Rules = append(group.Rules, rule{
+			Record: SuccessRateErrorRatioRecord + window,
+			Expr:   successRateErrorRatioQuery(names, percentileSelector(names, percentile), window),
+		})
+	}
+
+	group.Rules = append(group.Rules,
+		burnRateRule(names, "HighErrorRate", SuccessRateErrorRatioRecord, objective, "page", pageBurnRates,
+			fmt.Sprintf("High error rate on the {{ $labels.%s }} objective", names.sloNameLabel)),
+		burnRateRule(names, "HighErrorRate", SuccessRateErrorRatioRecord, objective, "ticket", ticketBurnRates,
+			fmt.Sprintf("High error rate on the {{ $labels.%s }} objective", names.sloNameLabel)),
+	)
+
+	return group
+}
+
+func latencyGroup(names seriesNames, objective float64) rulesGroup {
+	percentile := formatFloat(objective)
+	group := rulesGroup{Name: fmt.Sprintf("autometrics-latency-%s", percentile)}
+
+	for _, window := range ruleWindows {
+		group.Rules = append(group.Rules, rule{
+			Record: LatencyErrorRatioRecord + window,
+			Expr:   latencyErrorRatioQuery(names, percentileSelector(names, percentile), window),
+		})
+	}
+
+	group.Rules = append(group.Rules,
+		burnRateRule(names, "HighLatency", LatencyErrorRatioRecord, objective, "page", pageBurnRates,
+			fmt.Sprintf("High latency on the {{ $labels.%s }} objective", names.sloNameLabel)),
+		burnRateRule(names, "HighLatency", LatencyErrorRatioRecord, objective, "ticket", ticketBurnRates,
+			fmt.Sprintf("High latency on the {{ $labels.%s }} objective", names.sloNameLabel)),
+	)
+
+	return group
+}
+
+// percentileSelector returns the label matcher selecting the series of the objectives with the given percentile.
+func percentileSelector(names seriesNames, percentile string) string {
+	return fmt.Sprintf("%s=\"%s\"", names.targetSuccessRateLabel, percentile)
+}
+
+// successRateErrorRatioQuery returns the ratio of failed calls over the window, for each objective
+// matching the selector.
+//
+// The ratio is 0 (and not absent) when there are calls but none of them failed.
+func successRateErrorRatioQuery(names seriesNames, selector, window string) string {
+	calls := fmt.Sprintf("sum by (%s, %s) (rate(%s{%s}[%s]))",
+		names.sloNameLabel,
+		names.targetSuccessRateLabel,
+		names.callsCount,
+		selector,
+		window,
+	)
+	errors := fmt.Sprintf("sum by (%s, %s) (rate(%s{%s,%s=\"error\"}[%s]))",
+		names.sloNameLabel,
+		names.targetSuccessRateLabel,
+		names.callsCount,
+		selector,
+		names.resultLabel,
+		window,
+	)
+
+	return fmt.Sprintf("(\n  %s\n  or\n  %s * 0\n)\n/\n%s", errors, calls, calls)
+}
+
+// latencyErrorRatioQuery returns the ratio of calls slower than the threshold over the window, for each
+// objective matching the selector.
+//
+// The threshold is not part of the query: the label_join trick only keeps the buckets whose upper bound
+// is the threshold of the objective, so a single query covers all the thresholds.
+func latencyErrorRatioQuery(names seriesNames, selector, window string) string {
+	buckets := fmt.Sprintf("rate(%s_bucket{%s}[%s])",
+		names.callsDuration,
+		selector,
+		window,
+	)
+	count := fmt.Sprintf("rate(%s_count{%s}[%s])",
+		names.callsDuration,
+		selector,
+		window,
+	)
+
+	return fmt.Sprintf(
+		"1 - (\n"+
+			"  sum by (%s, %s) (\n"+
+			"    label_join(%s, \"%s\", \"\", \"%s\")\n"+
+			"    and\n"+
+			"    label_join(%s, \"%s\", \"\", \"le\")\n"+
+			"  )\n"+
+			"  /\n"+
+			"  sum by (%s, %s) (%s)\n"+
+			")",
+		names.sloNameLabel, names.targetSuccessRateLabel,
+		buckets, equalityCheckLabel, names.targetLatencyLabel,
+		buckets, equalityCheckLabel,
+		names.sloNameLabel, names.targetSuccessRateLabel, count,
+	)
+}
+
+// burnRateRule builds an alert that fires when any of the multi-window burn rate conditions is met.
+func burnRateRule(names seriesNames, name, record string, objective float64, severity string, burnRates []burnRateAlert, summary string) rule {
+	percentile := formatFloat(objective)
+	budget := strconv.FormatFloat((100-objective)/100, 'g', 12, 64)
+
+	conditions := make([]string, 0, len(burnRates))
+	// The description lists every condition, as the alert fires on any of them.
+	descriptions := make([]string, 0, len(burnRates))
+	for _, burnRate := range burnRates {
+		threshold := fmt.Sprintf("(%s * %s)", formatFloat(burnRate.burnRate), budget)
+		conditions = append(conditions, fmt.Sprintf(
+			"(\n  %s%s{%s} > %s\n  and\n  %s%s{%s} > %s\n)",
+			record, burnRate.longWindow, percentileSelector(names, percentile), threshold,
+			record, burnRate.shortWindow, percentileSelector(names, percentile), threshold,
+		))
+		descriptions = append(descriptions, fmt.Sprintf(
+			"at least %s times faster than allowed over the last %s (and the last %s)",
+			formatFloat(burnRate.burnRate), burnRate.longWindow, burnRate.shortWindow,
+		))
+	}
+
+	return rule{
+		Alert: name,
+		Expr:  strings.Join(conditions, "\nor\n"),
+		Labels: map[string]string{
+			SeverityLabel: severity,
+		},
+		Annotations: map[string]string{
+			"summary": summary,
+			"description": fmt.Sprintf(
+				"The {{ $labels.%s }} objective (%s%%) is burning its error budget %s.",
+				names.sloNameLabel, percentile, strings.Join(descriptions, ", or ")),
+		},
+	}
+}
+
+// formatFloat formats the objectives the same way the instrumentation does in the metric labels.
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatList(values []float64) string {
+	formatted := make([]string, 0, len(values))
+	for _, value := range values {
+		formatted = append(formatted, formatFloat(value))
+	}
+
+	return strings.Join(formatted, ", ")
+}
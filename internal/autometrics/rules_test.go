@@ -0,0 +1,92 @@
+package autometrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+func TestNewRulesSpec(t *testing.T) {
+	confs := []*autometrics.AlertConfiguration{
+		nil,
+		{
+			ServiceName: "API",
+			Success:     &autometrics.SuccessSlo{Objective: 99.99},
+			Latency:     &autometrics.LatencySlo{Target: 250 * time.Millisecond, Objective: 99},
+		},
+		{
+			ServiceName: "API",
+			Latency:     &autometrics.LatencySlo{Target: 100 * time.Millisecond, Objective: 99},
+		},
+	}
+
+	spec := NewRulesSpec([]float64{99, 90}, confs)
+
+	assert.Equal(t, []float64{90, 99, 99.99}, spec.SuccessObjectives)
+	assert.Equal(t, []float64{90, 99}, spec.LatencyObjectives)
+	assert.Equal(t, []float64{0.1, 0.25}, spec.LatencyThresholds)
+}
+
+func TestGenerateRules(t *testing.T) {
+	spec := RulesSpec{
+		SuccessObjectives: []float64{99.9},
+		LatencyObjectives: []float64{99},
+		LatencyThresholds: []float64{0.25},
+	}
+
+	content, err := GenerateRules(spec)
+	if err != nil {
+		t.Fatalf("error generating the rules: %s", err)
+	}
+
+	assert.True(t, strings.HasPrefix(string(content), "# Code generated by autometrics; DO NOT EDIT."))
+	assert.Contains(t, string(content), "# Latency thresholds used in the code (in seconds): 0.25")
+
+	var file rulesFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		t.Fatalf("the generated rules are not valid YAML: %s", err)
+	}
+
+	if !assert.Len(t, file.Groups, 2) {
+		return
+	}
+
+	success := file.Groups[0]
+	assert.Equal(t, "autometrics-success-rate-99.9", success.Name)
+	assert.Len(t, success.Rules, len(ruleWindows)+2)
+	assert.Equal(t, "slo:function_calls_error:ratio_rate5m", success.Rules[0].Record)
+	assert.Contains(t, success.Rules[0].Expr, `rate(function_calls_total{objective_percentile="99.9",result="error"}[5m])`)
+
+	page := success.Rules[len(ruleWindows)]
+	assert.Equal(t, "HighErrorRate", page.Alert)
+	assert.Equal(t, "page", page.Labels[SeverityLabel])
+	assert.Contains(t, page.Expr, `slo:function_calls_error:ratio_rate1h{objective_percentile="99.9"} > (14.4 * 0.001)`)
+	assert.Contains(t, page.Expr, `slo:function_calls_error:ratio_rate5m{objective_percentile="99.9"} > (14.4 * 0.001)`)
+	assert.Contains(t, page.Expr, `slo:function_calls_error:ratio_rate30m{objective_percentile="99.9"} > (6 * 0.001)`)
+	assert.Equal(t,
+		"The {{ $labels.objective_name }} objective (99.9%) is burning its error budget "+
+			"at least 14.4 times faster than allowed over the last 1h (and the last 5m), "+
+			"or at least 6 times faster than allowed over the last 6h (and the last 30m).",
+		page.Annotations["description"])
+
+	ticket := success.Rules[len(ruleWindows)+1]
+	assert.Equal(t, "ticket", ticket.Labels[SeverityLabel])
+	assert.Contains(t, ticket.Expr, `slo:function_calls_error:ratio_rate3d{objective_percentile="99.9"} > (1 * 0.001)`)
+	assert.Equal(t,
+		"The {{ $labels.objective_name }} objective (99.9%) is burning its error budget "+
+			"at least 3 times faster than allowed over the last 1d (and the last 2h), "+
+			"or at least 1 times faster than allowed over the last 3d (and the last 6h).",
+		ticket.Annotations["description"])
+
+	latency := file.Groups[1]
+	assert.Equal(t, "autometrics-latency-99", latency.Name)
+	assert.Equal(t, "slo:function_calls_slow:ratio_rate3d", latency.Rules[len(ruleWindows)-1].Record)
+	assert.Contains(t, latency.Rules[0].Expr, `label_join(rate(function_calls_duration_seconds_bucket{objective_percentile="99"}[5m]), "autometrics_check_label_equality", "", "objective_latency_threshold")`)
+	assert.Contains(t, latency.Rules[0].Expr, `label_join(rate(function_calls_duration_seconds_bucket{objective_percentile="99"}[5m]), "autometrics_check_label_equality", "", "le")`)
+	assert.Equal(t, "HighLatency", latency.Rules[len(ruleWindows)].Alert)
+}
@@ -0,0 +1,119 @@
+package autometrics // import "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+
+import (
+	"strings"
+
+	otel "github.com/autometrics-dev/autometrics-go/otel/autometrics"
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	prometheus "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// seriesNames are the names of the autometrics metrics and labels, as they are queried in Prometheus.
+type seriesNames struct {
+	callsCount      string
+	callsDuration   string
+	callsConcurrent string
+	buildInfo       string
+
+	functionLabel          string
+	moduleLabel            string
+	callerFunctionLabel    string
+	resultLabel            string
+	errorTypeLabel         string
+	serviceNameLabel       string
+	versionLabel           string
+	commitLabel            string
+	sloNameLabel           string
+	targetSuccessRateLabel string
+	targetLatencyLabel     string
+}
+
+var prometheusSeriesNames = seriesNames{
+	callsCount:      prometheus.FunctionCallsCountName,
+	callsDuration:   prometheus.FunctionCallsDurationName,
+	callsConcurrent: prometheus.FunctionCallsConcurrentName,
+	buildInfo:       prometheus.BuildInfoName,
+
+	functionLabel:          prometheus.FunctionLabel,
+	moduleLabel:            prometheus.ModuleLabel,
+	callerFunctionLabel:    prometheus.CallerFunctionLabel,
+	resultLabel:            prometheus.ResultLabel,
+	errorTypeLabel:         prometheus.ErrorTypeLabel,
+	serviceNameLabel:       prometheus.ServiceNameLabel,
+	versionLabel:           prometheus.VersionLabel,
+	commitLabel:            prometheus.CommitLabel,
+	sloNameLabel:           prometheus.SloNameLabel,
+	targetSuccessRateLabel: prometheus.TargetSuccessRateLabel,
+	targetLatencyLabel:     prometheus.TargetLatencyLabel,
+}
+
+// otelSeriesNames are the names of the OpenTelemetry metrics and attributes, once exported
+// by the OpenTelemetry Prometheus exporter.
+var otelSeriesNames = seriesNames{
+	callsCount:      otelPrometheusMetricName(otel.FunctionCallsCountName, true),
+	callsDuration:   otelPrometheusMetricName(otel.FunctionCallsDurationName, false),
+	callsConcurrent: otelPrometheusMetricName(otel.FunctionCallsConcurrentName, false),
+	buildInfo:       otelPrometheusMetricName(otel.BuildInfoName, false),
+
+	functionLabel:          otelPrometheusLabelName(otel.FunctionLabel),
+	moduleLabel:            otelPrometheusLabelName(otel.ModuleLabel),
+	callerFunctionLabel:    otelPrometheusLabelName(otel.CallerFunctionLabel),
+	resultLabel:            otelPrometheusLabelName(otel.ResultLabel),
+	errorTypeLabel:         otelPrometheusLabelName(otel.ErrorTypeLabel),
+	serviceNameLabel:       otelPrometheusLabelName(otel.ServiceNameLabel),
+	versionLabel:           otelPrometheusLabelName(otel.VersionLabel),
+	commitLabel:            otelPrometheusLabelName(otel.CommitLabel),
+	sloNameLabel:           otelPrometheusLabelName(otel.SloNameLabel),
+	targetSuccessRateLabel: otelPrometheusLabelName(otel.TargetSuccessRateLabel),
+	targetLatencyLabel:     otelPrometheusLabelName(otel.TargetLatencyLabel),
+}
+
+// seriesNamesFor returns the names of the series produced by the implementation.
+func seriesNamesFor(implementation autometrics.Implementation) seriesNames {
+	if implementation == autometrics.OTEL {
+		return otelSeriesNames
+	}
+
+	return prometheusSeriesNames
+}
+
+// otelPrometheusMetricName returns the name of the series of an OpenTelemetry metric, once
+// exported by the OpenTelemetry Prometheus exporter.
+//
+// Like the exporter, the invalid characters are replaced with underscores, and counters
+// (monotonic sums) get a "_total" suffix. The metrics of autometrics have no unit, so no
+// unit suffix is added.
+func otelPrometheusMetricName(name string, counter bool) string {
+	name = sanitizePrometheusName(name)
+	if counter {
+		name = strings.TrimSuffix(name, "_total") + "_total"
+	}
+
+	return name
+}
+
+// otelPrometheusLabelName returns the name of the label of an OpenTelemetry attribute, once
+// exported by the OpenTelemetry Prometheus exporter.
+func otelPrometheusLabelName(attribute string) string {
+	return sanitizePrometheusName(attribute)
+}
+
+// sanitizePrometheusName replaces the characters that are invalid in Prometheus names with
+// underscores, and prefixes a leading digit with an underscore, like the exporter does.
+func sanitizePrometheusName(name string) string {
+	var buf strings.Builder
+
+	for i, r := range name {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || r == ':' || (r >= '0' && r <= '9' && i > 0):
+			buf.WriteRune(r)
+		case r >= '0' && r <= '9':
+			buf.WriteRune('_')
+			buf.WriteRune(r)
+		default:
+			buf.WriteRune('_')
+		}
+	}
+
+	return buf.String()
+}
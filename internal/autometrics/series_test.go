@@ -0,0 +1,96 @@
+package autometrics
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+func TestOtelPrometheusMetricName(t *testing.T) {
+	tests := []struct {
+		name    string
+		counter bool
+		want    string
+	}{
+		{name: "function.calls", counter: true, want: "function_calls_total"},
+		{name: "function.calls_total", counter: true, want: "function_calls_total"},
+		{name: "function.calls.duration", want: "function_calls_duration"},
+		{name: "function.calls.concurrent", want: "function_calls_concurrent"},
+		{name: "0.ratio-of/things", want: "_0_ratio_of_things"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, otelPrometheusMetricName(test.name, test.counter),
+			"Unexpected series name for %s", test.name)
+	}
+
+	assert.Equal(t, "caller_function", otelPrometheusLabelName("caller.function"))
+	assert.Equal(t, "objective_latency_threshold", otelPrometheusLabelName("objective.latency_threshold"))
+}
+
+// TestOtelSeriesNames tests that the OpenTelemetry metrics, once exported to Prometheus, can
+// be queried with the same names as the metrics of the Prometheus implementation, except the
+// duration histogram which has no unit, and so no "_seconds" suffix.
+func TestOtelSeriesNames(t *testing.T) {
+	assert.Equal(t, "function_calls_duration", otelSeriesNames.callsDuration)
+
+	names := otelSeriesNames
+	names.callsDuration = prometheusSeriesNames.callsDuration
+	assert.Equal(t, prometheusSeriesNames, names)
+}
+
+// TestOtelDocumentationQueries tests that the documentation links of OpenTelemetry-instrumented code
+// query the series exported by the OpenTelemetry Prometheus exporter.
+func TestOtelDocumentationQueries(t *testing.T) {
+	ctx := GeneratorContext{Implementation: autometrics.OTEL, RuntimeCtx: DefaultRuntimeCtxInfo()}
+	lines := NewPrometheusDoc(url.URL{Scheme: "http", Host: "localhost:9090"}).GenerateAutometricsComment(ctx, "main", "main")
+
+	queries := make(map[string]string)
+	for _, line := range lines {
+		name, link, found := strings.Cut(strings.TrimPrefix(line, "// ["), "]: ")
+		if !found {
+			continue
+		}
+		parsed, err := url.Parse(link)
+		if err != nil {
+			t.Fatalf("error parsing the link %s: %s", link, err)
+		}
+		queries[name] = parsed.Query().Get("g0.expr")
+	}
+
+	assert.Contains(t, queries["Request Rate"], `function_calls_total{function="main"}`)
+	assert.Contains(t, queries["Latency (95th and 99th percentiles)"], `function_calls_duration_bucket{function="main"}`)
+	assert.Contains(t, queries["Concurrent Calls"], `function_calls_concurrent{function="main"}`)
+	assert.Contains(t, queries["Error Ratio Callee"], `function_calls_total{caller_function="main",result="error"}`)
+	assert.Contains(t, queries["Request Rate"], "sum by (function, module, service_name, version, commit)")
+}
+
+// TestErrorTypeDocumentationQuery tests that the breakdown of the error ratio by error type is only
+// linked when enabled.
+func TestErrorTypeDocumentationQuery(t *testing.T) {
+	ctx := GeneratorContext{Implementation: autometrics.PROMETHEUS, RuntimeCtx: DefaultRuntimeCtxInfo()}
+	doc := NewPrometheusDoc(url.URL{Scheme: "http", Host: "localhost:9090"})
+
+	for _, line := range doc.GenerateAutometricsComment(ctx, "main", "main") {
+		assert.NotContains(t, line, "Error Ratio by Error Type", "The breakdown should only be linked when enabled.")
+	}
+
+	ctx.ErrorTypes = true
+	var query string
+	for _, line := range doc.GenerateAutometricsComment(ctx, "main", "main") {
+		if name, link, found := strings.Cut(strings.TrimPrefix(line, "// ["), "]: "); found && name == "Error Ratio by Error Type" {
+			parsed, err := url.Parse(link)
+			if err != nil {
+				t.Fatalf("error parsing the link %s: %s", link, err)
+			}
+			query = parsed.Query().Get("g0.expr")
+		}
+	}
+
+	assert.Contains(t, query, `sum by (error_type, function, module, service_name, version, commit) (rate(function_calls_total{function="main",result="error"}[5m])`)
+	assert.Contains(t, query, "/ ignoring (error_type) group_left (sum by (function, module, service_name, version, commit) (rate(function_calls_total{function=\"main\"}[5m])")
+}
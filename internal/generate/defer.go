@@ -2,8 +2,9 @@ package generate // import "github.com/autometrics-dev/autometrics-go/internal/g
 
 import (
 	"fmt"
+	"go/ast"
+	"go/types"
 	"log"
-	"reflect"
 	"strings"
 
 	"golang.org/x/exp/slices"
@@ -20,17 +21,20 @@ const (
 	gin            = "github.com/gin-gonic/gin"
 	buffalo        = "github.com/gobuffalo/buffalo"
 	echoV4         = "github.com/labstack/echo/v4"
+	fasthttp       = "github.com/valyala/fasthttp"
+	fiberV2        = "github.com/gofiber/fiber/v2"
 	netHttp        = "net/http"
 )
 
 // injectDeferStatement add all the necessary information into context to produce the correct defer instrumentation statement.
 func injectDeferStatement(ctx *internal.GeneratorContext, funcDeclaration *dst.FuncDecl) error {
-	err := detectContext(ctx, funcDeclaration)
-	if err != nil {
-		return fmt.Errorf("failed to get context for tracing: %w", err)
+	if funcDeclaration.Body == nil {
+		return fmt.Errorf("cannot instrument %v: the function has no body", funcDeclaration.Name.Name)
 	}
-	firstStatement := funcDeclaration.Body.List[0]
-	variable, err := errorReturnValueName(funcDeclaration)
+
+	detectContext(ctx, funcDeclaration)
+
+	variable, customError, err := nameErrorResult(ctx, funcDeclaration)
 	if err != nil {
 		return fmt.Errorf("failed to get error return value name: %w", err)
 	}
@@ -41,19 +45,13 @@ func injectDeferStatement(ctx *internal.GeneratorContext, funcDeclaration *dst.F
 		variable = "&" + variable
 	}
 
-	autometricsDeferStatement, err := buildAutometricsDeferStatement(ctx, variable)
+	autometricsDeferStatement, err := buildAutometricsDeferStatement(ctx, variable, customError)
 	if err != nil {
 		return fmt.Errorf("failed to build the defer statement for instrumentation: %w", err)
 	}
 
-	if deferStatement, ok := firstStatement.(*dst.DeferStmt); ok {
-		decorations := deferStatement.Decorations().End
-
-		if slices.Contains(decorations.All(), "//autometrics:defer") {
-			funcDeclaration.Body.List[0] = &autometricsDeferStatement
-		} else {
-			funcDeclaration.Body.List = append([]dst.Stmt{&autometricsDeferStatement}, funcDeclaration.Body.List...)
-		}
+	if len(funcDeclaration.Body.List) > 0 && isAutometricsDeferStatement(funcDeclaration.Body.List[0]) {
+		funcDeclaration.Body.List[0] = &autometricsDeferStatement
 	} else {
 		funcDeclaration.Body.List = append([]dst.Stmt{&autometricsDeferStatement}, funcDeclaration.Body.List...)
 	}
@@ -62,41 +60,216 @@ func injectDeferStatement(ctx *internal.GeneratorContext, funcDeclaration *dst.F
 
 // removeDeferStatement removes, if detected, a previously injected defer statement.
 func removeDeferStatement(ctx *internal.GeneratorContext, funcDeclaration *dst.FuncDecl) error {
-	firstStatement := funcDeclaration.Body.List[0]
+	if funcDeclaration.Body == nil || len(funcDeclaration.Body.List) == 0 {
+		return nil
+	}
 
-	if deferStatement, ok := firstStatement.(*dst.DeferStmt); ok {
-		decorations := deferStatement.Decorations().End
-		if slices.Contains(decorations.All(), "//autometrics:defer") {
-			funcDeclaration.Body.List = funcDeclaration.Body.List[1:]
-		}
+	if isAutometricsDeferStatement(funcDeclaration.Body.List[0]) {
+		funcDeclaration.Body.List = funcDeclaration.Body.List[1:]
 	}
 
 	return nil
 }
 
+// isAutometricsDeferStatement returns true if the statement is a defer statement injected by autometrics.
+func isAutometricsDeferStatement(statement dst.Stmt) bool {
+	deferStatement, ok := statement.(*dst.DeferStmt)
+	if !ok {
+		return false
+	}
+
+	return slices.Contains(deferStatement.Decorations().End.All(), "//autometrics:defer")
+}
+
 // errorReturnValueName returns the name of the error return value if it exists.
-func errorReturnValueName(funcNode *dst.FuncDecl) (string, error) {
-	returnValues := funcNode.Type.Results
-	if returnValues == nil || returnValues.List == nil {
+func errorReturnValueName(ctx *internal.GeneratorContext, funcNode *dst.FuncDecl) (string, error) {
+	field, _ := errorResult(ctx, funcNode)
+	if field == nil {
 		return "", nil
 	}
 
+	// Assuming that the `error` type has 0 or 1 name before it.
+	if field.Names == nil {
+		return "", nil
+	} else if len(field.Names) > 1 {
+		return "", fmt.Errorf("expecting a single named `error` return value, got %d instead.", len(field.Names))
+	}
+	return field.Names[0].Name, nil
+}
+
+// errorResult returns the result field of the function that holds the returned error, or nil if there is none.
+// It also returns true if the type of the error is a custom error type instead of `error`.
+//
+// With the type information of the package (see [internal.GeneratorContext.ErrorResults]), any result whose type
+// implements error is found. Without it, the generator cannot check which types implement error, so a custom
+// error type is detected by its name: the last result is a custom error if its type name ends with "Error", like
+// *ValidationError or fs.PathError.
+func errorResult(ctx *internal.GeneratorContext, funcNode *dst.FuncDecl) (*dst.Field, bool) {
+	returnValues := funcNode.Type.Results
+	if returnValues == nil || len(returnValues.List) == 0 {
+		return nil, false
+	}
+
+	if ctx.ErrorResults != nil {
+		result, ok := ctx.ErrorResults[funcKey(funcNode)]
+		if !ok {
+			return nil, false
+		}
+
+		index := 0
+		for _, field := range returnValues.List {
+			index += max(len(field.Names), 1)
+			if result.Index < index {
+				return field, result.Custom
+			}
+		}
+		return nil, false
+	}
+
 	for _, field := range returnValues.List {
-		fieldType := field.Type
-		if spec, ok := fieldType.(*dst.Ident); ok {
-			if spec.Name == "error" {
-				// Assuming that the `error` type has 0 or 1 name before it.
-				if field.Names == nil {
-					return "", nil
-				} else if len(field.Names) > 1 {
-					return "", fmt.Errorf("expecting a single named `error` return value, got %d instead.", len(field.Names))
-				}
-				return field.Names[0].Name, nil
+		if spec, ok := field.Type.(*dst.Ident); ok && spec.Name == "error" {
+			return field, false
+		}
+	}
+
+	last := returnValues.List[len(returnValues.List)-1]
+	fieldType := last.Type
+	if pointer, ok := fieldType.(*dst.StarExpr); ok {
+		fieldType = pointer.X
+	}
+	var typeName string
+	switch fieldType := fieldType.(type) {
+	case *dst.Ident:
+		typeName = fieldType.Name
+	case *dst.SelectorExpr:
+		typeName = fieldType.Sel.Name
+	}
+	if strings.HasSuffix(typeName, "Error") {
+		return last, true
+	}
+
+	return nil, false
+}
+
+// funcKey returns the key of the function in [internal.GeneratorContext.ErrorResults].
+func funcKey(funcNode *dst.FuncDecl) string {
+	if funcNode.Recv == nil || len(funcNode.Recv.List) == 0 {
+		return funcNode.Name.Name
+	}
+	return receiverTypeName(funcNode.Recv.List[0].Type) + "." + funcNode.Name.Name
+}
+
+// ErrorResults returns the result holding the returned error of the functions declared in the file, using
+// the type information of its package, for [internal.GeneratorContext.ErrorResults].
+//
+// The error result of a function is its first result of type error, or else its last result whose type
+// implements error. The functions without error result are left out.
+func ErrorResults(file *ast.File, info *types.Info) map[string]internal.ErrorResult {
+	errorType := types.Universe.Lookup("error").Type()
+	errorInterface := errorType.Underlying().(*types.Interface)
+
+	results := make(map[string]internal.ErrorResult)
+	for _, decl := range file.Decls {
+		funcDeclaration, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		function, ok := info.Defs[funcDeclaration.Name].(*types.Func)
+		if !ok {
+			continue
+		}
+		signature := function.Type().(*types.Signature)
+
+		key := function.Name()
+		if recv := signature.Recv(); recv != nil {
+			recvType := recv.Type()
+			if pointer, ok := recvType.(*types.Pointer); ok {
+				recvType = pointer.Elem()
+			}
+			named, ok := recvType.(*types.Named)
+			if !ok {
+				continue
 			}
+			key = named.Obj().Name() + "." + key
+		}
+
+		result, found := internal.ErrorResult{}, false
+		for i := 0; i < signature.Results().Len(); i++ {
+			resultType := signature.Results().At(i).Type()
+			if types.Identical(resultType, errorType) {
+				result, found = internal.ErrorResult{Index: i}, true
+				break
+			}
+			if types.Implements(resultType, errorInterface) {
+				result, found = internal.ErrorResult{Index: i, Custom: true}, true
+			}
+		}
+		if found {
+			results[key] = result
 		}
 	}
 
-	return "", nil
+	return results
+}
+
+// nameErrorResult returns the name of the variable holding the returned error of the function, and true
+// if it has a custom error type (see [errorResult]). It returns an empty name if the function does not
+// return an error.
+//
+// To capture the returned error, unnamed results are named: the error result is named "err" (or another
+// name that is not used in the function), and the other results are named "_". An error result named "_"
+// is renamed too. Naming the results does not change what the function does, as the function has no
+// bare return statements in that case.
+func nameErrorResult(ctx *internal.GeneratorContext, funcNode *dst.FuncDecl) (string, bool, error) {
+	field, customError := errorResult(ctx, funcNode)
+	if field == nil {
+		return "", false, nil
+	}
+
+	if len(field.Names) == 1 && field.Names[0].Name == "_" {
+		field.Names[0].Name = unusedIdentifier(funcNode, "err")
+		return field.Names[0].Name, customError, nil
+	}
+
+	if field.Names != nil {
+		name, err := errorReturnValueName(ctx, funcNode)
+		return name, customError, err
+	}
+
+	name := unusedIdentifier(funcNode, "err")
+	for _, result := range funcNode.Type.Results.List {
+		resultName := "_"
+		if result == field {
+			resultName = name
+		}
+		result.Names = []*dst.Ident{dst.NewIdent(resultName)}
+	}
+	funcNode.Type.Results.Opening = true
+	funcNode.Type.Results.Closing = true
+
+	return name, customError, nil
+}
+
+// unusedIdentifier returns the name if no identifier of the function has it, or the name with the
+// autometrics prefix and a number otherwise.
+func unusedIdentifier(funcNode *dst.FuncDecl, name string) string {
+	used := make(map[string]bool)
+	dst.Inspect(funcNode, func(node dst.Node) bool {
+		if ident, ok := node.(*dst.Ident); ok {
+			used[ident.Name] = true
+		}
+		return true
+	})
+
+	candidate := name
+	for i := 0; used[candidate]; i++ {
+		candidate = fmt.Sprintf("autometrics%s%s", strings.ToUpper(name[:1]), name[1:])
+		if i > 0 {
+			candidate += fmt.Sprint(i)
+		}
+	}
+
+	return candidate
 }
 
 // buildAutometricsContextNode creates an AST node representing the runtime context to inject in the instrumented code.
@@ -107,6 +280,12 @@ func buildAutometricsContextNode(agc *internal.GeneratorContext) (*dst.CallExpr,
 
 	var options []string
 
+	if agc.FuncCtx.FunctionName != "" {
+		options = append(options, fmt.Sprintf("%vWithFunctionName(%#v)", autometricsNamespacePrefix(agc), agc.FuncCtx.FunctionName))
+	}
+	if agc.FuncCtx.QualifiedModuleName != "" {
+		options = append(options, fmt.Sprintf("%vWithModuleName(%#v)", autometricsNamespacePrefix(agc), agc.FuncCtx.QualifiedModuleName))
+	}
 	if agc.RuntimeCtx.TraceIDGetter != "" {
 		options = append(options, fmt.Sprintf("%vWithTraceID(%v)", autometricsNamespacePrefix(agc), agc.RuntimeCtx.TraceIDGetter))
 	}
@@ -125,9 +304,14 @@ func buildAutometricsContextNode(agc *internal.GeneratorContext) (*dst.CallExpr,
 			agc.RuntimeCtx.AlertConf.ServiceName,
 		))
 		if agc.RuntimeCtx.AlertConf.Latency != nil {
-			options = append(options, fmt.Sprintf("%vWithAlertLatency(%#v * time.Nanosecond, %#v)",
+			timeImportName := agc.FuncCtx.TimeImportName
+			if timeImportName == "" {
+				timeImportName = "time"
+			}
+			options = append(options, fmt.Sprintf("%vWithAlertLatency(%#v * %v.Nanosecond, %#v)",
 				autometricsNamespacePrefix(agc),
 				agc.RuntimeCtx.AlertConf.Latency.Target,
+				timeImportName,
 				agc.RuntimeCtx.AlertConf.Latency.Objective,
 			))
 		}
@@ -195,14 +379,20 @@ var dummy = %vNewContext(
 }
 
 // buildAutometricsDeferStatement builds the AST node for the defer instrumentation statement to be inserted.
-func buildAutometricsDeferStatement(ctx *internal.GeneratorContext, secondVar string) (dst.DeferStmt, error) {
+//
+// The defer statement calls InstrumentCustomError instead of Instrument if customError is true.
+func buildAutometricsDeferStatement(ctx *internal.GeneratorContext, secondVar string, customError bool) (dst.DeferStmt, error) {
 	preInstrumentArg, err := buildAutometricsContextNode(ctx)
 	if err != nil {
 		return dst.DeferStmt{}, fmt.Errorf("could not generate the runtime context value: %w", err)
 	}
+	instrumentFunction := "Instrument"
+	if customError {
+		instrumentFunction = "InstrumentCustomError"
+	}
 	statement := dst.DeferStmt{
 		Call: &dst.CallExpr{
-			Fun: dst.NewIdent(fmt.Sprintf("%vInstrument", autometricsNamespacePrefix(ctx))),
+			Fun: dst.NewIdent(fmt.Sprintf("%v%v", autometricsNamespacePrefix(ctx), instrumentFunction)),
 			Args: []dst.Expr{
 				&dst.CallExpr{
 					Fun: dst.NewIdent(fmt.Sprintf("%vPreInstrument", autometricsNamespacePrefix(ctx))),
@@ -233,7 +423,7 @@ func autometricsNamespacePrefix(ctx *internal.GeneratorContext) string {
 // detectContextIdentImpl is a Context detection logic helper for arguments whose type is an identifier
 //
 // The function returns true when it found enough information to ask for iteration to stop.
-func detectContextIdentImpl(ctx *internal.GeneratorContext, argName string, ident *dst.Ident) (bool, error) {
+func detectContextIdentImpl(ctx *internal.GeneratorContext, argName string, ident *dst.Ident) bool {
 	typeName := ident.Name
 	// If argType is just a dst.Ident when parsing, that means
 	// it is a single identifier ('Context', _not_ 'context.Context').
@@ -247,25 +437,20 @@ func detectContextIdentImpl(ctx *internal.GeneratorContext, argName string, iden
 			ctx.RuntimeCtx.ContextVariableName = argName
 			ctx.RuntimeCtx.SpanIDGetter = ""
 			ctx.RuntimeCtx.TraceIDGetter = ""
-			return true, nil
+			return true
 		}
 
 		if canonical == netHttp && typeName == "Request" {
-			if argName == "_" {
-				log.Println("Warning: an unnamed net/http.Request has been detected. To make Autometrics reuse its context for tracing purposes, please name it, and run 'go generate' again")
-				ctx.RuntimeCtx.ContextVariableName = "nil"
-			} else {
-				ctx.RuntimeCtx.ContextVariableName = fmt.Sprintf("%s.Context()", argName)
-			}
+			ctx.RuntimeCtx.ContextVariableName = fmt.Sprintf("%s.Context()", argName)
 			ctx.RuntimeCtx.SpanIDGetter = ""
 			ctx.RuntimeCtx.TraceIDGetter = ""
-			return true, nil
+			return true
 		}
 
 		if canonical == gin && typeName == "Context" {
 			ctx.RuntimeCtx.SpanIDGetter = fmt.Sprintf("%s.DecodeString(%s.GetString(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareSpanIDKey)
 			ctx.RuntimeCtx.TraceIDGetter = fmt.Sprintf("%s.DecodeString(%s.GetString(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareTraceIDKey)
-			return true, nil
+			return true
 		}
 
 		// Buffalo context embeds a context.Context so it can work like vanilla
@@ -273,112 +458,201 @@ func detectContextIdentImpl(ctx *internal.GeneratorContext, argName string, iden
 			ctx.RuntimeCtx.ContextVariableName = argName
 			ctx.RuntimeCtx.SpanIDGetter = ""
 			ctx.RuntimeCtx.TraceIDGetter = ""
-			return true, nil
+			return true
 		}
 
 		if canonical == echoV4 && typeName == "Context" {
 			ctx.RuntimeCtx.SpanIDGetter = fmt.Sprintf("%s.DecodeString(%s.Get(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareSpanIDKey)
 			ctx.RuntimeCtx.TraceIDGetter = fmt.Sprintf("%s.DecodeString(%s.Get(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareTraceIDKey)
-			return true, nil
+			return true
+		}
+
+		if canonical == fiberV2 && typeName == "Ctx" {
+			setStoredIDsContext(ctx, argName, fmt.Sprintf("%s.UserContext()", argName), "Locals")
+			return true
+		}
+
+		if canonical == fasthttp && typeName == "RequestCtx" {
+			setStoredIDsContext(ctx, argName, argName, "UserValue")
+			return true
 		}
 	}
 
-	return false, nil
+	return false
 }
 
-// detectContextIdentImpl is a Context detection logic helper for arguments whose type is a selector expression.
+// detectContextSelectorImpl is a Context detection logic helper for arguments whose type is a selector expression.
 //
 // The function returns true when it found enough information to ask for iteration to stop.
-func detectContextSelectorImpl(ctx *internal.GeneratorContext, argName string, selector *dst.SelectorExpr) (bool, error) {
+func detectContextSelectorImpl(ctx *internal.GeneratorContext, argName string, selector *dst.SelectorExpr) bool {
 	typeName := selector.Sel.Name
-	if parent, p_ok := selector.X.(*dst.Ident); p_ok {
-		parentName := parent.Name
-		for alias, canonical := range ctx.ImportsMap {
-			if canonical == vanillaContext && parentName == alias && typeName == "Context" {
-				ctx.RuntimeCtx.ContextVariableName = argName
-				ctx.RuntimeCtx.SpanIDGetter = ""
-				ctx.RuntimeCtx.TraceIDGetter = ""
-				return true, nil
-			}
+	parent, ok := selector.X.(*dst.Ident)
+	if !ok {
+		// Only qualified identifiers (package.Type) can be types
+		return false
+	}
 
-			if canonical == netHttp && parentName == alias && typeName == "Request" {
-				ctx.RuntimeCtx.ContextVariableName = fmt.Sprintf("%s.Context()", argName)
-				if argName == "_" {
-					log.Println("Warning: an unnamed net/http.Request has been detected. To make Autometrics reuse its context for tracing purposes, please name it, and run 'go generate' again")
-					ctx.RuntimeCtx.ContextVariableName = "nil"
-				} else {
-					ctx.RuntimeCtx.ContextVariableName = fmt.Sprintf("%s.Context()", argName)
-				}
-				ctx.RuntimeCtx.SpanIDGetter = ""
-				ctx.RuntimeCtx.TraceIDGetter = ""
-				return true, nil
-			}
+	parentName := parent.Name
+	for alias, canonical := range ctx.ImportsMap {
+		if canonical == vanillaContext && parentName == alias && typeName == "Context" {
+			ctx.RuntimeCtx.ContextVariableName = argName
+			ctx.RuntimeCtx.SpanIDGetter = ""
+			ctx.RuntimeCtx.TraceIDGetter = ""
+			return true
+		}
 
-			if canonical == gin && parentName == alias && typeName == "Context" {
-				ctx.RuntimeCtx.SpanIDGetter = fmt.Sprintf("%s.DecodeString(%s.GetString(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareSpanIDKey)
-				ctx.RuntimeCtx.TraceIDGetter = fmt.Sprintf("%s.DecodeString(%s.GetString(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareTraceIDKey)
-				return true, nil
-			}
+		if canonical == netHttp && parentName == alias && typeName == "Request" {
+			ctx.RuntimeCtx.ContextVariableName = fmt.Sprintf("%s.Context()", argName)
+			ctx.RuntimeCtx.SpanIDGetter = ""
+			ctx.RuntimeCtx.TraceIDGetter = ""
+			return true
+		}
 
-			// Buffalo context embeds a context.Context so it can work like vanilla
-			if canonical == buffalo && parentName == alias && typeName == "Context" {
-				ctx.RuntimeCtx.ContextVariableName = argName
-				ctx.RuntimeCtx.SpanIDGetter = ""
-				ctx.RuntimeCtx.TraceIDGetter = ""
-				return true, nil
-			}
+		if canonical == gin && parentName == alias && typeName == "Context" {
+			ctx.RuntimeCtx.SpanIDGetter = fmt.Sprintf("%s.DecodeString(%s.GetString(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareSpanIDKey)
+			ctx.RuntimeCtx.TraceIDGetter = fmt.Sprintf("%s.DecodeString(%s.GetString(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareTraceIDKey)
+			return true
+		}
+
+		// Buffalo context embeds a context.Context so it can work like vanilla
+		if canonical == buffalo && parentName == alias && typeName == "Context" {
+			ctx.RuntimeCtx.ContextVariableName = argName
+			ctx.RuntimeCtx.SpanIDGetter = ""
+			ctx.RuntimeCtx.TraceIDGetter = ""
+			return true
+		}
+
+		if canonical == echoV4 && typeName == "Context" && (parentName == alias || parentName == "echo") {
+			ctx.RuntimeCtx.SpanIDGetter = fmt.Sprintf("%s.DecodeString(%s.Get(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareSpanIDKey)
+			ctx.RuntimeCtx.TraceIDGetter = fmt.Sprintf("%s.DecodeString(%s.Get(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareTraceIDKey)
+			return true
+		}
+
+		if canonical == fiberV2 && parentName == alias && typeName == "Ctx" {
+			setStoredIDsContext(ctx, argName, fmt.Sprintf("%s.UserContext()", argName), "Locals")
+			return true
+		}
+
+		if canonical == fasthttp && parentName == alias && typeName == "RequestCtx" {
+			setStoredIDsContext(ctx, argName, argName, "UserValue")
+			return true
+		}
+	}
+
+	return false
+}
+
+// setStoredIDsContext sets the runtime context to use the contextVariable, and the IDs that the middlewares store
+// as untyped values of the argument, read with its accessor method: the locals of a *fiber.Ctx, or the user values
+// of a *fasthttp.RequestCtx (which implements context.Context).
+func setStoredIDsContext(ctx *internal.GeneratorContext, argName, contextVariable, accessor string) {
+	ctx.RuntimeCtx.ContextVariableName = contextVariable
+	ctx.RuntimeCtx.SpanIDGetter = fmt.Sprintf("%s.DecodeValue(%s.%s(%#v))", ctx.FuncCtx.ImplImportName, argName, accessor, am.MiddlewareSpanIDKey)
+	ctx.RuntimeCtx.TraceIDGetter = fmt.Sprintf("%s.DecodeValue(%s.%s(%#v))", ctx.FuncCtx.ImplImportName, argName, accessor, am.MiddlewareTraceIDKey)
+}
 
-			if canonical == echoV4 && typeName == "Context" && (parentName == alias || parentName == "echo") {
-				ctx.RuntimeCtx.SpanIDGetter = fmt.Sprintf("%s.DecodeString(%s.Get(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareSpanIDKey)
-				ctx.RuntimeCtx.TraceIDGetter = fmt.Sprintf("%s.DecodeString(%s.Get(%#v))", ctx.FuncCtx.ImplImportName, argName, am.MiddlewareTraceIDKey)
-				return true, nil
+// detectContextType is a Context detection logic helper for arguments of any type.
+//
+// Only the named types and the pointers to named types can be contexts. Other types, like function
+// types, maps, channels, arrays, slices or instantiated generic types, are never used as context.
+//
+// The function returns true when it found enough information to ask for iteration to stop.
+func detectContextType(ctx *internal.GeneratorContext, argName string, argType dst.Expr, typeParams map[string]dst.Expr) bool {
+	switch argType := argType.(type) {
+	case *dst.ParenExpr:
+		return detectContextType(ctx, argName, argType.X, typeParams)
+	case *dst.Ident:
+		if constraint, ok := typeParams[argType.Name]; ok {
+			return detectContextTypeParam(ctx, argName, constraint)
+		}
+		return detectContextIdentImpl(ctx, argName, argType)
+	case *dst.SelectorExpr:
+		return detectContextSelectorImpl(ctx, argName, argType)
+	case *dst.StarExpr:
+		switch pointedType := argType.X.(type) {
+		case *dst.Ident:
+			if _, ok := typeParams[pointedType.Name]; ok {
+				return false
 			}
+			return detectContextIdentImpl(ctx, argName, pointedType)
+		case *dst.SelectorExpr:
+			return detectContextSelectorImpl(ctx, argName, pointedType)
 		}
-	} else {
-		// TODO: log that autometrics cannot detect multi-nested contexts instead of errorring
-		// continue
-		return true, fmt.Errorf("expecting parent to be an identifier, got %s instead", reflect.TypeOf(selector.X).String())
 	}
-	return false, nil
+
+	return false
+}
+
+// detectContextTypeParam is a Context detection logic helper for arguments whose type is a type parameter.
+//
+// A type parameter is used as context only if its constraint is a type that is itself used as a context,
+// like context.Context, as the methods of other context types cannot be called on the type parameter.
+func detectContextTypeParam(ctx *internal.GeneratorContext, argName string, constraint dst.Expr) bool {
+	runtimeCtx := ctx.RuntimeCtx
+	if detectContextType(ctx, argName, constraint, nil) &&
+		ctx.RuntimeCtx.ContextVariableName == argName &&
+		ctx.RuntimeCtx.TraceIDGetter == "" &&
+		ctx.RuntimeCtx.SpanIDGetter == "" {
+		return true
+	}
+
+	ctx.RuntimeCtx = runtimeCtx
+	return false
 }
 
 // detectContext modifies a RuntimeCtxInfo to inject context when detected in the function signature.
-func detectContext(ctx *internal.GeneratorContext, funcDeclaration *dst.FuncDecl) error {
-	arguments := funcDeclaration.Type.Params.List
-	for _, argGroup := range arguments {
-		if len(argGroup.Names) > 1 {
-			continue
+//
+// The arguments are inspected in order, and the first one that can be used as a context is used. If
+// there is none, the instrumentation uses a nil context, and a warning is logged when a context
+// argument was found but cannot be used, because it is unnamed or variadic.
+func detectContext(ctx *internal.GeneratorContext, funcDeclaration *dst.FuncDecl) {
+	typeParams := make(map[string]dst.Expr)
+	if funcDeclaration.Type.TypeParams != nil {
+		for _, typeParamGroup := range funcDeclaration.Type.TypeParams.List {
+			for _, name := range typeParamGroup.Names {
+				typeParams[name.Name] = typeParamGroup.Type
+			}
 		}
-		argName := argGroup.Names[0].Name
+	}
+
+	runtimeCtx := ctx.RuntimeCtx
+	for _, argGroup := range funcDeclaration.Type.Params.List {
 		if argGroup.Type == nil {
 			continue
 		}
 
-		if argType, ok := argGroup.Type.(*dst.Ident); ok {
-			if found, err := detectContextIdentImpl(ctx, argName, argType); found {
-				return err
+		if variadic, ok := argGroup.Type.(*dst.Ellipsis); ok {
+			if detectContextType(ctx, "_", variadic.Elt, typeParams) {
+				log.Printf("Warning: the variadic context argument of %s cannot be used by Autometrics for tracing purposes, a nil context is used instead", funcDeclaration.Name.Name)
+				ctx.RuntimeCtx = runtimeCtx
 			}
-		} else if argType, ok := argGroup.Type.(*dst.SelectorExpr); ok {
-			if found, err := detectContextSelectorImpl(ctx, argName, argType); found {
-				return err
+			continue
+		}
+
+		// Unnamed arguments are in a single group without names
+		argNames := []string{"_"}
+		if len(argGroup.Names) > 0 {
+			argNames = argNames[:0]
+			for _, name := range argGroup.Names {
+				argNames = append(argNames, name.Name)
+			}
+		}
+
+		for _, argName := range argNames {
+			if !detectContextType(ctx, argName, argGroup.Type, typeParams) {
+				continue
 			}
-		} else if argType, ok := argGroup.Type.(*dst.StarExpr); ok {
-			if ident, ok := argType.X.(*dst.Ident); ok {
-				if found, err := detectContextIdentImpl(ctx, argName, ident); found {
-					return err
-				}
-			} else if selector, ok := argType.X.(*dst.SelectorExpr); ok {
-				if found, err := detectContextSelectorImpl(ctx, argName, selector); found {
-					return err
-				}
-			} else {
-				return fmt.Errorf("expecting the type being pointed to to be an identifier, got %s instead", reflect.TypeOf(argType.X).String())
+
+			if argName != "_" {
+				return
 			}
-		} else {
-			return fmt.Errorf("expecting the type of argGroup to be an identifier, got %s instead", reflect.TypeOf(argGroup.Type).String())
+
+			log.Printf("Warning: an unnamed context argument has been detected in %s. To make Autometrics reuse its context for tracing purposes, please name it, and run 'go generate' again", funcDeclaration.Name.Name)
+			ctx.RuntimeCtx = runtimeCtx
 		}
 	}
 
 	ctx.RuntimeCtx.ContextVariableName = "nil"
-	return nil
+	ctx.RuntimeCtx.SpanIDGetter = ""
+	ctx.RuntimeCtx.TraceIDGetter = ""
 }
@@ -44,6 +44,8 @@ func main(thisIsAContext context.Context) {
 		"func main(thisIsAContext context.Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tthisIsAContext,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
 		"\t\tprom.WithCallerName(true),\n" +
 		"\t\tprom.WithSloName(\"Service Test\"),\n" +
@@ -101,6 +103,8 @@ func main(thisIsAContext vanilla.Context) {
 		"func main(thisIsAContext vanilla.Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tthisIsAContext,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
 		"\t\tprom.WithCallerName(true),\n" +
 		"\t\tprom.WithSloName(\"Service Test\"),\n" +
@@ -158,6 +162,8 @@ func main(thisIsAContext Context) {
 		"func main(thisIsAContext Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tthisIsAContext,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
 		"\t\tprom.WithCallerName(true),\n" +
 		"\t\tprom.WithSloName(\"Service Test\"),\n" +
@@ -215,6 +221,8 @@ func main(w http.ResponseWriter, req *http.Request) {
 		"func main(w http.ResponseWriter, req *http.Request) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\treq.Context(),\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
 		"\t\tprom.WithCallerName(true),\n" +
 		"\t\tprom.WithSloName(\"Service Test\"),\n" +
@@ -272,6 +280,8 @@ func main(w vanilla.ResponseWriter, req *vanilla.Request) {
 		"func main(w vanilla.ResponseWriter, req *vanilla.Request) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\treq.Context(),\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
 		"\t\tprom.WithCallerName(true),\n" +
 		"\t\tprom.WithSloName(\"Service Test\"),\n" +
@@ -329,6 +339,8 @@ func main(w ResponseWriter, req *Request) {
 		"func main(w ResponseWriter, req *Request) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\treq.Context(),\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
 		"\t\tprom.WithCallerName(true),\n" +
 		"\t\tprom.WithSloName(\"Service Test\"),\n" +
@@ -386,6 +398,8 @@ func main(thisIsAContext buffalo.Context) {
 		"func main(thisIsAContext buffalo.Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tthisIsAContext,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
 		"\t\tprom.WithCallerName(true),\n" +
 		"\t\tprom.WithSloName(\"Service Test\"),\n" +
@@ -443,6 +457,8 @@ func main(thisIsAContext vanilla.Context) {
 		"func main(thisIsAContext vanilla.Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tthisIsAContext,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
 		"\t\tprom.WithCallerName(true),\n" +
 		"\t\tprom.WithSloName(\"Service Test\"),\n" +
@@ -500,6 +516,8 @@ func main(thisIsAContext Context) {
 		"func main(thisIsAContext Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tthisIsAContext,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
 		"\t\tprom.WithCallerName(true),\n" +
 		"\t\tprom.WithSloName(\"Service Test\"),\n" +
@@ -557,6 +575,8 @@ func main(thisIsAContext echo.Context) {
 		"func main(thisIsAContext echo.Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithTraceID(prom.DecodeString(thisIsAContext.Get(\"autometricsTraceID\"))),\n" +
 		"\t\tprom.WithSpanID(prom.DecodeString(thisIsAContext.Get(\"autometricsSpanID\"))),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
@@ -616,6 +636,8 @@ func main(thisIsAContext vanilla.Context) {
 		"func main(thisIsAContext vanilla.Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithTraceID(prom.DecodeString(thisIsAContext.Get(\"autometricsTraceID\"))),\n" +
 		"\t\tprom.WithSpanID(prom.DecodeString(thisIsAContext.Get(\"autometricsSpanID\"))),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
@@ -675,6 +697,8 @@ func main(thisIsAContext Context) {
 		"func main(thisIsAContext Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithTraceID(prom.DecodeString(thisIsAContext.Get(\"autometricsTraceID\"))),\n" +
 		"\t\tprom.WithSpanID(prom.DecodeString(thisIsAContext.Get(\"autometricsSpanID\"))),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
@@ -734,6 +758,8 @@ func main(thisIsAContext *gin.Context) {
 		"func main(thisIsAContext *gin.Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithTraceID(prom.DecodeString(thisIsAContext.GetString(\"autometricsTraceID\"))),\n" +
 		"\t\tprom.WithSpanID(prom.DecodeString(thisIsAContext.GetString(\"autometricsSpanID\"))),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
@@ -793,6 +819,8 @@ func main(thisIsAContext *vanilla.Context) {
 		"func main(thisIsAContext *vanilla.Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithTraceID(prom.DecodeString(thisIsAContext.GetString(\"autometricsTraceID\"))),\n" +
 		"\t\tprom.WithSpanID(prom.DecodeString(thisIsAContext.GetString(\"autometricsSpanID\"))),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
@@ -852,6 +880,8 @@ func main(thisIsAContext *Context) {
 		"func main(thisIsAContext *Context) {\n" +
 		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
 		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
 		"\t\tprom.WithTraceID(prom.DecodeString(thisIsAContext.GetString(\"autometricsTraceID\"))),\n" +
 		"\t\tprom.WithSpanID(prom.DecodeString(thisIsAContext.GetString(\"autometricsSpanID\"))),\n" +
 		"\t\tprom.WithConcurrentCalls(true),\n" +
@@ -875,3 +905,1132 @@ func main(thisIsAContext *Context) {
 
 	assert.Equal(t, want, actual, "The generated source code is not as expected.")
 }
+
+// TestFiberContext tests that autometrics correctly detects a *fiber.Ctx in
+// a function signature, when the import has a major version suffix.
+func TestFiberContext(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(thisIsAContext *fiber.Ctx) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"github.com/gofiber/fiber/v2\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(thisIsAContext *fiber.Ctx) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tthisIsAContext.UserContext(),\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithTraceID(prom.DecodeValue(thisIsAContext.Locals(\"autometricsTraceID\"))),\n" +
+		"\t\tprom.WithSpanID(prom.DecodeValue(thisIsAContext.Locals(\"autometricsSpanID\"))),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestFiberContextRenamed tests that autometrics correctly detects a *fiber.Ctx in
+// a function signature when the import is renamed.
+func TestFiberContextRenamed(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	vanilla "github.com/gofiber/fiber/v2"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(thisIsAContext *vanilla.Ctx) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\tvanilla \"github.com/gofiber/fiber/v2\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(thisIsAContext *vanilla.Ctx) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tthisIsAContext.UserContext(),\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithTraceID(prom.DecodeValue(thisIsAContext.Locals(\"autometricsTraceID\"))),\n" +
+		"\t\tprom.WithSpanID(prom.DecodeValue(thisIsAContext.Locals(\"autometricsSpanID\"))),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestFiberContextAnonymous tests that autometrics correctly detects a *fiber.Ctx in
+// a function signature when the import is anon.
+func TestFiberContextAnonymous(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	. "github.com/gofiber/fiber/v2"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(thisIsAContext *Ctx) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t. \"github.com/gofiber/fiber/v2\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(thisIsAContext *Ctx) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tthisIsAContext.UserContext(),\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithTraceID(prom.DecodeValue(thisIsAContext.Locals(\"autometricsTraceID\"))),\n" +
+		"\t\tprom.WithSpanID(prom.DecodeValue(thisIsAContext.Locals(\"autometricsSpanID\"))),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestFiberContextUnnamed tests that autometrics correctly detects an unnamed *fiber.Ctx in
+// a function signature, and falls back to a nil context.
+func TestFiberContextUnnamed(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(_ *fiber.Ctx) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"github.com/gofiber/fiber/v2\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(_ *fiber.Ctx) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestFasthttpContext tests that autometrics correctly detects a *fasthttp.RequestCtx in
+// a function signature.
+func TestFasthttpContext(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"github.com/valyala/fasthttp"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(thisIsAContext *fasthttp.RequestCtx) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"github.com/valyala/fasthttp\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(thisIsAContext *fasthttp.RequestCtx) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tthisIsAContext,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithTraceID(prom.DecodeValue(thisIsAContext.UserValue(\"autometricsTraceID\"))),\n" +
+		"\t\tprom.WithSpanID(prom.DecodeValue(thisIsAContext.UserValue(\"autometricsSpanID\"))),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestFasthttpContextAnonymous tests that autometrics correctly detects a *fasthttp.RequestCtx in
+// a function signature when the import is anon.
+func TestFasthttpContextAnonymous(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	. "github.com/valyala/fasthttp"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(thisIsAContext *RequestCtx) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t. \"github.com/valyala/fasthttp\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(thisIsAContext *RequestCtx) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tthisIsAContext,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithTraceID(prom.DecodeValue(thisIsAContext.UserValue(\"autometricsTraceID\"))),\n" +
+		"\t\tprom.WithSpanID(prom.DecodeValue(thisIsAContext.UserValue(\"autometricsSpanID\"))),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestGroupedContexts tests that autometrics uses the first context of a group of arguments
+// sharing the same type.
+func TestGroupedContexts(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(parent, thisIsAContext context.Context) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"context\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(parent, thisIsAContext context.Context) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tparent,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestUnnamedArguments tests that autometrics falls back to a nil context when the arguments
+// are unnamed.
+func TestUnnamedArguments(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(context.Context, int) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"context\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(context.Context, int) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestBlankContext tests that autometrics skips a context argument named '_', and uses
+// the next usable context.
+func TestBlankContext(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+	"net/http"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(_ context.Context, thisIsARequest *http.Request) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"context\"\n" +
+		"\t\"net/http\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(_ context.Context, thisIsARequest *http.Request) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tthisIsARequest.Context(),\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestVariadicContexts tests that autometrics falls back to a nil context when the contexts
+// are variadic arguments.
+func TestVariadicContexts(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(thisIsAContext ...context.Context) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"context\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(thisIsAContext ...context.Context) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestArgumentShapes tests that autometrics skips the arguments that cannot be contexts,
+// whatever their type, before finding a context.
+func TestArgumentShapes(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+	"net/http"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(callback func(context.Context) error, values map[string]context.Context, done chan context.Context, contexts [2]context.Context, requests []*http.Request, pointer **http.Request, anything interface{ Done() <-chan struct{} }, thisIsAContext context.Context) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"context\"\n" +
+		"\t\"net/http\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(callback func(context.Context) error, values map[string]context.Context, done chan context.Context, contexts [2]context.Context, requests []*http.Request, pointer **http.Request, anything interface{ Done() <-chan struct{} }, thisIsAContext context.Context) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tthisIsAContext,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestGenericArguments tests that autometrics skips instantiated generic types and type parameters,
+// before finding a context.
+func TestGenericArguments(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+	"github.com/example/generic"
+	"net/http"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main[T any, L generic.List[T]](value T, list L, pairs generic.Map[string, context.Context], items generic.List[*http.Request], thisIsARequest *http.Request) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"context\"\n" +
+		"\t\"github.com/example/generic\"\n" +
+		"\t\"net/http\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main[T any, L generic.List[T]](value T, list L, pairs generic.Map[string, context.Context], items generic.List[*http.Request], thisIsARequest *http.Request) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tthisIsARequest.Context(),\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestContextTypeParameter tests that autometrics uses an argument whose type is a type parameter
+// constrained by context.Context.
+func TestContextTypeParameter(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main[T any, C context.Context](value T, thisIsAContext C) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"context\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main[T any, C context.Context](value T, thisIsAContext C) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tthisIsAContext,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestNoContext tests that autometrics falls back to a nil context when there is no
+// context in the arguments.
+func TestNoContext(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"net/http"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --no-doc --slo "Service Test" --success-target 99
+func main(writer http.ResponseWriter, code int) {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	want := "// This is the package comment.\n" +
+		"package main\n" +
+		"\n" +
+		"import (\n" +
+		"\t\"net/http\"\n" +
+		"\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"// This comment is associated with the main function.\n" +
+		"//\n" +
+		"//autometrics:inst --no-doc --slo \"Service Test\" --success-target 99\n" +
+		"func main(writer http.ResponseWriter, code int) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"main\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t\tprom.WithSloName(\"Service Test\"),\n" +
+		"\t\tprom.WithAlertSuccess(99),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\tfmt.Println(hello) // line comment 3\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+func TestUnnamedErrorResult(t *testing.T) {
+	sourceCode := `package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+//autometrics:inst --no-doc
+func count(items []string) (int, error) {
+	return len(items), nil
+}
+`
+
+	want := "package main\n" +
+		"\n" +
+		"import (\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"//autometrics:inst --no-doc\n" +
+		"func count(items []string) (_ int, err error) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"count\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t)), &err) //autometrics:defer\n" +
+		"\n" +
+		"\treturn len(items), nil\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+func TestSingleUnnamedErrorResult(t *testing.T) {
+	sourceCode := `package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+//autometrics:inst --no-doc
+func check() error {
+	return nil
+}
+`
+
+	want := "package main\n" +
+		"\n" +
+		"import (\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"//autometrics:inst --no-doc\n" +
+		"func check() (err error) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"check\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t)), &err) //autometrics:defer\n" +
+		"\n" +
+		"\treturn nil\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+func TestUnnamedErrorResultNameCollision(t *testing.T) {
+	sourceCode := `package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+//autometrics:inst --no-doc
+func parse(input string) (string, error) {
+	value, err := strconv.Unquote(input)
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+`
+
+	want := "package main\n" +
+		"\n" +
+		"import (\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"//autometrics:inst --no-doc\n" +
+		"func parse(input string) (_ string, autometricsErr error) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"parse\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t)), &autometricsErr) //autometrics:defer\n" +
+		"\n" +
+		"\tvalue, err := strconv.Unquote(input)\n" +
+		"\tif err != nil {\n" +
+		"\t\treturn \"\", err\n" +
+		"\t}\n" +
+		"\treturn value, nil\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+func TestBlankErrorResult(t *testing.T) {
+	sourceCode := `package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+//autometrics:inst --no-doc
+func check() (_ error) {
+	return nil
+}
+`
+
+	want := "package main\n" +
+		"\n" +
+		"import (\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"//autometrics:inst --no-doc\n" +
+		"func check() (err error) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"check\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t)), &err) //autometrics:defer\n" +
+		"\n" +
+		"\treturn nil\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestCustomErrorResult tests that, without type information, the last result is captured
+// with InstrumentCustomError when the name of its type ends with "Error".
+func TestCustomErrorResult(t *testing.T) {
+	sourceCode := `package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+type ValidationError struct {
+	Field string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + " is invalid"
+}
+
+type NotFound struct {
+	Key string
+}
+
+func (e *NotFound) Error() string {
+	return e.Key + " not found"
+}
+
+//autometrics:inst --no-doc
+func validate(input string) (int, *ValidationError) {
+	return len(input), nil
+}
+
+//autometrics:inst --no-doc
+func lookup(key string) (string, *NotFound) {
+	return key, nil
+}
+`
+
+	want := "package main\n" +
+		"\n" +
+		"import (\n" +
+		"\tprom \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		")\n" +
+		"\n" +
+		"type ValidationError struct {\n" +
+		"\tField string\n" +
+		"}\n" +
+		"\n" +
+		"func (e *ValidationError) Error() string {\n" +
+		"\treturn e.Field + \" is invalid\"\n" +
+		"}\n" +
+		"\n" +
+		"type NotFound struct {\n" +
+		"\tKey string\n" +
+		"}\n" +
+		"\n" +
+		"func (e *NotFound) Error() string {\n" +
+		"\treturn e.Key + \" not found\"\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:inst --no-doc\n" +
+		"func validate(input string) (_ int, err *ValidationError) {\n" +
+		"\tdefer prom.InstrumentCustomError(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"validate\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t)), &err) //autometrics:defer\n" +
+		"\n" +
+		"\treturn len(input), nil\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:inst --no-doc\n" +
+		"func lookup(key string) (string, *NotFound) {\n" +
+		"\tdefer prom.Instrument(prom.PreInstrument(prom.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tprom.WithFunctionName(\"lookup\"),\n" +
+		"\t\tprom.WithModuleName(\"main\"),\n" +
+		"\t\tprom.WithConcurrentCalls(true),\n" +
+		"\t\tprom.WithCallerName(true),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\treturn key, nil\n" +
+		"}\n"
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The custom error types should be detected by their names without type information.")
+}
@@ -45,18 +45,20 @@ func cleanUpAutometricsComments(ctx internal.GeneratorContext, funcDeclaration *
 			amCommentSectionEnd := int(math.Min(float64(len(docComments)), float64(oldEndCommentIndex+2)))
 			docComments = append(docComments[:amCommentSectionStart], docComments[amCommentSectionEnd:]...)
 
-			// Remove the generated links from former passes
+			// Remove the generated links from former passes. The links of all the known
+			// generators are removed, as a former pass may have used another generator.
+			generatedLinks := internal.KnownGeneratedLinks()
 			if ctx.DocumentationGenerator != nil {
-				generatedLinks := ctx.DocumentationGenerator.GeneratedLinks()
-				docComments = filter(docComments, func(input string) bool {
-					for _, link := range generatedLinks {
-						if strings.Contains(input, fmt.Sprintf("[%s]", link)) {
-							return false
-						}
-					}
-					return true
-				})
+				generatedLinks = append(generatedLinks, ctx.DocumentationGenerator.GeneratedLinks()...)
 			}
+			docComments = filter(docComments, func(input string) bool {
+				for _, link := range generatedLinks {
+					if strings.Contains(input, fmt.Sprintf("[%s]", link)) {
+						return false
+					}
+				}
+				return true
+			})
 		}
 	}
 
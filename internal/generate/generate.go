@@ -33,35 +33,86 @@ const (
 //
 // It also replaces the file in place.
 func TransformFile(ctx internal.GeneratorContext, path, moduleName string) error {
+	_, err := transformFile(ctx, path, moduleName, false)
+	return err
+}
+
+// CheckFile runs the transformation of [TransformFile] in memory, without writing the file.
+//
+// The returned report is marked as modified if the file is not up-to-date with its directives,
+// and then contains the unified diff between the file and what [TransformFile] would write.
+func CheckFile(ctx internal.GeneratorContext, path, moduleName string) (FileReport, error) {
+	return transformFile(ctx, path, moduleName, true)
+}
+
+// transformFile replaces the file in place with its transformed version, and reports the changes made.
+//
+// In dry-run mode, the file is left untouched and the report contains the diff of the changes instead.
+func transformFile(ctx internal.GeneratorContext, path, moduleName string, dryRun bool) (FileReport, error) {
+	if ctx.PackagePath == "" {
+		ctx.PackagePath = fileImportPath(path)
+	}
+
+	return rewriteFile(path, dryRun, func(sourceCode string) (string, int, error) {
+		transformedSource, instrumented, err := generateDocumentationAndInstrumentation(ctx, sourceCode, moduleName)
+		if err != nil {
+			return "", 0, fmt.Errorf("error generating documentation: %w", err)
+		}
+		return transformedSource, instrumented, nil
+	})
+}
+
+// rewriteFile replaces the file in place with the source code returned by rewrite, and reports the
+// changes made. rewrite also returns the number of functions to report (see [FileReport]).
+//
+// In dry-run mode, the file is left untouched and the report contains the diff of the changes instead.
+func rewriteFile(path string, dryRun bool, rewrite func(sourceCode string) (string, int, error)) (FileReport, error) {
+	report := FileReport{Path: path}
+
 	cwd, err := os.Getwd()
 	if err != nil {
-		return fmt.Errorf("error getting a working directory: %w", err)
+		return report, fmt.Errorf("error getting a working directory: %w", err)
 	}
 
 	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("error reading file information from %s: %w", path, err)
+		return report, fmt.Errorf("error reading file information from %s: %w", path, err)
 	}
 
 	permissions := info.Mode()
 
 	sourceBytes, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("error reading the source code from %s (cwd: %s): %w", path, cwd, err)
+		return report, fmt.Errorf("error reading the source code from %s (cwd: %s): %w", path, cwd, err)
 	}
 
 	sourceCode := string(sourceBytes)
-	transformedSource, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, moduleName)
+	transformedSource, instrumented, err := rewrite(sourceCode)
 	if err != nil {
-		return fmt.Errorf("error generating documentation: %w", err)
+		return report, err
+	}
+
+	report.InstrumentedFunctions = instrumented
+	report.Modified = transformedSource != sourceCode
+
+	if !report.Modified {
+		return report, nil
+	}
+
+	if dryRun {
+		report.Diff, err = unifiedDiff(path, sourceCode, transformedSource)
+		if err != nil {
+			return report, fmt.Errorf("error computing the diff: %w", err)
+		}
+		return report, nil
 	}
 
 	err = os.WriteFile(path, []byte(transformedSource), permissions)
 	if err != nil {
-		return fmt.Errorf("error writing file: %w", err)
+		return report, fmt.Errorf("error writing file: %w", err)
 	}
 
-	return nil
+	return report, nil
 }
 
 // GenerateDocumentationAndInstrumentation takes the raw source code from a file and generates
@@ -69,35 +120,35 @@ func TransformFile(ctx internal.GeneratorContext, path, moduleName string) error
 //
 // It returns the new source code with augmented documentation.
 func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sourceCode, moduleName string) (string, error) {
+	transformedSource, _, err := generateDocumentationAndInstrumentation(ctx, sourceCode, moduleName)
+	return transformedSource, err
+}
+
+// generateDocumentationAndInstrumentation is [GenerateDocumentationAndInstrumentation], that also returns
+// the number of functions that have an autometrics directive.
+func generateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sourceCode, moduleName string) (string, int, error) {
 	fileTree, err := decorator.Parse(sourceCode)
 	if err != nil {
-		return "", fmt.Errorf("error parsing source code: %w", err)
+		return "", 0, fmt.Errorf("error parsing source code: %w", err)
 	}
 
 	var inspectErr error
-	var foundAmImport bool
-
-	for _, importSpec := range fileTree.Imports {
-		foundAmImport = inspectImportSpec(&ctx, importSpec)
-		if foundAmImport {
-			break
-		}
-	}
+	var instrumented int
 
-	if !foundAmImport {
-		err = addAutometricsImport(&ctx, fileTree)
-		if err != nil {
-			return "", fmt.Errorf("error adding the autometrics import: %w", err)
-		}
-	}
+	usedBefore := usedImportNames(fileTree)
+	foundAmImport, foundTimeImport := inspectImports(&ctx, fileTree)
 
 	if ctx.FuncCtx.ImplImportName == "" {
-		return "", errors.New("assertion error: ctx.FuncCtx.ImplImportName is empty just before filewalking")
+		return "", 0, errors.New("assertion error: ctx.FuncCtx.ImplImportName is empty just before filewalking")
 	}
 
 	fileWalk := func(node dst.Node) bool {
 		if funcDeclaration, ok := node.(*dst.FuncDecl); ok {
-			inspectErr = walkFuncDeclaration(&ctx, funcDeclaration, moduleName)
+			var hasDirective bool
+			hasDirective, inspectErr = walkFuncDeclaration(&ctx, funcDeclaration, moduleName)
+			if hasDirective {
+				instrumented++
+			}
 		}
 
 		if inspectErr != nil {
@@ -110,33 +161,87 @@ func GenerateDocumentationAndInstrumentation(ctx internal.GeneratorContext, sour
 	dst.Inspect(fileTree, fileWalk)
 
 	if inspectErr != nil {
-		return "", fmt.Errorf("error while transforming file in %v: %w", moduleName, inspectErr)
+		return "", 0, fmt.Errorf("error while transforming file in %v: %w", moduleName, inspectErr)
 	}
 
+	usedAfter := usedImportNames(fileTree)
+	if !foundAmImport && usedAfter[ctx.FuncCtx.ImplImportName] > 0 {
+		err = addAutometricsImport(&ctx, fileTree)
+		if err != nil {
+			return "", 0, fmt.Errorf("error adding the autometrics import: %w", err)
+		}
+	}
+	if !foundTimeImport && usedAfter[ctx.FuncCtx.TimeImportName] > 0 {
+		addImport(fileTree, ctx.FuncCtx.TimeImportName, "time")
+	}
+	// The generated code of a former pass might have been the only user of an import
+	removeUnusedImports(fileTree, usedBefore)
+
 	var buf strings.Builder
 
 	err = decorator.Fprint(&buf, fileTree)
 	if err != nil {
-		return "", fmt.Errorf("error writing the AST to buffer: %w", err)
+		return "", 0, fmt.Errorf("error writing the AST to buffer: %w", err)
+	}
+
+	return buf.String(), instrumented, nil
+}
+
+// qualifiedModuleName returns the module name of the function as the instrumented code reports it at runtime:
+// the path of the package, followed by the receiver type name for methods.
+//
+// It returns an empty string if the path of the package is unknown.
+func qualifiedModuleName(packagePath, packageName string, funcDeclaration *dst.FuncDecl) string {
+	modulePath := runtimePackagePath(packagePath, packageName)
+	if modulePath == "" {
+		return ""
+	}
+	if funcDeclaration.Recv == nil || len(funcDeclaration.Recv.List) == 0 {
+		return modulePath
 	}
 
-	return buf.String(), nil
+	receiver := receiverTypeName(funcDeclaration.Recv.List[0].Type)
+	if receiver == "" {
+		return ""
+	}
+	return modulePath + "." + receiver
+}
+
+// receiverTypeName returns the name of the type of a method receiver, without the pointer and the type parameters.
+func receiverTypeName(receiverType dst.Expr) string {
+	switch receiverType := receiverType.(type) {
+	case *dst.Ident:
+		return receiverType.Name
+	case *dst.StarExpr:
+		return receiverTypeName(receiverType.X)
+	case *dst.ParenExpr:
+		return receiverTypeName(receiverType.X)
+	case *dst.IndexExpr:
+		return receiverTypeName(receiverType.X)
+	case *dst.IndexListExpr:
+		return receiverTypeName(receiverType.X)
+	}
+
+	return ""
 }
 
 // walkFuncDeclaration uses the context to generate documentation and code if necessary for a function declaration in a file.
-func walkFuncDeclaration(ctx *internal.GeneratorContext, funcDeclaration *dst.FuncDecl, moduleName string) error {
+//
+// It returns true if the function has an autometrics directive.
+func walkFuncDeclaration(ctx *internal.GeneratorContext, funcDeclaration *dst.FuncDecl, moduleName string) (bool, error) {
 	if ctx.FuncCtx.ImplImportName == "" {
 		if ctx.Implementation == autometrics.PROMETHEUS {
-			return fmt.Errorf("the source file is missing a %v import", AmPromPackage)
+			return false, fmt.Errorf("the source file is missing a %v import", AmPromPackage)
 		} else if ctx.Implementation == autometrics.OTEL {
-			return fmt.Errorf("the source file is missing a %v import", AmOtelPackage)
+			return false, fmt.Errorf("the source file is missing a %v import", AmOtelPackage)
 		} else {
-			return fmt.Errorf("unknown implementation of metrics has been queried")
+			return false, fmt.Errorf("unknown implementation of metrics has been queried")
 		}
 	}
 
 	ctx.FuncCtx.FunctionName = funcDeclaration.Name.Name
 	ctx.FuncCtx.ModuleName = moduleName
+	ctx.FuncCtx.QualifiedModuleName = qualifiedModuleName(ctx.PackagePath, moduleName, funcDeclaration)
 
 	defer ctx.ResetFuncCtx()
 
@@ -144,12 +249,12 @@ func walkFuncDeclaration(ctx *internal.GeneratorContext, funcDeclaration *dst.Fu
 	// Clean up old autometrics comments
 	docComments, err := cleanUpAutometricsComments(*ctx, funcDeclaration)
 	if err != nil {
-		return fmt.Errorf("error trying to remove autometrics comment from former pass: %w", err)
+		return false, fmt.Errorf("error trying to remove autometrics comment from former pass: %w", err)
 	}
 
 	err = removeDeferStatement(ctx, funcDeclaration)
 	if err != nil {
-		return fmt.Errorf(
+		return false, fmt.Errorf(
 			"error removing an older autometrics defer statement in %v: %w",
 			funcDeclaration.Name.Name,
 			err)
@@ -158,7 +263,7 @@ func walkFuncDeclaration(ctx *internal.GeneratorContext, funcDeclaration *dst.Fu
 	// Detect autometrics directive
 	err = parseAutometricsFnContext(ctx, docComments)
 	if err != nil {
-		return fmt.Errorf(
+		return false, fmt.Errorf(
 			"failed to parse //autometrics directive for %v: %w",
 			funcDeclaration.Name.Name,
 			err)
@@ -189,10 +294,12 @@ func walkFuncDeclaration(ctx *internal.GeneratorContext, funcDeclaration *dst.Fu
 		// defer statement
 		err := injectDeferStatement(ctx, funcDeclaration)
 		if err != nil {
-			return fmt.Errorf("failed to inject defer statement: %w", err)
+			return false, fmt.Errorf("failed to inject defer statement: %w", err)
 		}
+
+		return true, nil
 	}
-	return nil
+	return false, nil
 }
 
 // parseAutometricsFnContext modifies the GeneratorContext according to the arguments put in the directive.
@@ -203,13 +310,15 @@ func parseAutometricsFnContext(ctx *internal.GeneratorContext, commentGroup []st
 				return fmt.Errorf("invalid directive comment '%s': only '//autometrics:doc' and '//autometrics:inst' are allowed.", comment)
 			}
 			ctx.FuncCtx.CommentIndex = i
+			ctx.FuncCtx.UnknownArguments = nil
 			ctx.RuntimeCtx = internal.DefaultRuntimeCtxInfo()
 
 			tokens, err := shlex.Split(args)
 			if err != nil {
 				return fmt.Errorf("could not parse the directive arguments: %w", err)
 			}
-			tokenIndex := 0
+			// The first token is the name of the directive
+			tokenIndex := 1
 			for tokenIndex < len(tokens) {
 				token := tokens[tokenIndex]
 				switch {
@@ -237,11 +346,12 @@ func parseAutometricsFnContext(ctx *internal.GeneratorContext, commentGroup []st
 					ctx.FuncCtx.DisableDocGeneration = true
 					tokenIndex = tokenIndex + 1
 				default:
-					// Advance past the "value"
+					// Unknown arguments are ignored, the linter reports them.
+					ctx.FuncCtx.UnknownArguments = append(ctx.FuncCtx.UnknownArguments, token)
 					tokenIndex = tokenIndex + 1
 				}
 			}
-			err = ctx.RuntimeCtx.Validate(ctx.AllowCustomLatencies)
+			err = ctx.RuntimeCtx.Validate(ctx.AllowCustomLatencies, ctx.Buckets, ctx.Objectives)
 			if err != nil {
 				return fmt.Errorf("parsed configuration is invalid: %w", err)
 			}
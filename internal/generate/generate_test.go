@@ -71,6 +71,8 @@ import (
 func main() {
 	defer prom.Instrument(prom.PreInstrument(prom.NewContext(
 		nil,
+		prom.WithFunctionName("main"),
+		prom.WithModuleName("main"),
 		prom.WithConcurrentCalls(true),
 		prom.WithCallerName(true),
 		prom.WithSloName("Service Test"),
@@ -150,6 +152,8 @@ import (
 func main() {
 	defer prom.Instrument(prom.PreInstrument(prom.NewContext(
 		nil,
+		prom.WithFunctionName("main"),
+		prom.WithModuleName("main"),
 		prom.WithConcurrentCalls(true),
 		prom.WithCallerName(true),
 		prom.WithSloName("Service Test"),
@@ -201,6 +205,8 @@ import (
 func main() {
 	defer prom.Instrument(prom.PreInstrument(prom.NewContext(
 		nil,
+		prom.WithFunctionName("main"),
+		prom.WithModuleName("main"),
 		prom.WithConcurrentCalls(true),
 		prom.WithCallerName(true),
 		prom.WithSloName("Service Test"),
@@ -249,6 +255,8 @@ import (
 func main() {
 	defer prom.Instrument(prom.PreInstrument(prom.NewContext(
 		nil,
+		prom.WithFunctionName("main"),
+		prom.WithModuleName("main"),
 		prom.WithConcurrentCalls(true),
 		prom.WithCallerName(true),
 		prom.WithSloName("Service Test"),
@@ -301,6 +309,8 @@ func main() {
 package main
 
 import (
+	"time"
+
 	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
 )
 
@@ -335,6 +345,8 @@ import (
 func main() {
 	defer prom.Instrument(prom.PreInstrument(prom.NewContext(
 		nil,
+		prom.WithFunctionName("main"),
+		prom.WithModuleName("main"),
 		prom.WithConcurrentCalls(true),
 		prom.WithCallerName(true),
 		prom.WithSloName("API"),
@@ -358,6 +370,62 @@ func main() {
 	assert.Equal(t, want, actual, "The generated source code is not as expected.")
 }
 
+// TestCommentRefreshSwitchGenerator calls GenerateDocumentationAndInstrumentation twice with
+// different documentation generators, making sure that the links of the first pass are removed.
+func TestCommentRefreshSwitchGenerator(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst
+func main() {
+	fmt.Println(hello) // line comment 3
+}
+`
+
+	promCtx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	grafanaCtx, err := internal.NewGeneratorContextFromConfig(internal.Config{
+		PrometheusUrl:        defaultPrometheusInstanceUrl,
+		GrafanaUrl:           "https://grafana.example.com/",
+		GrafanaDatasourceUID: "prometheus",
+		GrafanaOrgID:         1,
+	}, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	prometheusSource, err := GenerateDocumentationAndInstrumentation(promCtx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	grafanaSource, err := GenerateDocumentationAndInstrumentation(grafanaCtx, prometheusSource, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Contains(t, grafanaSource, "// # Grafana")
+	assert.NotContains(t, grafanaSource, "// # Prometheus")
+	assert.NotContains(t, grafanaSource, "localhost:9090", "The links of the first pass must be removed.")
+	assert.Equal(t, 1, strings.Count(grafanaSource, "// [Request Rate]: https://grafana.example.com/explore?"))
+
+	roundTrip, err := GenerateDocumentationAndInstrumentation(promCtx, grafanaSource, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, prometheusSource, roundTrip, "Switching back to the first generator must give the same source code.")
+}
+
 // TestCommentAddImport calls GenerateDocumentationAndInstrumentation on a
 // decorated function, making sure that the autometrics import is automatically added.
 func TestCommentAddImport(t *testing.T) {
@@ -375,7 +443,11 @@ func main() {
 	want := `// This is the package comment.
 package main
 
-import "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+import (
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
 
 // This comment is associated with the main function.
 //
@@ -383,6 +455,8 @@ import "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
 func main() {
 	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
 		nil,
+		autometrics.WithFunctionName("main"),
+		autometrics.WithModuleName("main"),
 		autometrics.WithConcurrentCalls(true),
 		autometrics.WithCallerName(true),
 		autometrics.WithSloName("API"),
@@ -432,6 +506,7 @@ import (
 	"fmt"
 	"github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
 	"strings"
+	"time"
 )
 
 // This comment is associated with the main function.
@@ -440,6 +515,8 @@ import (
 func main() {
 	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
 		nil,
+		autometrics.WithFunctionName("main"),
+		autometrics.WithModuleName("main"),
 		autometrics.WithConcurrentCalls(true),
 		autometrics.WithCallerName(true),
 		autometrics.WithSloName("API"),
@@ -490,13 +567,19 @@ func main() {
 	want := `// This is the package comment.
 package main
 
-import "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+import (
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
 
 // This comment is associated with the main function.
 //autometrics:inst --no-doc --slo "API" --latency-target 99.9 --latency-ms 500
 func main() {
 	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
 		nil,
+		autometrics.WithFunctionName("main"),
+		autometrics.WithModuleName("main"),
 		autometrics.WithConcurrentCalls(true),
 		autometrics.WithCallerName(true),
 		autometrics.WithSloName("API"),
@@ -551,6 +634,8 @@ func main() {
 package main
 
 import (
+	"time"
+
 	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
 	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
 )
@@ -561,6 +646,8 @@ import (
 func main() {
 	defer prom.Instrument(prom.PreInstrument(prom.NewContext(
 		nil,
+		prom.WithFunctionName("main"),
+		prom.WithModuleName("main"),
 		prom.WithConcurrentCalls(true),
 		prom.WithCallerName(true),
 		prom.WithSloName("API"),
@@ -827,6 +914,58 @@ func main() {
 	}
 }
 
+// TestInputValidationFromConfig tests that the buckets and objectives of the configuration
+// are used to validate the directives.
+func TestInputValidationFromConfig(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --slo "API" --success-target 99.5 --latency-ms 2000 --latency-target 99.5
+func main() {
+	fmt.Println(hello) // line comment 3
+}
+`
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	_, err = GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	assert.Error(t, err, "Calling generation must fail if the objectives are not in the default objectives.")
+
+	ctx, err = internal.NewGeneratorContextFromConfig(internal.Config{
+		PrometheusUrl: defaultPrometheusInstanceUrl,
+		Objectives:    []float64{99.5},
+	}, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	_, err = GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	assert.Error(t, err, "Calling generation must fail if latency target is not in the default buckets.")
+
+	ctx, err = internal.NewGeneratorContextFromConfig(internal.Config{
+		PrometheusUrl: defaultPrometheusInstanceUrl,
+		Buckets:       []float64{0.5, 1, 2},
+		Objectives:    []float64{99.5},
+	}, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	_, err = GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating instrumentation with the objectives and buckets of the configuration: %s", err)
+	}
+}
+
 func TestInputValidationDocComments(t *testing.T) {
 	sourceCode := `// This is the package comment.
 package main
@@ -975,7 +1114,7 @@ func main() {
 		t.Fatalf("First node of source code is not a function declaration")
 	}
 
-	actual, err := errorReturnValueName(funcNode)
+	actual, err := errorReturnValueName(&internal.GeneratorContext{}, funcNode)
 	if err != nil {
 		t.Fatalf("error getting the returned value name: %s", err)
 	}
@@ -1005,7 +1144,7 @@ func main() int {
 		t.Fatalf("First node of source code is not a function declaration")
 	}
 
-	actual, err := errorReturnValueName(funcNode)
+	actual, err := errorReturnValueName(&internal.GeneratorContext{}, funcNode)
 	if err != nil {
 		t.Fatalf("error getting the returned value name: %s", err)
 	}
@@ -1035,7 +1174,7 @@ func main() error {
 		t.Fatalf("First node of source code is not a function declaration")
 	}
 
-	actual, err := errorReturnValueName(funcNode)
+	actual, err := errorReturnValueName(&internal.GeneratorContext{}, funcNode)
 	if err != nil {
 		t.Fatalf("error getting the returned value name: %s", err)
 	}
@@ -1065,7 +1204,7 @@ func main() (int, error) {
 		t.Fatalf("First node of source code is not a function declaration")
 	}
 
-	actual, err := errorReturnValueName(funcNode)
+	actual, err := errorReturnValueName(&internal.GeneratorContext{}, funcNode)
 	if err != nil {
 		t.Fatalf("error getting the returned value name: %s", err)
 	}
@@ -1095,7 +1234,7 @@ func main() (int, int) {
 		t.Fatalf("First node of source code is not a function declaration")
 	}
 
-	actual, err := errorReturnValueName(funcNode)
+	actual, err := errorReturnValueName(&internal.GeneratorContext{}, funcNode)
 	if err != nil {
 		t.Fatalf("error getting the returned value name: %s", err)
 	}
@@ -1125,7 +1264,7 @@ func main() (cannotGetLuckyCollision error) {
 		t.Fatalf("First node of source code is not a function declaration")
 	}
 
-	actual, err := errorReturnValueName(funcNode)
+	actual, err := errorReturnValueName(&internal.GeneratorContext{}, funcNode)
 	if err != nil {
 		t.Fatalf("error getting the returned value name: %s", err)
 	}
@@ -1155,7 +1294,7 @@ func main() (i int, cannotGetLuckyCollision error) {
 		t.Fatalf("First node of source code is not a function declaration")
 	}
 
-	actual, err := errorReturnValueName(funcNode)
+	actual, err := errorReturnValueName(&internal.GeneratorContext{}, funcNode)
 	if err != nil {
 		t.Fatalf("error getting the returned value name: %s", err)
 	}
@@ -1183,7 +1322,7 @@ func main() (cannotGetLuckyCollision, otherError error) {
 		t.Fatalf("First node of source code is not a function declaration")
 	}
 
-	_, err = errorReturnValueName(funcNode)
+	_, err = errorReturnValueName(&internal.GeneratorContext{}, funcNode)
 	assert.Error(t, err, "Calling the named return detection must fail if there are multiple error values.")
 }
 
@@ -1328,7 +1467,11 @@ func main() {
 	want := `// This is the package comment.
 package main
 
-import _ "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+import (
+	"time"
+
+	_ "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
 
 // This comment is associated with the main function.
 //
@@ -1336,6 +1479,8 @@ import _ "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
 func main() {
 	defer Instrument(PreInstrument(NewContext(
 		nil,
+		WithFunctionName("main"),
+		WithModuleName("main"),
 		WithConcurrentCalls(true),
 		WithCallerName(true),
 		WithSloName("API"),
@@ -1358,3 +1503,60 @@ func main() {
 
 	assert.Equal(t, want, actual, "The generated source code is not as expected.")
 }
+
+// TestStaticFunctionIdentity makes sure that the generated code sets the module name of methods the way
+// the instrumented code reports it: the path of the package followed by the receiver type name.
+func TestStaticFunctionIdentity(t *testing.T) {
+	sourceCode := `package api
+
+import "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+
+type Stack[T any] struct{}
+
+//autometrics:inst --no-doc
+func (s *Stack[T]) Push(item T) {
+}
+`
+
+	want := `package api
+
+import "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+
+type Stack[T any] struct{}
+
+//autometrics:inst --no-doc
+func (s *Stack[T]) Push(item T) {
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithFunctionName("Push"),
+		autometrics.WithModuleName("example.com/service/api.Stack"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+	)), nil) //autometrics:defer
+
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+	ctx.PackagePath = "example.com/service/api"
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "api")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+
+	// Without the path of the package, the module name is found at runtime.
+	ctx.PackagePath = ""
+	actual, err = GenerateDocumentationAndInstrumentation(ctx, sourceCode, "api")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.NotContains(t, actual, "WithModuleName", "The module name should not be set without the path of the package.")
+	assert.Contains(t, actual, `autometrics.WithFunctionName("Push")`)
+}
@@ -40,9 +40,7 @@ func inspectImportSpec(ctx *internal.GeneratorContext, importSpec *dst.ImportSpe
 	}
 
 	if importSpec.Name == nil {
-		names := strings.Split(importSpec.Path.Value, "/")
-		name := strings.Trim(names[len(names)-1], "\"")
-		ctx.ImportsMap[name] = strings.Trim(importSpec.Path.Value, "\"")
+		ctx.ImportsMap[defaultImportName(mustUnquote(importSpec.Path.Value))] = strings.Trim(importSpec.Path.Value, "\"")
 	} else {
 		ctx.ImportsMap[importSpec.Name.Name] = strings.Trim(importSpec.Path.Value, "\"")
 	}
@@ -50,42 +48,123 @@ func inspectImportSpec(ctx *internal.GeneratorContext, importSpec *dst.ImportSpe
 	return foundAm
 }
 
-// addAutometricsImport adds the correct autometrics import to the passed fileTree.
-func addAutometricsImport(ctx *internal.GeneratorContext, fileTree *dst.File) error {
-	// var importSpec dst.ImportSpec
-	if ctx.Implementation == autometrics.PROMETHEUS {
-		addImport(fileTree, mustUnquote(AmPromPackage))
-		ctx.FuncCtx.ImplImportName = "autometrics"
-		ctx.ImportsMap["autometrics"] = AmPromPackage
+// inspectImports fills the ImportsMap of the context with the imports of the file, and chooses the names
+// the generated code uses to refer to the autometrics and time packages.
+//
+// The names of the existing imports are kept. Otherwise the default name of the package is used, unless
+// an identifier of the file already has that name: an alias is then chosen for the import to add.
+// It returns whether the file already imports the autometrics and time packages.
+func inspectImports(ctx *internal.GeneratorContext, fileTree *dst.File) (foundAm, foundTime bool) {
+	for _, importSpec := range fileTree.Imports {
+		// All the imports must be inspected for context detection, so the loop does not stop
+		// at the autometrics import.
+		if inspectImportSpec(ctx, importSpec) {
+			foundAm = true
+		}
 
-		return nil
+		if mustUnquote(importSpec.Path.Value) == "time" {
+			if name := importName(importSpec); name != "_" && name != "." {
+				ctx.FuncCtx.TimeImportName = name
+				foundTime = true
+			}
+		}
 	}
 
-	if ctx.Implementation == autometrics.OTEL {
-		addImport(fileTree, mustUnquote(AmOtelPackage))
-		ctx.FuncCtx.ImplImportName = "autometrics"
-		ctx.ImportsMap["autometrics"] = AmOtelPackage
+	used := fileIdentifiers(fileTree)
+	if !foundAm {
+		// The import itself is only added after the walk, if the generated code uses it,
+		// so that files without any directive are left untouched.
+		ctx.FuncCtx.ImplImportName = availableImportName(used, "autometrics", "am")
+		used[ctx.FuncCtx.ImplImportName] = true
+	}
+	if !foundTime {
+		ctx.FuncCtx.TimeImportName = availableImportName(used, "time", "amtime")
+	}
 
-		return nil
+	return foundAm, foundTime
+}
+
+// fileIdentifiers returns the names of all the identifiers and imports of the file, except for the package
+// name and the selected fields and methods, as they cannot collide with the name of an import.
+func fileIdentifiers(fileTree *dst.File) map[string]bool {
+	used := make(map[string]bool)
+
+	for _, importSpec := range fileTree.Imports {
+		used[importName(importSpec)] = true
 	}
 
+	var collect func(node dst.Node) bool
+	collect = func(node dst.Node) bool {
+		switch node := node.(type) {
+		case *dst.SelectorExpr:
+			dst.Inspect(node.X, collect)
+			return false
+		case *dst.Ident:
+			used[node.Name] = true
+		}
+		return true
+	}
+	for _, decl := range fileTree.Decls {
+		dst.Inspect(decl, collect)
+	}
+
+	return used
+}
+
+// availableImportName returns the first of the names that is not used, or the first name with
+// the smallest numeric suffix that makes it unused.
+func availableImportName(used map[string]bool, names ...string) string {
+	for _, name := range names {
+		if !used[name] {
+			return name
+		}
+	}
+
+	for i := 2; ; i++ {
+		name := fmt.Sprintf("%s%d", names[0], i)
+		if !used[name] {
+			return name
+		}
+	}
+}
+
+// addAutometricsImport adds the correct autometrics import to the passed fileTree, with the
+// ImplImportName of the context as alias if it is not the default name of the package.
+func addAutometricsImport(ctx *internal.GeneratorContext, fileTree *dst.File) error {
 	if ctx.FuncCtx.ImplImportName == "" {
-		return errors.New("assertion error: ctx.FuncCtx.ImplImportName is empty at the end of addAutometricsImport")
+		return errors.New("assertion error: ctx.FuncCtx.ImplImportName is empty at the start of addAutometricsImport")
+	}
+
+	var path string
+	switch ctx.Implementation {
+	case autometrics.PROMETHEUS:
+		path = mustUnquote(AmPromPackage)
+	case autometrics.OTEL:
+		path = mustUnquote(AmOtelPackage)
+	default:
+		return errors.New("unrecognized implementation of Autometrics has been queried")
 	}
 
-	return errors.New("unrecognized implementation of Autometrics has been queried")
+	addImport(fileTree, ctx.FuncCtx.ImplImportName, path)
+	ctx.ImportsMap[ctx.FuncCtx.ImplImportName] = path
+
+	return nil
 }
 
-// addImport walks a file declarations to add in the correct location an additional import for 'imp' without alias.
+// addImport walks a file declarations to add in the correct location an additional import for 'imp',
+// with the name as alias if it is not the default name of the package.
 //
 // Ref: https://github.com/dave/dst/issues/61#issuecomment-928529830
-func addImport(file *dst.File, imp string) {
+func addImport(file *dst.File, name, imp string) {
 	// Where to insert our import block within the file's Decl slice
 	index := 0
 
 	importSpec := &dst.ImportSpec{
 		Path: &dst.BasicLit{Kind: token.STRING, Value: fmt.Sprintf("%q", imp)},
 	}
+	if name != defaultImportName(imp) {
+		importSpec.Name = dst.NewIdent(name)
+	}
 
 	for i, node := range file.Decls {
 		n, ok := node.(*dst.GenDecl)
@@ -103,15 +182,29 @@ func addImport(file *dst.File, imp string) {
 			continue
 		}
 
-		// Insert our import into the first non-"C" import block
+		// Insert our import into the first non-"C" import block, sorted among the imports of the
+		// same group (standard library or not)
+		standard := !strings.Contains(imp, ".")
 		for j, spec := range n.Specs {
 			path := mustUnquote(spec.(*dst.ImportSpec).Path.Value)
-			if !strings.Contains(path, ".") || imp > path {
+			if standard == !strings.Contains(path, ".") && imp > path {
+				continue
+			}
+			if !standard && !strings.Contains(path, ".") {
 				continue
 			}
 
-			importSpec.Decorations().Before = spec.Decorations().Before
-			spec.Decorations().Before = dst.NewLine
+			if standard && strings.Contains(path, ".") && j > 0 {
+				// Last import of the standard library group
+				importSpec.Decorations().Before = dst.NewLine
+			} else {
+				importSpec.Decorations().Before = spec.Decorations().Before
+				spec.Decorations().Before = dst.NewLine
+				if standard && strings.Contains(path, ".") {
+					// First import, before the group of the other imports
+					spec.Decorations().Before = dst.EmptyLine
+				}
+			}
 
 			n.Specs = append(n.Specs[:j], append([]dst.Spec{importSpec}, n.Specs[j:]...)...)
 			return
@@ -139,3 +232,104 @@ func mustUnquote(s string) string {
 	}
 	return out
 }
+
+// importName returns the name used to refer to the imported package in the file.
+//
+// Like for the ImportsMap of the context, the name of an import without alias is assumed to be
+// its [defaultImportName].
+func importName(importSpec *dst.ImportSpec) string {
+	if importSpec.Name != nil {
+		return importSpec.Name.Name
+	}
+
+	return defaultImportName(mustUnquote(importSpec.Path.Value))
+}
+
+// defaultImportName returns the name of the package imported without alias, assuming that it is the last
+// element of its path, or the element before the major version suffix of a module ("fiber" for
+// "github.com/gofiber/fiber/v2").
+func defaultImportName(path string) string {
+	names := strings.Split(path, "/")
+	name := names[len(names)-1]
+	if len(names) > 1 && isMajorVersionSuffix(name) {
+		name = names[len(names)-2]
+	}
+
+	return name
+}
+
+// isMajorVersionSuffix returns true if the element of an import path is a major version suffix, like "v2".
+func isMajorVersionSuffix(element string) bool {
+	if len(element) < 2 || element[0] != 'v' {
+		return false
+	}
+	for _, char := range element[1:] {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// usedImportNames counts the qualified identifiers in the file, by name of the package they refer to.
+func usedImportNames(fileTree *dst.File) map[string]int {
+	used := make(map[string]int)
+
+	dst.Inspect(fileTree, func(node dst.Node) bool {
+		if selector, ok := node.(*dst.SelectorExpr); ok {
+			if ident, ok := selector.X.(*dst.Ident); ok {
+				used[ident.Name]++
+			}
+		}
+		return true
+	})
+
+	return used
+}
+
+// removeUnusedImports removes the imports that were used in usedBefore (see [usedImportNames]), and are
+// not used in the file anymore.
+//
+// Imports that were not used to begin with are left untouched, as their name might not be detected properly.
+func removeUnusedImports(fileTree *dst.File, usedBefore map[string]int) {
+	usedAfter := usedImportNames(fileTree)
+
+	unused := func(importSpec *dst.ImportSpec) bool {
+		name := importName(importSpec)
+		if name == "_" || name == "." {
+			return false
+		}
+		return usedBefore[name] > 0 && usedAfter[name] == 0
+	}
+
+	decls := fileTree.Decls[:0]
+	for _, decl := range fileTree.Decls {
+		genDecl, ok := decl.(*dst.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			decls = append(decls, decl)
+			continue
+		}
+
+		specs := genDecl.Specs[:0]
+		for _, spec := range genDecl.Specs {
+			if !unused(spec.(*dst.ImportSpec)) {
+				specs = append(specs, spec)
+			}
+		}
+		genDecl.Specs = specs
+
+		if len(genDecl.Specs) > 0 {
+			decls = append(decls, genDecl)
+		}
+	}
+	fileTree.Decls = decls
+
+	imports := fileTree.Imports[:0]
+	for _, importSpec := range fileTree.Imports {
+		if !unused(importSpec) {
+			imports = append(imports, importSpec)
+		}
+	}
+	fileTree.Imports = imports
+}
@@ -0,0 +1,180 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+// TestImportNameCollision makes sure that the imports added by the generator get an alias
+// when their default name is already used in the file.
+func TestImportNameCollision(t *testing.T) {
+	sourceCode := `package main
+
+import (
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+var time = autometrics.DefBuckets
+
+//autometrics:inst --no-doc --slo "API" --latency-target 99.9 --latency-ms 500
+func main() {
+	fmt.Println(time)
+}
+`
+
+	want := `package main
+
+import (
+	amtime "time"
+
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	am "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+var time = autometrics.DefBuckets
+
+//autometrics:inst --no-doc --slo "API" --latency-target 99.9 --latency-ms 500
+func main() {
+	defer am.Instrument(am.PreInstrument(am.NewContext(
+		nil,
+		am.WithFunctionName("main"),
+		am.WithModuleName("main"),
+		am.WithConcurrentCalls(true),
+		am.WithCallerName(true),
+		am.WithSloName("API"),
+		am.WithAlertLatency(500000000*amtime.Nanosecond, 99.9),
+	)), nil) //autometrics:defer
+
+	fmt.Println(time)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+
+	// The aliases of the former pass are kept.
+	actual, err = GenerateDocumentationAndInstrumentation(ctx, want, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generation should be idempotent.")
+}
+
+// TestExistingTimeImport makes sure that the generated code uses the existing import of the time package.
+func TestExistingTimeImport(t *testing.T) {
+	sourceCode := `package main
+
+import (
+	stdtime "time"
+
+	"github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+//autometrics:inst --no-doc --slo "API" --latency-target 99.9 --latency-ms 500
+func main() {
+	fmt.Println(stdtime.Now())
+}
+`
+
+	want := `package main
+
+import (
+	stdtime "time"
+
+	"github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+//autometrics:inst --no-doc --slo "API" --latency-target 99.9 --latency-ms 500
+func main() {
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithFunctionName("main"),
+		autometrics.WithModuleName("main"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+		autometrics.WithSloName("API"),
+		autometrics.WithAlertLatency(500000000*stdtime.Nanosecond, 99.9),
+	)), nil) //autometrics:defer
+
+	fmt.Println(stdtime.Now())
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
+
+// TestRemoveUnusedImports makes sure that the imports only used by the generated code of a former pass
+// are removed, while the other imports are left untouched.
+func TestRemoveUnusedImports(t *testing.T) {
+	sourceCode := `package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This function is not instrumented anymore.
+func main() {
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+		autometrics.WithSloName("API"),
+		autometrics.WithAlertLatency(500000000*time.Nanosecond, 99.9),
+	)), nil) //autometrics:defer
+
+	fmt.Println(hello)
+}
+`
+
+	want := `package main
+
+import (
+	"fmt"
+)
+
+// This function is not instrumented anymore.
+func main() {
+
+	fmt.Println(hello)
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	actual, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The generated source code is not as expected.")
+}
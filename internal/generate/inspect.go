@@ -0,0 +1,160 @@
+package generate // import "github.com/autometrics-dev/autometrics-go/internal/generate"
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+
+	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+)
+
+// FunctionInfo describes a function that has an autometrics directive.
+type FunctionInfo struct {
+	// FunctionName is the name of the function, as it appears in the metrics.
+	FunctionName string
+	// ModuleName is the name of the module containing the function, as it appears in the metrics.
+	ModuleName string
+	// QualifiedModuleName is the module label of the function in the metrics: the path of the package,
+	// followed by the receiver type name for methods, as the instrumented code reports it.
+	//
+	// It is empty if the path of the package is unknown.
+	QualifiedModuleName string
+	// Path is the path of the file containing the function.
+	Path string
+	// Receiver is the type of the receiver of the function if it is a method, like "*Server".
+	//
+	// It is empty for plain functions.
+	Receiver string
+	// Line is the line of the function declaration in the file.
+	Line int
+	// Directive is the kind of autometrics directive on the function, "inst" or "doc".
+	Directive string
+	// UnknownArguments are the arguments of the directive that the generator does not know, and ignores.
+	UnknownArguments []string
+	// RuntimeCtx is the runtime context the generator builds for the function.
+	//
+	// It contains the alerting configuration set in the directive, the tracking flags, and
+	// the context variable detected in the function signature.
+	RuntimeCtx internal.RuntimeCtxInfo
+}
+
+// InspectPackages loads all the packages matching the patterns (relative to dir), and lists
+// the functions that have an autometrics directive in them, without modifying any file.
+//
+// The same files as in [TransformPackages] are inspected, and the directives are validated
+// the same way.
+func InspectPackages(ctx internal.GeneratorContext, dir string, patterns []string) ([]FunctionInfo, error) {
+	files, err := packageFiles(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var functions []FunctionInfo
+	for _, file := range files {
+		fileCtx := ctx
+		fileCtx.ResetFileCtx()
+		fileCtx.PackagePath = file.packagePath
+
+		fileFunctions, err := InspectFile(fileCtx, file.path, file.moduleName)
+		if err != nil {
+			return functions, fmt.Errorf("error inspecting %s: %w", file.path, err)
+		}
+		functions = append(functions, fileFunctions...)
+	}
+
+	return functions, nil
+}
+
+// InspectFile lists the functions that have an autometrics directive in a file, in the order
+// they are declared.
+func InspectFile(ctx internal.GeneratorContext, path, moduleName string) ([]FunctionInfo, error) {
+	sourceBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the source code from %s: %w", path, err)
+	}
+
+	if ctx.PackagePath == "" {
+		ctx.PackagePath = fileImportPath(path)
+	}
+
+	return InspectSource(ctx, path, string(sourceBytes), moduleName)
+}
+
+// InspectSource is [InspectFile] for source code already read from the file at path.
+func InspectSource(ctx internal.GeneratorContext, path, sourceCode, moduleName string) ([]FunctionInfo, error) {
+	fset := token.NewFileSet()
+	dec := decorator.NewDecorator(fset)
+	fileTree, err := dec.ParseFile(path, sourceCode, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing source code: %w", err)
+	}
+
+	inspectImports(&ctx, fileTree)
+
+	var functions []FunctionInfo
+	var inspectErr error
+
+	fileWalk := func(node dst.Node) bool {
+		funcDeclaration, ok := node.(*dst.FuncDecl)
+		if !ok {
+			return inspectErr == nil
+		}
+
+		ctx.FuncCtx.FunctionName = funcDeclaration.Name.Name
+		ctx.FuncCtx.ModuleName = moduleName
+		defer ctx.ResetFuncCtx()
+
+		docComments := funcDeclaration.Decorations().Start.All()
+		inspectErr = parseAutometricsFnContext(&ctx, docComments)
+		if inspectErr != nil {
+			inspectErr = fmt.Errorf(
+				"failed to parse //autometrics directive for %v: %w",
+				funcDeclaration.Name.Name,
+				inspectErr)
+			return false
+		}
+
+		if ctx.FuncCtx.CommentIndex < 0 {
+			return true
+		}
+
+		detectContext(&ctx, funcDeclaration)
+
+		info := FunctionInfo{
+			FunctionName:        funcDeclaration.Name.Name,
+			ModuleName:          moduleName,
+			QualifiedModuleName: qualifiedModuleName(ctx.PackagePath, moduleName, funcDeclaration),
+			Path:                path,
+			Directive:           "inst",
+			RuntimeCtx:          ctx.RuntimeCtx,
+		}
+		info.UnknownArguments = ctx.FuncCtx.UnknownArguments
+		if strings.HasPrefix(docComments[ctx.FuncCtx.CommentIndex], "//autometrics:doc") {
+			info.Directive = "doc"
+		}
+		if astNode, ok := dec.Ast.Nodes[funcDeclaration].(*ast.FuncDecl); ok {
+			info.Line = fset.Position(astNode.Pos()).Line
+			if astNode.Recv != nil && len(astNode.Recv.List) > 0 {
+				info.Receiver = types.ExprString(astNode.Recv.List[0].Type)
+			}
+		}
+		functions = append(functions, info)
+
+		return true
+	}
+
+	dst.Inspect(fileTree, fileWalk)
+
+	if inspectErr != nil {
+		return nil, fmt.Errorf("error while inspecting file in %v: %w", moduleName, inspectErr)
+	}
+
+	return functions, nil
+}
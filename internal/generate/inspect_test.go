@@ -0,0 +1,130 @@
+package generate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+// TestInspectSource tests that the inspection lists the functions with a directive,
+// along with their position and runtime context.
+func TestInspectSource(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+
+	"github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --slo "API" --success-target 90 --latency-ms 250 --latency-target 99
+func main() {
+	fmt.Println(hello) // line comment 3
+}
+
+// Not instrumented.
+func helper() {
+}
+
+//autometrics:doc --no-doc
+func (s *Server) documented(ctx context.Context) {
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	functions, err := InspectSource(ctx, "main.go", sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error inspecting the source code: %s", err)
+	}
+
+	mainCtx := internal.DefaultRuntimeCtxInfo()
+	mainCtx.AlertConf = &autometrics.AlertConfiguration{
+		ServiceName: "API",
+		Latency: &autometrics.LatencySlo{
+			Target:    250 * time.Millisecond,
+			Objective: 99,
+		},
+		Success: &autometrics.SuccessSlo{Objective: 90},
+	}
+
+	documentedCtx := internal.DefaultRuntimeCtxInfo()
+	documentedCtx.ContextVariableName = "ctx"
+
+	want := []FunctionInfo{
+		{
+			FunctionName:        "main",
+			ModuleName:          "main",
+			QualifiedModuleName: "main",
+			Path:                "main.go",
+			Line:                13,
+			Directive:           "inst",
+			RuntimeCtx:          mainCtx,
+		},
+		{
+			FunctionName:        "documented",
+			ModuleName:          "main",
+			QualifiedModuleName: "main.Server",
+			Path:                "main.go",
+			Receiver:            "*Server",
+			Line:                22,
+			Directive:           "doc",
+			RuntimeCtx:          documentedCtx,
+		},
+	}
+
+	assert.Equal(t, want, functions, "The inspected functions are not as expected.")
+}
+
+// TestInspectSourceInvalidDirective tests that the inspection validates the directives
+// like the generator does.
+func TestInspectSourceInvalidDirective(t *testing.T) {
+	sourceCode := `package main
+
+//autometrics:inst --slo "API" --success-target 42
+func main() {
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	_, err = InspectSource(ctx, "main.go", sourceCode, "main")
+	assert.Error(t, err, "Objectives that are not supported by the rules must be rejected.")
+}
+
+// TestInspectSourceUnknownArguments tests that the arguments ignored by the generator are reported.
+func TestInspectSourceUnknownArguments(t *testing.T) {
+	sourceCode := `package main
+
+//autometrics:inst --slo "API" --succes-target 99 --no-doc
+func main() {
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	functions, err := InspectSource(ctx, "main.go", sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error inspecting the source code: %s", err)
+	}
+
+	if assert.Len(t, functions, 1) {
+		assert.Equal(t, []string{"--succes-target", "99"}, functions[0].UnknownArguments)
+	}
+}
@@ -0,0 +1,130 @@
+package generate // import "github.com/autometrics-dev/autometrics-go/internal/generate"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+)
+
+// ManifestVersion is the version of the format of the manifest.
+//
+// It is bumped every time a change in the format can break a reader of the manifest.
+const ManifestVersion = 1
+
+// Manifest lists all the functions with an autometrics directive in a codebase.
+type Manifest struct {
+	Version   int             `json:"version"`
+	Functions []ManifestEntry `json:"functions"`
+}
+
+// ManifestEntry describes a function with an autometrics directive, and how it gets instrumented.
+type ManifestEntry struct {
+	// Function is the name of the function, as it appears in the metrics.
+	Function string `json:"function"`
+	// Module is the name of the module containing the function, as it appears in the metrics.
+	Module string `json:"module"`
+	// Receiver is the type of the receiver of a method, like "*Server".
+	Receiver string `json:"receiver,omitempty"`
+	// File is the path of the file containing the function, relative to the directory of the manifest.
+	File string `json:"file"`
+	// Line is the line of the function declaration in the file.
+	Line int `json:"line"`
+	// Directive is the kind of autometrics directive on the function, "inst" or "doc".
+	Directive string `json:"directive"`
+	// TrackConcurrentCalls is true if the instrumentation tracks the number of concurrent calls.
+	TrackConcurrentCalls bool `json:"track_concurrent_calls"`
+	// TrackCallerName is true if the instrumentation reports the name of the caller.
+	TrackCallerName bool `json:"track_caller_name"`
+	// Context describes how the instrumentation gets its context from the function arguments.
+	Context ManifestContext `json:"context"`
+	// Alert is the alerting configuration set in the directive, or nil if the directive has none.
+	Alert *ManifestAlert `json:"alert,omitempty"`
+}
+
+// ManifestContext describes the context detected in the signature of a function.
+type ManifestContext struct {
+	// Variable is the expression used as context.Context by the instrumentation, it is "nil"
+	// if no context has been detected.
+	Variable string `json:"variable"`
+	// TraceIDGetter is the expression used to get the trace ID, if any.
+	TraceIDGetter string `json:"trace_id_getter,omitempty"`
+	// SpanIDGetter is the expression used to get the span ID, if any.
+	SpanIDGetter string `json:"span_id_getter,omitempty"`
+}
+
+// ManifestAlert is the alerting configuration of a function.
+type ManifestAlert struct {
+	// SloName is the name of the Service Level Objective.
+	SloName string `json:"slo_name"`
+	// SuccessObjective is the percentage of calls that must succeed, if set.
+	SuccessObjective *float64 `json:"success_objective,omitempty"`
+	// LatencyObjective is the percentage of calls that must last less than the latency threshold, if set.
+	LatencyObjective *float64 `json:"latency_objective,omitempty"`
+	// LatencyThresholdSeconds is the latency threshold of the latency objective, in seconds, if set.
+	LatencyThresholdSeconds *float64 `json:"latency_threshold_seconds,omitempty"`
+}
+
+// NewManifest builds the manifest of the functions. File paths are made relative to dir when possible.
+func NewManifest(dir string, functions []FunctionInfo) Manifest {
+	manifest := Manifest{
+		Version:   ManifestVersion,
+		Functions: make([]ManifestEntry, 0, len(functions)),
+	}
+
+	for _, function := range functions {
+		path := function.Path
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			path = rel
+		}
+
+		entry := ManifestEntry{
+			Function:             function.FunctionName,
+			Module:               function.ModuleName,
+			Receiver:             function.Receiver,
+			File:                 filepath.ToSlash(path),
+			Line:                 function.Line,
+			Directive:            function.Directive,
+			TrackConcurrentCalls: function.RuntimeCtx.TrackConcurrentCalls,
+			TrackCallerName:      function.RuntimeCtx.TrackCallerName,
+			Context: ManifestContext{
+				Variable:      function.RuntimeCtx.ContextVariableName,
+				TraceIDGetter: function.RuntimeCtx.TraceIDGetter,
+				SpanIDGetter:  function.RuntimeCtx.SpanIDGetter,
+			},
+		}
+
+		if conf := function.RuntimeCtx.AlertConf; conf != nil {
+			entry.Alert = &ManifestAlert{SloName: conf.ServiceName}
+			if conf.Success != nil {
+				objective := conf.Success.Objective
+				entry.Alert.SuccessObjective = &objective
+			}
+			if conf.Latency != nil {
+				objective := conf.Latency.Objective
+				threshold := conf.Latency.Target.Seconds()
+				entry.Alert.LatencyObjective = &objective
+				entry.Alert.LatencyThresholdSeconds = &threshold
+			}
+		}
+
+		manifest.Functions = append(manifest.Functions, entry)
+	}
+
+	return manifest
+}
+
+// JSON returns the indented JSON encoding of the manifest.
+func (m Manifest) JSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	// The context getters are Go expressions that can contain characters like '&'.
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(m); err != nil {
+		return nil, fmt.Errorf("error encoding the manifest: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,91 @@
+package generate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+func TestManifest(t *testing.T) {
+	runtimeCtx := internal.DefaultRuntimeCtxInfo()
+	runtimeCtx.ContextVariableName = "r.Context()"
+	runtimeCtx.AlertConf = &autometrics.AlertConfiguration{
+		ServiceName: "API",
+		Latency: &autometrics.LatencySlo{
+			Target:    250 * time.Millisecond,
+			Objective: 99,
+		},
+	}
+
+	functions := []FunctionInfo{
+		{
+			FunctionName: "AddUser",
+			ModuleName:   "api",
+			Path:         "/src/project/api/users.go",
+			Receiver:     "*Server",
+			Line:         42,
+			Directive:    "inst",
+			RuntimeCtx:   runtimeCtx,
+		},
+		{
+			FunctionName: "main",
+			ModuleName:   "main",
+			Path:         "/src/project/main.go",
+			Line:         7,
+			Directive:    "doc",
+			RuntimeCtx:   internal.DefaultRuntimeCtxInfo(),
+		},
+	}
+
+	content, err := NewManifest("/src/project", functions).JSON()
+	if err != nil {
+		t.Fatalf("error encoding the manifest: %s", err)
+	}
+
+	want := `{
+  "version": 1,
+  "functions": [
+    {
+      "function": "AddUser",
+      "module": "api",
+      "receiver": "*Server",
+      "file": "api/users.go",
+      "line": 42,
+      "directive": "inst",
+      "track_concurrent_calls": true,
+      "track_caller_name": true,
+      "context": {
+        "variable": "r.Context()"
+      },
+      "alert": {
+        "slo_name": "API",
+        "latency_objective": 99,
+        "latency_threshold_seconds": 0.25
+      }
+    },
+    {
+      "function": "main",
+      "module": "main",
+      "file": "main.go",
+      "line": 7,
+      "directive": "doc",
+      "track_concurrent_calls": true,
+      "track_caller_name": true,
+      "context": {
+        "variable": "nil"
+      }
+    }
+  ]
+}
+`
+
+	assert.Equal(t, want, string(content), "The manifest is not as expected.")
+
+	var manifest Manifest
+	assert.NoError(t, json.Unmarshal(content, &manifest), "The manifest must be readable back.")
+}
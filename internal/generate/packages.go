@@ -0,0 +1,221 @@
+package generate // import "github.com/autometrics-dev/autometrics-go/internal/generate"
+
+import (
+	"fmt"
+	"go/ast"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+)
+
+// FileReport sums up what the generator did to a single file.
+type FileReport struct {
+	// Path is the path of the transformed file.
+	Path string
+	// InstrumentedFunctions is the number of functions with an autometrics directive in the file.
+	//
+	// When stripping a file, it is the number of functions autometrics code was removed from.
+	InstrumentedFunctions int
+	// Modified is true if the generator changed the content of the file.
+	//
+	// In check mode, it is true if the generator would change the content of the file.
+	Modified bool
+	// Diff is the unified diff between the file and its transformed version.
+	//
+	// It is only computed in check mode.
+	Diff string
+}
+
+// TransformPackages loads all the packages matching the patterns (relative to dir), and
+// transforms every Go file in them.
+//
+// Test files and vendored files are skipped. The returned reports are sorted by path.
+func TransformPackages(ctx internal.GeneratorContext, dir string, patterns []string) ([]FileReport, error) {
+	return transformPackages(ctx, dir, patterns, false)
+}
+
+// CheckPackages runs the transformation of [TransformPackages] in memory, without writing any file.
+//
+// See [CheckFile] for the content of the reports.
+func CheckPackages(ctx internal.GeneratorContext, dir string, patterns []string) ([]FileReport, error) {
+	return transformPackages(ctx, dir, patterns, true)
+}
+
+func transformPackages(ctx internal.GeneratorContext, dir string, patterns []string, dryRun bool) ([]FileReport, error) {
+	files, err := packageFiles(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]FileReport, 0, len(files))
+	for _, file := range files {
+		fileCtx := ctx
+		fileCtx.ResetFileCtx()
+		fileCtx.PackagePath = file.packagePath
+		fileCtx.ErrorResults = file.errorResults
+
+		report, err := transformFile(fileCtx, file.path, file.moduleName, dryRun)
+		if err != nil {
+			return reports, fmt.Errorf("error transforming %s: %w", file.path, err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+type packageFile struct {
+	path        string
+	moduleName  string
+	packagePath string
+	// errorResults is nil if the type information of the file is not available.
+	errorResults map[string]internal.ErrorResult
+}
+
+// packageFiles lists the Go files to transform in the packages matching the patterns.
+//
+// The module name associated with each file is the package name, to match what go generate
+// puts in the GOPACKAGE environment variable. The packages are type-checked to find the results
+// of custom error types (see [ErrorResults]).
+func packageFiles(dir string, patterns []string) ([]packageFile, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedModule |
+			packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Dir:   dir,
+		Tests: false,
+	}
+
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading packages %v: %w", patterns, err)
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %w", dir, err)
+	}
+
+	seen := make(map[string]bool)
+	var files []packageFile
+
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) == 0 && len(pkg.Errors) > 0 {
+			return nil, fmt.Errorf("error loading package %s: %v", pkg.PkgPath, pkg.Errors[0])
+		}
+
+		root := absDir
+		if pkg.Module != nil && pkg.Module.Dir != "" {
+			root = pkg.Module.Dir
+		}
+
+		// The custom error types of the packages with type errors are detected by their names.
+		syntax := make(map[string]*ast.File, len(pkg.Syntax))
+		if pkg.TypesInfo != nil && !pkg.IllTyped {
+			for _, file := range pkg.Syntax {
+				syntax[pkg.Fset.File(file.Pos()).Name()] = file
+			}
+		}
+
+		for _, path := range pkg.GoFiles {
+			if seen[path] || skipFile(root, path) {
+				continue
+			}
+			seen[path] = true
+
+			file := packageFile{path: path, moduleName: pkg.Name, packagePath: pkg.PkgPath}
+			if fileSyntax, ok := syntax[path]; ok {
+				file.errorResults = ErrorResults(fileSyntax, pkg.TypesInfo)
+			}
+			files = append(files, file)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+	return files, nil
+}
+
+// skipFile returns true for the files the generator must not touch in package mode.
+//
+// Only the directories below root, the directory of the module of the file (or the directory the packages
+// are loaded from), are checked for vendored files, so that a module checked out in a "vendor" directory
+// is still transformed.
+func skipFile(root, path string) bool {
+	if strings.HasSuffix(path, "_test.go") {
+		return true
+	}
+
+	relativePath, err := filepath.Rel(root, path)
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		relativePath = filepath.Base(path)
+	}
+
+	for _, part := range strings.Split(filepath.ToSlash(relativePath), "/") {
+		if part == "vendor" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fileImportPath returns the import path of the package of the file, from the path of the module declared in
+// the closest go.mod file.
+//
+// It returns an empty string if the file is not in a module.
+func fileImportPath(filePath string) string {
+	dir, err := filepath.Abs(filepath.Dir(filePath))
+	if err != nil {
+		return ""
+	}
+
+	for root := dir; ; root = filepath.Dir(root) {
+		content, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			modulePath := modfile.ModulePath(content)
+			relativeDir, err := filepath.Rel(root, dir)
+			if modulePath == "" || err != nil {
+				return ""
+			}
+			return path.Join(modulePath, filepath.ToSlash(relativeDir))
+		}
+
+		if filepath.Dir(root) == root {
+			return ""
+		}
+	}
+}
+
+// runtimePackagePath returns the path of the package as it appears in the names of its functions at runtime:
+// "main" for the main packages, and the import path with the dots of its last element escaped otherwise (see
+// [autometrics.CallerInfo]).
+//
+// It returns an empty string if the import path is unknown, and for external test packages.
+func runtimePackagePath(importPath, packageName string) string {
+	if packageName == "main" {
+		return "main"
+	}
+	if importPath == "" || strings.HasSuffix(packageName, "_test") {
+		return ""
+	}
+
+	lastSlash := strings.LastIndex(importPath, "/")
+	var builder strings.Builder
+	for i := 0; i < len(importPath); i++ {
+		char := importPath[i]
+		if char <= ' ' || (char == '.' && i > lastSlash) || char == '%' || char == '"' || char >= 0x7F {
+			fmt.Fprintf(&builder, "%%%02x", char)
+			continue
+		}
+		builder.WriteByte(char)
+	}
+
+	return builder.String()
+}
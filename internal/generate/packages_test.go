@@ -0,0 +1,301 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+// TestTransformPackages tests that the package mode of the generator transforms
+// all the files of all the packages matching the pattern, except the test files.
+func TestTransformPackages(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod": "module example.com/pkgmode\n\ngo 1.18\n",
+		"main.go": `package main
+
+//autometrics:inst --no-doc
+func main() {
+	println("hello")
+}
+`,
+		"main_test.go": `package main
+
+//autometrics:inst --no-doc
+func helper() {
+}
+`,
+		"sub/sub.go": `package sub
+
+// Untouched is not instrumented.
+func Untouched() {
+}
+`,
+		"sub/deeper/deeper.go": `package deeper
+
+//autometrics:inst --no-doc
+func First() {
+	println("first")
+}
+
+//autometrics:doc --no-doc
+func Second() {
+	println("second")
+}
+`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("error creating the test module: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("error creating the test module: %s", err)
+		}
+	}
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	reports, err := TransformPackages(ctx, dir, []string{"./..."})
+	if err != nil {
+		t.Fatalf("error transforming the packages: %s", err)
+	}
+
+	var summary []string
+	for _, report := range reports {
+		rel, err := filepath.Rel(dir, report.Path)
+		if err != nil {
+			t.Fatalf("unexpected report path %s: %s", report.Path, err)
+		}
+		summary = append(summary, filepath.ToSlash(rel))
+	}
+
+	assert.Equal(t, []string{"main.go", "sub/deeper/deeper.go", "sub/sub.go"}, summary, "The transformed files are not as expected.")
+	assert.Equal(t, 1, reports[0].InstrumentedFunctions)
+	assert.Equal(t, 2, reports[1].InstrumentedFunctions)
+	assert.Equal(t, 0, reports[2].InstrumentedFunctions)
+	assert.False(t, reports[2].Modified, "Files without directives must be left untouched.")
+
+	deeper, err := os.ReadFile(filepath.Join(dir, "sub", "deeper", "deeper.go"))
+	if err != nil {
+		t.Fatalf("error reading transformed file: %s", err)
+	}
+	assert.Equal(t, 2, strings.Count(string(deeper), "//autometrics:defer"), "Both functions should be instrumented.")
+	assert.Equal(t, 2, strings.Count(string(deeper), `autometrics.WithModuleName("example.com/pkgmode/sub/deeper")`),
+		"The module name should be the import path of the package.")
+
+	test, err := os.ReadFile(filepath.Join(dir, "main_test.go"))
+	if err != nil {
+		t.Fatalf("error reading test file: %s", err)
+	}
+	assert.Equal(t, files["main_test.go"], string(test), "Test files must not be transformed.")
+}
+
+// TestTransformPackagesCustomErrors tests that the package mode of the generator captures the
+// results whose type implements error, using the type information of the package.
+func TestTransformPackagesCustomErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod": "module example.com/pkgmode\n\ngo 1.18\n",
+		"errors.go": `package pkgmode
+
+type NotFound struct {
+	Key string
+}
+
+func (e *NotFound) Error() string {
+	return e.Key + " not found"
+}
+
+type ParseError struct {
+	Line int
+}
+
+//autometrics:inst --no-doc
+func Lookup(key string) (string, *NotFound) {
+	return key, nil
+}
+
+//autometrics:inst --no-doc
+func Parse(input string) (int, ParseError) {
+	return len(input), ParseError{}
+}
+
+//autometrics:inst --no-doc
+func Fetch(key string) (*NotFound, error) {
+	return nil, nil
+}
+`,
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("error creating the test module: %s", err)
+		}
+	}
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	if _, err := TransformPackages(ctx, dir, []string{"./..."}); err != nil {
+		t.Fatalf("error transforming the packages: %s", err)
+	}
+
+	actual, err := os.ReadFile(filepath.Join(dir, "errors.go"))
+	if err != nil {
+		t.Fatalf("error reading transformed file: %s", err)
+	}
+
+	want := "package pkgmode\n" +
+		"\n" +
+		"import \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"\n" +
+		"\n" +
+		"type NotFound struct {\n" +
+		"\tKey string\n" +
+		"}\n" +
+		"\n" +
+		"func (e *NotFound) Error() string {\n" +
+		"\treturn e.Key + \" not found\"\n" +
+		"}\n" +
+		"\n" +
+		"type ParseError struct {\n" +
+		"\tLine int\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:inst --no-doc\n" +
+		"func Lookup(key string) (_ string, err *NotFound) {\n" +
+		"\tdefer autometrics.InstrumentCustomError(autometrics.PreInstrument(autometrics.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tautometrics.WithFunctionName(\"Lookup\"),\n" +
+		"\t\tautometrics.WithModuleName(\"example.com/pkgmode\"),\n" +
+		"\t\tautometrics.WithConcurrentCalls(true),\n" +
+		"\t\tautometrics.WithCallerName(true),\n" +
+		"\t)), &err) //autometrics:defer\n" +
+		"\n" +
+		"\treturn key, nil\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:inst --no-doc\n" +
+		"func Parse(input string) (int, ParseError) {\n" +
+		"\tdefer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tautometrics.WithFunctionName(\"Parse\"),\n" +
+		"\t\tautometrics.WithModuleName(\"example.com/pkgmode\"),\n" +
+		"\t\tautometrics.WithConcurrentCalls(true),\n" +
+		"\t\tautometrics.WithCallerName(true),\n" +
+		"\t)), nil) //autometrics:defer\n" +
+		"\n" +
+		"\treturn len(input), ParseError{}\n" +
+		"}\n" +
+		"\n" +
+		"//autometrics:inst --no-doc\n" +
+		"func Fetch(key string) (_ *NotFound, err error) {\n" +
+		"\tdefer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(\n" +
+		"\t\tnil,\n" +
+		"\t\tautometrics.WithFunctionName(\"Fetch\"),\n" +
+		"\t\tautometrics.WithModuleName(\"example.com/pkgmode\"),\n" +
+		"\t\tautometrics.WithConcurrentCalls(true),\n" +
+		"\t\tautometrics.WithCallerName(true),\n" +
+		"\t)), &err) //autometrics:defer\n" +
+		"\n" +
+		"\treturn nil, nil\n" +
+		"}\n"
+
+	assert.Equal(t, want, string(actual), "Only the results implementing error should be captured, with InstrumentCustomError for the custom error types.")
+}
+
+func TestFileImportPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "api", "v1"), 0o755); err != nil {
+		t.Fatalf("error creating the test module: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/service\n\ngo 1.18\n"), 0o644); err != nil {
+		t.Fatalf("error creating the test module: %s", err)
+	}
+
+	assert.Equal(t, "example.com/service", fileImportPath(filepath.Join(dir, "main.go")))
+	assert.Equal(t, "example.com/service/api/v1", fileImportPath(filepath.Join(dir, "api", "v1", "handlers.go")))
+}
+
+func TestRuntimePackagePath(t *testing.T) {
+	assert.Equal(t, "main", runtimePackagePath("example.com/service/cmd/server", "main"))
+	assert.Equal(t, "main", runtimePackagePath("", "main"))
+	assert.Equal(t, "example.com/service/api", runtimePackagePath("example.com/service/api", "api"))
+	assert.Equal(t, "gopkg.in/yaml%2ev3", runtimePackagePath("gopkg.in/yaml.v3", "yaml"))
+	assert.Equal(t, "", runtimePackagePath("", "api"), "The path of the package should be found at runtime.")
+	assert.Equal(t, "", runtimePackagePath("example.com/service/api", "api_test"), "The path of the package should be found at runtime.")
+}
+
+func TestSkipFile(t *testing.T) {
+	assert.True(t, skipFile("/src", "/src/pkg/file_test.go"))
+	assert.True(t, skipFile("/src", "/src/vendor/github.com/dep/file.go"))
+	assert.False(t, skipFile("/src", "/src/pkg/vendoring.go"))
+	assert.False(t, skipFile("/src", "/src/pkg/file.go"))
+	assert.False(t, skipFile("/vendor/module", "/vendor/module/pkg/file.go"), "The directories above the module must not be checked.")
+	assert.False(t, skipFile("/home/x/vendor-tools/module", "/home/x/vendor-tools/module/file.go"))
+	assert.True(t, skipFile("/vendor/module", "/vendor/module/vendor/github.com/dep/file.go"))
+}
+
+// TestCheckFile tests that the check mode reports the drift between a file and its directives
+// without writing the file.
+func TestCheckFile(t *testing.T) {
+	sourceCode := `package main
+
+//autometrics:inst --no-doc
+func main() {
+	println("hello")
+}
+`
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte(sourceCode), 0o644); err != nil {
+		t.Fatalf("error creating the test file: %s", err)
+	}
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, true)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	report, err := CheckFile(ctx, path, "main")
+	if err != nil {
+		t.Fatalf("error checking the file: %s", err)
+	}
+
+	assert.True(t, report.Modified, "The file should be reported as out of date.")
+	assert.Contains(t, report.Diff, "+import \"github.com/autometrics-dev/autometrics-go/prometheus/autometrics\"")
+	assert.Contains(t, report.Diff, "+\t)), nil) //autometrics:defer")
+
+	actual, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading the test file: %s", err)
+	}
+	assert.Equal(t, sourceCode, string(actual), "Check mode must not write the file.")
+
+	ctx.ResetFileCtx()
+	if err := TransformFile(ctx, path, "main"); err != nil {
+		t.Fatalf("error transforming the file: %s", err)
+	}
+
+	ctx.ResetFileCtx()
+	report, err = CheckFile(ctx, path, "main")
+	if err != nil {
+		t.Fatalf("error checking the file: %s", err)
+	}
+
+	assert.False(t, report.Modified, "The file should be up-to-date after generation.")
+	assert.Empty(t, report.Diff)
+}
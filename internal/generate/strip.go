@@ -0,0 +1,196 @@
+package generate // import "github.com/autometrics-dev/autometrics-go/internal/generate"
+
+import (
+	"fmt"
+	"strings"
+
+	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+)
+
+// StripFile removes all the instrumentation generated by autometrics from a file, and replaces
+// it in place: the defer statements, the generated documentation, and the imports that are not
+// used anymore. The autometrics directives are also removed if removeDirectives is true.
+func StripFile(path string, removeDirectives bool) (FileReport, error) {
+	return stripFile(path, removeDirectives, false)
+}
+
+// CheckStripFile runs the removal of [StripFile] in memory, without writing the file.
+//
+// See [CheckFile] for the content of the report.
+func CheckStripFile(path string, removeDirectives bool) (FileReport, error) {
+	return stripFile(path, removeDirectives, true)
+}
+
+// StripPackages removes all the instrumentation generated by autometrics from the packages
+// matching the patterns (relative to dir), like [StripFile] does.
+//
+// The same files as in [TransformPackages] are stripped. The returned reports are sorted by path.
+func StripPackages(dir string, patterns []string, removeDirectives bool) ([]FileReport, error) {
+	return stripPackages(dir, patterns, removeDirectives, false)
+}
+
+// CheckStripPackages runs the removal of [StripPackages] in memory, without writing any file.
+//
+// See [CheckFile] for the content of the reports.
+func CheckStripPackages(dir string, patterns []string, removeDirectives bool) ([]FileReport, error) {
+	return stripPackages(dir, patterns, removeDirectives, true)
+}
+
+func stripPackages(dir string, patterns []string, removeDirectives, dryRun bool) ([]FileReport, error) {
+	files, err := packageFiles(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]FileReport, 0, len(files))
+	for _, file := range files {
+		report, err := stripFile(file.path, removeDirectives, dryRun)
+		if err != nil {
+			return reports, fmt.Errorf("error stripping %s: %w", file.path, err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+func stripFile(path string, removeDirectives, dryRun bool) (FileReport, error) {
+	return rewriteFile(path, dryRun, func(sourceCode string) (string, int, error) {
+		strippedSource, stripped, err := stripSource(sourceCode, removeDirectives)
+		if err != nil {
+			return "", 0, fmt.Errorf("error stripping the instrumentation: %w", err)
+		}
+		return strippedSource, stripped, nil
+	})
+}
+
+// stripSource removes the generated instrumentation from the source code, and returns the new source
+// code along with the number of functions that had instrumentation or a directive.
+func stripSource(sourceCode string, removeDirectives bool) (string, int, error) {
+	fileTree, err := decorator.Parse(sourceCode)
+	if err != nil {
+		return "", 0, fmt.Errorf("error parsing source code: %w", err)
+	}
+
+	usedBefore := usedImportNames(fileTree)
+
+	var stripErr error
+	var stripped int
+
+	dst.Inspect(fileTree, func(node dst.Node) bool {
+		funcDeclaration, ok := node.(*dst.FuncDecl)
+		if !ok {
+			return stripErr == nil
+		}
+
+		var modified bool
+		modified, stripErr = stripFuncDeclaration(funcDeclaration, removeDirectives)
+		if stripErr != nil {
+			stripErr = fmt.Errorf("error stripping %v: %w", funcDeclaration.Name.Name, stripErr)
+			return false
+		}
+		if modified {
+			stripped++
+		}
+
+		return true
+	})
+
+	if stripErr != nil {
+		return "", 0, stripErr
+	}
+
+	// Keep the formatting of the files that have nothing to strip
+	if stripped == 0 {
+		return sourceCode, 0, nil
+	}
+
+	removeUnusedImports(fileTree, usedBefore)
+
+	var buf strings.Builder
+
+	err = decorator.Fprint(&buf, fileTree)
+	if err != nil {
+		return "", 0, fmt.Errorf("error writing the AST to buffer: %w", err)
+	}
+
+	return buf.String(), stripped, nil
+}
+
+// stripFuncDeclaration removes the generated defer statement and documentation of a function, and
+// its directive if removeDirectives is true. It returns true if the function had any of them.
+func stripFuncDeclaration(funcDeclaration *dst.FuncDecl, removeDirectives bool) (bool, error) {
+	var modified bool
+
+	original := len(funcDeclaration.Decorations().Start.All())
+	// The documentation generator is not needed, the links of all the known generators are removed.
+	docComments, err := cleanUpAutometricsComments(internal.GeneratorContext{}, funcDeclaration)
+	if err != nil {
+		return false, err
+	}
+	if len(docComments) != original {
+		modified = true
+		docComments = removeGoplsLine(docComments, funcDeclaration.Name.Name)
+	}
+
+	if removeDirectives {
+		withoutDirectives := filter(docComments, func(comment string) bool {
+			return !strings.HasPrefix(comment, "//autometrics:")
+		})
+		if len(withoutDirectives) != len(docComments) {
+			modified = true
+			withoutDirectives = trimEmptyCommentLines(withoutDirectives)
+		}
+		docComments = withoutDirectives
+	}
+
+	funcDeclaration.Decorations().Start.Replace(docComments...)
+
+	if funcDeclaration.Body != nil && len(funcDeclaration.Body.List) > 0 && isAutometricsDeferStatement(funcDeclaration.Body.List[0]) {
+		err = removeDeferStatement(nil, funcDeclaration)
+		if err != nil {
+			return false, err
+		}
+		modified = true
+
+		// The generated defer statement is followed by an empty line, that should not be left behind
+		if len(funcDeclaration.Body.List) > 0 {
+			funcDeclaration.Body.List[0].Decorations().Before = dst.NewLine
+		} else {
+			funcDeclaration.Body.Decs.Lbrace.Append("\n")
+		}
+	}
+
+	return modified, nil
+}
+
+// removeGoplsLine removes the line with only the function name that the generator adds
+// when the generated documentation starts the doc comment (see generateDocumentationAndInstrumentation).
+func removeGoplsLine(docComments []string, funcName string) []string {
+	directiveIndex := len(docComments)
+	for i, comment := range docComments {
+		if strings.HasPrefix(comment, "//autometrics:") {
+			directiveIndex = i
+			break
+		}
+	}
+
+	header := trimEmptyCommentLines(docComments[:directiveIndex])
+	if len(header) == 1 && header[0] == fmt.Sprintf("// %s", funcName) {
+		return docComments[directiveIndex:]
+	}
+
+	return docComments
+}
+
+// trimEmptyCommentLines removes the trailing empty lines of a comment, that are used as separators.
+func trimEmptyCommentLines(comments []string) []string {
+	for len(comments) > 0 && strings.TrimSpace(comments[len(comments)-1]) == "//" {
+		comments = comments[:len(comments)-1]
+	}
+
+	return comments
+}
@@ -0,0 +1,194 @@
+package generate
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+	"github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+// TestStripRoundTrip tests that stripping the generated code gives back the original source code,
+// without the autometrics import that is not used anymore.
+func TestStripRoundTrip(t *testing.T) {
+	sourceCode := `// This is the package comment.
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --slo "API" --success-target 99
+func main(ctx context.Context) (err error) {
+	fmt.Println(hello) // line comment 3
+	return nil
+}
+
+//autometrics:doc
+func documented() {
+}
+`
+
+	want := `// This is the package comment.
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// This comment is associated with the main function.
+//
+//autometrics:inst --slo "API" --success-target 99
+func main(ctx context.Context) (err error) {
+	fmt.Println(hello) // line comment 3
+	return nil
+}
+
+//autometrics:doc
+func documented() {
+}
+`
+
+	ctx, err := internal.NewGeneratorContext(autometrics.PROMETHEUS, defaultPrometheusInstanceUrl, false, false)
+	if err != nil {
+		t.Fatalf("error creating the generation context: %s", err)
+	}
+
+	generated, err := GenerateDocumentationAndInstrumentation(ctx, sourceCode, "main")
+	if err != nil {
+		t.Fatalf("error generating the documentation: %s", err)
+	}
+
+	actual, stripped, err := stripSource(generated, false)
+	if err != nil {
+		t.Fatalf("error stripping the instrumentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The stripped source code is not as expected.")
+	assert.Equal(t, 2, stripped, "Both functions had generated code.")
+
+	actual, stripped, err = stripSource(actual, false)
+	if err != nil {
+		t.Fatalf("error stripping the instrumentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "Stripping the code again must not change it.")
+	assert.Equal(t, 0, stripped)
+}
+
+// TestStripDirectives tests that the directives and the imports only used by the generated code are removed.
+func TestStripDirectives(t *testing.T) {
+	sourceCode := `package main
+
+import (
+	"fmt"
+	"time"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+func init() {
+	prom.Init(nil, prom.DefBuckets, prom.BuildInfo{}, nil)
+}
+
+// main
+//
+//	autometrics:doc-start Generated documentation by Autometrics.
+//
+// # Autometrics
+//
+// # Prometheus
+//
+// View the live metrics for the ` + "`main`" + ` function:
+//   - [Request Rate]
+//
+//	autometrics:doc-end Generated documentation by Autometrics.
+//
+// [Request Rate]: http://localhost:9090/graph
+//
+//autometrics:inst --slo "API" --latency-target 99.9 --latency-ms 500
+func main() {
+	defer prom.Instrument(prom.PreInstrument(prom.NewContext(
+		nil,
+		prom.WithConcurrentCalls(true),
+		prom.WithCallerName(true),
+		prom.WithSloName("API"),
+		prom.WithAlertLatency(500000000*time.Nanosecond, 99.9),
+	)), nil) //autometrics:defer
+
+	fmt.Println(hello)
+}
+
+// Helper does nothing.
+//
+//autometrics:doc
+func Helper() {
+}
+`
+
+	want := `package main
+
+import (
+	"fmt"
+
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+func init() {
+	prom.Init(nil, prom.DefBuckets, prom.BuildInfo{}, nil)
+}
+
+func main() {
+	fmt.Println(hello)
+}
+
+// Helper does nothing.
+func Helper() {
+}
+`
+
+	actual, stripped, err := stripSource(sourceCode, true)
+	if err != nil {
+		t.Fatalf("error stripping the instrumentation: %s", err)
+	}
+
+	assert.Equal(t, want, actual, "The stripped source code is not as expected.")
+	assert.Equal(t, 2, stripped)
+
+	_, err = parser.ParseFile(token.NewFileSet(), "main.go", actual, parser.AllErrors)
+	assert.NoError(t, err, "The stripped source code must be valid Go code.")
+}
+
+// TestStripUntouched tests that files without autometrics code are left untouched.
+func TestStripUntouched(t *testing.T) {
+	sourceCode := `package main
+
+import (
+	"fmt"
+	_ "embed"
+	unused "github.com/example/unused"
+)
+
+// main does nothing.
+func main() {
+	defer fmt.Println("done")
+}
+`
+
+	actual, stripped, err := stripSource(sourceCode, true)
+	if err != nil {
+		t.Fatalf("error stripping the instrumentation: %s", err)
+	}
+
+	assert.Equal(t, sourceCode, actual)
+	assert.Equal(t, 0, stripped)
+}
@@ -2,6 +2,8 @@ package generate // import "github.com/autometrics-dev/autometrics-go/internal/g
 
 import (
 	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
 )
 
 // Backport of strings.CutPrefix for pre-1.20
@@ -20,3 +22,14 @@ func filter(ss []string, test func(string) bool) (ret []string) {
 	}
 	return
 }
+
+// unifiedDiff returns the unified diff between the original and the transformed version of the file at path.
+func unifiedDiff(path, original, transformed string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(original),
+		B:        difflib.SplitLines(transformed),
+		FromFile: path,
+		ToFile:   path + " (generated)",
+		Context:  3,
+	})
+}
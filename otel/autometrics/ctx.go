@@ -41,6 +41,38 @@ func WithCallerName(enabled bool) autometrics.Option {
 	return autometrics.WithCallerName(enabled)
 }
 
+func WithFunctionName(name string) autometrics.Option {
+	return autometrics.WithFunctionName(name)
+}
+
+func WithModuleName(name string) autometrics.Option {
+	return autometrics.WithModuleName(name)
+}
+
 func WithValidHttpCodes(ranges []ValidHttpRange) autometrics.Option {
 	return autometrics.WithValidHttpCodes(ranges)
 }
+
+func WithErrorClassifier(classifier ErrorClassifier) autometrics.Option {
+	return autometrics.WithErrorClassifier(classifier)
+}
+
+func WithDefaultErrorClassifier(classifier ErrorClassifier) InitOption {
+	return autometrics.WithDefaultErrorClassifier(classifier)
+}
+
+func WithErrorTypes(types ...ErrorType) InitOption {
+	return autometrics.WithErrorTypes(types...)
+}
+
+func WithSyntheticTraceIDs(enabled bool) InitOption {
+	return autometrics.WithSyntheticTraceIDs(enabled)
+}
+
+func ErrorSentinel(name string, target error) ErrorType {
+	return autometrics.ErrorSentinel(name, target)
+}
+
+func ErrorAs[T error](name string) ErrorType {
+	return autometrics.ErrorAs[T](name)
+}
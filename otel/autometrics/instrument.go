@@ -2,12 +2,9 @@ package autometrics // import "github.com/autometrics-dev/autometrics-go/otel/au
 
 import (
 	"context"
-	"strconv"
 	"time"
 
 	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
-	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/metric"
 )
 
 // Instrument called in a defer statement wraps the body of a function
@@ -15,80 +12,69 @@ import (
 //
 // The first argument SHOULD be a call to PreInstrument so that
 // the "concurrent calls" gauge is correctly setup.
+//
+// If the function panics, the call is recorded as an error (so it counts
+// against the success rate objectives), and Instrument panics again with
+// the same value. Instrument must then be the deferred function itself,
+// and not be called from another deferred function.
 func Instrument(ctx context.Context, err *error) {
-	if amCtx.Err() != nil {
-		return
+	if recovered := recover(); recovered != nil {
+		instrument(ctx, &am.PanicError{Value: recovered})
+		panic(recovered)
 	}
 
-	result := "ok"
-
-	if err != nil && *err != nil {
-		result = "error"
+	var resultErr error
+	if err != nil {
+		resultErr = *err
 	}
 
-	var sloName, latencyTarget, latencyObjective, successObjective string
+	instrument(ctx, resultErr)
+}
+
+// InstrumentCustomError is [Instrument] for functions whose error result has a custom type implementing
+// error, like *ValidationError. The generator uses it instead of Instrument for those functions.
+//
+// A nil pointer of the custom type is a successful call, see [am.CustomErrorValue].
+func InstrumentCustomError[E any](ctx context.Context, err *E) {
+	// recover only stops the panic when called directly by the deferred function,
+	// so this cannot be delegated to Instrument.
+	if recovered := recover(); recovered != nil {
+		instrument(ctx, &am.PanicError{Value: recovered})
+		panic(recovered)
+	}
 
-	callInfo := am.GetCallInfo(ctx)
-	buildInfo := am.GetBuildInfo(ctx)
-	slo := am.GetAlertConfiguration(ctx)
+	var resultErr error
+	if err != nil {
+		resultErr = am.CustomErrorValue(*err)
+	}
 
-	if slo.ServiceName != "" {
-		sloName = slo.ServiceName
+	instrument(ctx, resultErr)
+}
 
-		if slo.Latency != nil {
-			latencyTarget = strconv.FormatFloat(slo.Latency.Target.Seconds(), 'f', -1, 64)
-			latencyObjective = strconv.FormatFloat(slo.Latency.Objective, 'f', -1, 64)
-		}
+// instrument records the call of the function with the error it returned.
+func instrument(ctx context.Context, err error) {
+	if amCtx.Err() != nil {
+		return
+	}
 
-		if slo.Success != nil {
-			successObjective = strconv.FormatFloat(slo.Success.Objective, 'f', -1, 64)
-		}
+	result := am.ClassifyError(ctx, err)
+	var errorType string
+	if result == am.ResultError {
+		errorType = am.ErrorTypeOf(err)
 	}
 
-	functionCallsCount.Add(ctx, 1,
-		metric.WithAttributes([]attribute.KeyValue{
-			attribute.Key(FunctionLabel).String(callInfo.FuncName),
-			attribute.Key(ModuleLabel).String(callInfo.ModuleName),
-			attribute.Key(CallerFunctionLabel).String(callInfo.ParentFuncName),
-			attribute.Key(CallerModuleLabel).String(callInfo.ParentModuleName),
-			attribute.Key(ResultLabel).String(result),
-			attribute.Key(TargetSuccessRateLabel).String(successObjective),
-			attribute.Key(SloNameLabel).String(sloName),
-			attribute.Key(CommitLabel).String(buildInfo.Commit),
-			attribute.Key(VersionLabel).String(buildInfo.Version),
-			attribute.Key(BranchLabel).String(buildInfo.Branch),
-			attribute.Key(ServiceNameLabel).String(buildInfo.Service),
-			attribute.Key(JobNameLabel).String(am.GetPushJobName()),
-		}...))
-	functionCallsDuration.Record(ctx, time.Since(am.GetStartTime(ctx)).Seconds(),
-		metric.WithAttributes([]attribute.KeyValue{
-			attribute.Key(FunctionLabel).String(callInfo.FuncName),
-			attribute.Key(ModuleLabel).String(callInfo.ModuleName),
-			attribute.Key(CallerFunctionLabel).String(callInfo.ParentFuncName),
-			attribute.Key(CallerModuleLabel).String(callInfo.ParentModuleName),
-			attribute.Key(TargetLatencyLabel).String(latencyTarget),
-			attribute.Key(TargetSuccessRateLabel).String(latencyObjective),
-			attribute.Key(SloNameLabel).String(sloName),
-			attribute.Key(CommitLabel).String(buildInfo.Commit),
-			attribute.Key(VersionLabel).String(buildInfo.Version),
-			attribute.Key(BranchLabel).String(buildInfo.Branch),
-			attribute.Key(ServiceNameLabel).String(buildInfo.Service),
-			attribute.Key(JobNameLabel).String(am.GetPushJobName()),
-		}...))
+	key := am.GetSeriesKey(ctx)
+
+	// Ignored calls are neither successes nor failures, only the concurrent calls are tracked for them.
+	if result != am.ResultIgnored {
+		functionCallsCount.Add(ctx, 1,
+			callsCountSeries.Get(callsKey{SeriesKey: key, result: result, errorType: errorType}, newCallsCountSeries)...)
+		functionCallsDuration.Record(ctx, time.Since(am.GetStartTime(ctx)).Seconds(),
+			callsDurationSeries.Get(key, newCallsDurationSeries)...)
+	}
 
 	if am.GetTrackConcurrentCalls(ctx) {
-		functionCallsConcurrent.Add(ctx, -1,
-			metric.WithAttributes([]attribute.KeyValue{
-				attribute.Key(FunctionLabel).String(callInfo.FuncName),
-				attribute.Key(ModuleLabel).String(callInfo.ModuleName),
-				attribute.Key(CallerFunctionLabel).String(callInfo.ParentFuncName),
-				attribute.Key(CallerModuleLabel).String(callInfo.ParentModuleName),
-				attribute.Key(CommitLabel).String(buildInfo.Commit),
-				attribute.Key(VersionLabel).String(buildInfo.Version),
-				attribute.Key(BranchLabel).String(buildInfo.Branch),
-				attribute.Key(ServiceNameLabel).String(buildInfo.Service),
-				attribute.Key(JobNameLabel).String(am.GetPushJobName()),
-			}...))
+		functionCallsConcurrent.Add(ctx, -1, concurrentCalls(key)...)
 	}
 }
 
@@ -101,28 +87,12 @@ func PreInstrument(ctx context.Context) context.Context {
 		return nil
 	}
 
-	callInfo := am.CallerInfo()
-	ctx = am.SetCallInfo(ctx, callInfo)
-	ctx = am.FillBuildInfo(ctx)
-	ctx = am.FillTracingInfo(ctx)
+	callInfo := am.ResolveCallInfo(ctx)
+	ctx = am.PrepareCall(ctx, callInfo)
 
 	if am.GetTrackConcurrentCalls(ctx) {
-		buildInfo := am.GetBuildInfo(ctx)
-		functionCallsConcurrent.Add(ctx, 1,
-			metric.WithAttributes([]attribute.KeyValue{
-				attribute.Key(FunctionLabel).String(callInfo.FuncName),
-				attribute.Key(ModuleLabel).String(callInfo.ModuleName),
-				attribute.Key(CallerFunctionLabel).String(callInfo.ParentFuncName),
-				attribute.Key(CallerModuleLabel).String(callInfo.ParentModuleName),
-				attribute.Key(CommitLabel).String(buildInfo.Commit),
-				attribute.Key(VersionLabel).String(buildInfo.Version),
-				attribute.Key(BranchLabel).String(buildInfo.Branch),
-				attribute.Key(ServiceNameLabel).String(buildInfo.Service),
-				attribute.Key(JobNameLabel).String(am.GetPushJobName()),
-			}...))
+		functionCallsConcurrent.Add(ctx, 1, concurrentCalls(am.GetSeriesKey(ctx))...)
 	}
 
-	ctx = am.SetStartTime(ctx, time.Now())
-
 	return ctx
 }
@@ -0,0 +1,48 @@
+package autometrics
+
+import (
+	"context"
+	"testing"
+
+	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRecordingDoesNotAllocate makes sure that recording the calls of a function does not allocate
+// once its attribute sets are resolved, with the default configuration: the synthetic trace IDs are
+// not attached to the measurements.
+func TestRecordingDoesNotAllocate(t *testing.T) {
+	shutdown, err := Init("autometrics-test", DefBuckets, BuildInfo{}, nil)
+	if err != nil {
+		t.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	ctx := PreInstrument(NewContext(context.Background(), WithSloName("API"), WithAlertSuccess(99.9)))
+	instrument(ctx, nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		functionCallsConcurrent.Add(ctx, 1, concurrentCalls(am.GetSeriesKey(ctx))...)
+		instrument(ctx, nil)
+	})
+
+	assert.Zero(t, allocs)
+}
+
+// BenchmarkRecordCall measures the recording of the metrics of a call, once its attribute sets are resolved.
+func BenchmarkRecordCall(b *testing.B) {
+	shutdown, err := Init("autometrics-test", DefBuckets, BuildInfo{}, nil)
+	if err != nil {
+		b.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	ctx := PreInstrument(NewContext(context.Background(), WithSloName("API"), WithAlertSuccess(99.9)))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		functionCallsConcurrent.Add(ctx, 1, concurrentCalls(am.GetSeriesKey(ctx))...)
+		instrument(ctx, nil)
+	}
+}
@@ -32,6 +32,9 @@ var (
 	amCtx              context.Context
 	exporterLock       sync.Mutex
 	pushPeriodicReader *metric.PeriodicReader
+	// pullDuration is set when the metrics are exported to Prometheus instead of pushed, in which case
+	// the duration histogram has no job attribute.
+	pullDuration bool
 )
 
 const (
@@ -62,6 +65,10 @@ const (
 	CallerModuleLabel = "caller.module"
 	// ResultLabel is the openTelemetry attribute that describes whether a function call is successful.
 	ResultLabel = "result"
+	// ErrorTypeLabel is the openTelemetry attribute that describes the type of the error of a failed call.
+	//
+	// It is only added to the calls counter when enabled with [WithErrorTypes].
+	ErrorTypeLabel = "error.type"
 	// TargetLatencyLabel is the openTelemetry attribute that describes the latency to respect to match
 	// the Service Level Objective.
 	TargetLatencyLabel = "objective.latency_threshold"
@@ -105,6 +112,36 @@ func completeMeterName(meterName string) string {
 // the current (otel) package imported at the call site.
 type BuildInfo = autometrics.BuildInfo
 
+// InitOption is an option of the process-wide configuration set in [Init].
+//
+// This is a reexport of the autometrics type to allow [Init] to work with only
+// the current (otel) package imported at the call site.
+type InitOption = autometrics.InitOption
+
+// Result is the outcome of a call of an instrumented function, decided by an [ErrorClassifier].
+//
+// This is a reexport of the autometrics type to allow classifiers to work with only
+// the current (otel) package imported.
+type Result = autometrics.Result
+
+const (
+	ResultOk      = autometrics.ResultOk
+	ResultError   = autometrics.ResultError
+	ResultIgnored = autometrics.ResultIgnored
+)
+
+// ErrorClassifier decides the [Result] of a call of an instrumented function from the non-nil error it returned.
+//
+// This is a reexport of the autometrics type to allow classifiers to work with only
+// the current (otel) package imported.
+type ErrorClassifier = autometrics.ErrorClassifier
+
+// ErrorType is a kind of error, recorded in the [ErrorTypeLabel] when enabled with [WithErrorTypes].
+//
+// This is a reexport of the autometrics type to allow [Init] to work with only
+// the current (otel) package imported at the call site.
+type ErrorType = autometrics.ErrorType
+
 // PushConfiguration holds meta information about the push-to-collector configuration of the instrumented code.
 type PushConfiguration struct {
 	// URL of the collector to push to. It must be non-empty if this struct is built.
@@ -165,7 +202,10 @@ type PushConfiguration struct {
 // Make sure that all the latency targets you want to use for SLOs are
 // present in the histogramBuckets array, otherwise the alerts will fail
 // to work (they will never trigger).
-func Init(meterName string, histogramBuckets []float64, buildInformation BuildInfo, pushConfiguration *PushConfiguration) (context.CancelCauseFunc, error) {
+//
+// The initOpts set the process-wide configuration, like the default [ErrorClassifier]
+// with [WithDefaultErrorClassifier].
+func Init(meterName string, histogramBuckets []float64, buildInformation BuildInfo, pushConfiguration *PushConfiguration, initOpts ...InitOption) (context.CancelCauseFunc, error) {
 	var err error
 	newCtx, cancelFunc := context.WithCancelCause(context.Background())
 	amCtx = newCtx
@@ -174,6 +214,13 @@ func Init(meterName string, histogramBuckets []float64, buildInformation BuildIn
 	autometrics.SetVersion(buildInformation.Version)
 	autometrics.SetBranch(buildInformation.Branch)
 
+	autometrics.SetDefaultErrorClassifier(nil)
+	autometrics.SetErrorTypes(nil, false)
+	autometrics.SetSyntheticTraceIDs(true)
+	for _, opt := range initOpts {
+		opt.ApplyInit()
+	}
+
 	var pushExporter metric.Exporter
 	if pushConfiguration != nil {
 		pushExporter, err = initPushExporter(pushConfiguration)
@@ -196,6 +243,8 @@ func Init(meterName string, histogramBuckets []float64, buildInformation BuildIn
 	}
 	meter := provider.Meter(completeMeterName(meterName))
 
+	pullDuration = pushConfiguration == nil
+	resetSeries()
 	functionCallsCount, err = meter.Int64Counter(FunctionCallsCountName, instruments.WithDescription("The number of times the function has been called"))
 	if err != nil {
 		return nil, fmt.Errorf("error initializing %v metric: %w", FunctionCallsCountName, err)
@@ -292,8 +341,6 @@ func initProvider(pushExporter metric.Exporter, pushConfiguration *PushConfigura
 			return nil, fmt.Errorf("error initializing prometheus exporter: %w", err)
 		}
 
-		streamView.AttributeFilter = attribute.NewDenyKeysFilter(attribute.Key(JobNameLabel))
-
 		metricView := metric.NewView(
 			instrumentView,
 			streamView,
@@ -0,0 +1,118 @@
+package autometrics // import "github.com/autometrics-dev/autometrics-go/otel/autometrics"
+
+import (
+	"strconv"
+
+	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// callsKey identifies a series of the calls counter.
+type callsKey struct {
+	am.SeriesKey
+	result    am.Result
+	errorType string
+}
+
+// The attribute sets of the series are built once per function, caller and objective, and kept as
+// measurement options, so that recording a call neither builds nor sorts the attributes. The caches
+// are reset in Init.
+var (
+	callsCountSeries      am.SeriesCache[callsKey, []metric.AddOption]
+	callsDurationSeries   am.SeriesCache[am.SeriesKey, []metric.RecordOption]
+	callsConcurrentSeries am.SeriesCache[am.SeriesKey, []metric.AddOption]
+)
+
+func resetSeries() {
+	callsCountSeries.Reset()
+	callsDurationSeries.Reset()
+	callsConcurrentSeries.Reset()
+}
+
+// concurrentCalls returns the options of the series of the concurrent calls counter of the function.
+func concurrentCalls(key am.SeriesKey) []metric.AddOption {
+	// The objective is not an attribute of the counter.
+	return callsConcurrentSeries.Get(am.SeriesKey{CallInfo: key.CallInfo, BuildInfo: key.BuildInfo}, newConcurrentCallsSeries)
+}
+
+func newCallsCountSeries(key callsKey) []metric.AddOption {
+	sloName, _, _, successObjective := sloLabels(key.SeriesKey)
+
+	attributes := []attribute.KeyValue{
+		attribute.Key(FunctionLabel).String(key.CallInfo.FuncName),
+		attribute.Key(ModuleLabel).String(key.CallInfo.ModuleName),
+		attribute.Key(CallerFunctionLabel).String(key.CallInfo.ParentFuncName),
+		attribute.Key(CallerModuleLabel).String(key.CallInfo.ParentModuleName),
+		attribute.Key(ResultLabel).String(key.result.String()),
+		attribute.Key(TargetSuccessRateLabel).String(successObjective),
+		attribute.Key(SloNameLabel).String(sloName),
+		attribute.Key(CommitLabel).String(key.BuildInfo.Commit),
+		attribute.Key(VersionLabel).String(key.BuildInfo.Version),
+		attribute.Key(BranchLabel).String(key.BuildInfo.Branch),
+		attribute.Key(ServiceNameLabel).String(key.BuildInfo.Service),
+		attribute.Key(JobNameLabel).String(am.GetPushJobName()),
+	}
+	if _, enabled := am.GetErrorTypes(); enabled {
+		attributes = append(attributes, attribute.Key(ErrorTypeLabel).String(key.errorType))
+	}
+
+	return []metric.AddOption{metric.WithAttributeSet(attribute.NewSet(attributes...))}
+}
+
+func newCallsDurationSeries(key am.SeriesKey) []metric.RecordOption {
+	sloName, latencyTarget, latencyObjective, _ := sloLabels(key)
+
+	attributes := []attribute.KeyValue{
+		attribute.Key(FunctionLabel).String(key.CallInfo.FuncName),
+		attribute.Key(ModuleLabel).String(key.CallInfo.ModuleName),
+		attribute.Key(CallerFunctionLabel).String(key.CallInfo.ParentFuncName),
+		attribute.Key(CallerModuleLabel).String(key.CallInfo.ParentModuleName),
+		attribute.Key(TargetLatencyLabel).String(latencyTarget),
+		attribute.Key(TargetSuccessRateLabel).String(latencyObjective),
+		attribute.Key(SloNameLabel).String(sloName),
+		attribute.Key(CommitLabel).String(key.BuildInfo.Commit),
+		attribute.Key(VersionLabel).String(key.BuildInfo.Version),
+		attribute.Key(BranchLabel).String(key.BuildInfo.Branch),
+		attribute.Key(ServiceNameLabel).String(key.BuildInfo.Service),
+	}
+	// The job attribute is left out here rather than filtered out by a view of the histogram, as the
+	// filter would allocate a new set on every measurement.
+	if !pullDuration {
+		attributes = append(attributes, attribute.Key(JobNameLabel).String(am.GetPushJobName()))
+	}
+
+	return []metric.RecordOption{metric.WithAttributeSet(attribute.NewSet(attributes...))}
+}
+
+func newConcurrentCallsSeries(key am.SeriesKey) []metric.AddOption {
+	return []metric.AddOption{metric.WithAttributeSet(attribute.NewSet(
+		attribute.Key(FunctionLabel).String(key.CallInfo.FuncName),
+		attribute.Key(ModuleLabel).String(key.CallInfo.ModuleName),
+		attribute.Key(CallerFunctionLabel).String(key.CallInfo.ParentFuncName),
+		attribute.Key(CallerModuleLabel).String(key.CallInfo.ParentModuleName),
+		attribute.Key(CommitLabel).String(key.BuildInfo.Commit),
+		attribute.Key(VersionLabel).String(key.BuildInfo.Version),
+		attribute.Key(BranchLabel).String(key.BuildInfo.Branch),
+		attribute.Key(ServiceNameLabel).String(key.BuildInfo.Service),
+		attribute.Key(JobNameLabel).String(am.GetPushJobName()),
+	))}
+}
+
+// sloLabels returns the values of the objective attributes of the series.
+func sloLabels(key am.SeriesKey) (sloName, latencyTarget, latencyObjective, successObjective string) {
+	if key.SloName == "" {
+		return
+	}
+
+	sloName = key.SloName
+	if key.HasLatency {
+		latencyTarget = strconv.FormatFloat(key.LatencyTarget.Seconds(), 'f', -1, 64)
+		latencyObjective = strconv.FormatFloat(key.LatencyObjective, 'f', -1, 64)
+	}
+	if key.HasSuccess {
+		successObjective = strconv.FormatFloat(key.SuccessObjective, 'f', -1, 64)
+	}
+
+	return
+}
@@ -16,6 +16,11 @@ func DecodeString(s string) []byte {
 	return res
 }
 
+// Convenience re-export of [autometrics.DecodeValue] to allow generating code without touching imports in instrumented file.
+func DecodeValue(v interface{}) []byte {
+	return autometrics.DecodeValue(v)
+}
+
 // Convenience re-export of [autometrics.WithNewTraceId] to avoid needing multiple imports in instrumented file.
 func WithNewTraceId(ctx context.Context) context.Context {
 	return autometrics.WithNewTraceId(ctx)
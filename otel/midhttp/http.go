@@ -12,15 +12,23 @@ import (
 func Autometrics(next http.HandlerFunc, opts ...am.Option) http.HandlerFunc {
 	fn := func(rw http.ResponseWriter, r *http.Request) {
 		arw := mid.NewResponseWriter(rw)
-		ctx := otel.PreInstrument(otel.NewContext(r.Context(), opts...))
+		// The tracing headers of the request are read before the options, so that the options
+		// can still override the IDs.
+		ctx := mid.ExtractTracingInfo(r.Context(), r.Header)
+		ctx = otel.PreInstrument(otel.NewContext(ctx, opts...))
 
 		// Compute then set the function name and module name labels
 		ctx = am.SetCallInfo(ctx, am.ReflectFunctionModuleName(next))
 
 		err := errors.New("Unfinished handler")
 
+		// Instrument is deferred directly, so that it records a panic of the handler as an error
+		// before propagating it.
 		defer otel.Instrument(ctx, &err)
 
+		// The response carries the span of the request, so that callers can link to its exemplars.
+		mid.InjectTracingInfo(ctx, arw.Header())
+
 		r = r.WithContext(ctx)
 		next.ServeHTTP(arw, r)
 
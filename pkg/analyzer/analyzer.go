@@ -0,0 +1,465 @@
+// Package analyzer defines an [analysis.Analyzer] that reports the problems of the autometrics
+// directives, and of the code generated for them.
+//
+// The analyzer runs with the `autometrics-vet` command, either on its own or through
+// `go vet -vettool=$(which autometrics-vet)`, and in any other driver of the
+// [golang.org/x/tools/go/analysis] framework, like gopls. The changes the generator would make
+// are offered as suggested fixes, so that editors can apply them in place.
+//
+// The directives are validated with the settings of the configuration file of the project (see
+// the documentation of the autometrics command), so the generator must not be invoked with
+// flags that change the generated code.
+package analyzer // import "github.com/autometrics-dev/autometrics-go/pkg/analyzer"
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	internal "github.com/autometrics-dev/autometrics-go/internal/autometrics"
+	"github.com/autometrics-dev/autometrics-go/internal/generate"
+)
+
+const doc = `report problems in autometrics directives and generated code
+
+The analyzer reports:
+- arguments of the autometrics directives that the generator does not know, and ignores,
+- generated documentation and defer statements that do not match the directive anymore,
+- instrumented functions taking a context.Context that is not passed to autometrics.`
+
+// Analyzer reports problems in the autometrics directives and the generated code.
+var Analyzer = &analysis.Analyzer{
+	Name: "autometrics",
+	Doc:  doc,
+	Run:  run,
+}
+
+// configPath is the configuration file to use instead of looking for one from the analyzed files.
+var configPath string
+
+func init() {
+	Analyzer.Flags.StringVar(&configPath, "config", "", "autometrics configuration file to use. By default, the first one found in the directory of the analyzed file or its parents is used.")
+}
+
+// knownArguments are the arguments accepted in the autometrics directives.
+var knownArguments = []string{
+	generate.SloNameArgument,
+	generate.SuccessObjArgument,
+	generate.LatencyMsArgument,
+	generate.LatencyObjArgument,
+	generate.NoDocArgument,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	configs := make(map[string]internal.Config)
+
+	for _, file := range pass.Files {
+		path := pass.Fset.File(file.Pos()).Name()
+		// Test files are never transformed by the generator.
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		cfg, err := loadConfig(configs, filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+		ctx, err := internal.NewGeneratorContextFromConfig(cfg, false)
+		if err != nil {
+			return nil, fmt.Errorf("error initialising autometrics context: %w", err)
+		}
+
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the source code from %s: %w", path, err)
+		}
+
+		checkFile(pass, ctx, file, path, source)
+	}
+
+	return nil, nil
+}
+
+// loadConfig returns the configuration that applies to the files in dir, caching the configurations
+// by directory.
+func loadConfig(configs map[string]internal.Config, dir string) (internal.Config, error) {
+	if cfg, ok := configs[dir]; ok {
+		return cfg, nil
+	}
+
+	var cfg internal.Config
+	path := configPath
+	if path == "" {
+		var err error
+		path, err = internal.FindConfig(dir)
+		if err != nil {
+			return cfg, fmt.Errorf("error looking for a configuration file: %w", err)
+		}
+	}
+
+	if path != "" {
+		var err error
+		cfg, err = internal.LoadConfig(path)
+		if err != nil {
+			return cfg, fmt.Errorf("error loading the configuration: %w", err)
+		}
+	}
+	// Same default as the generator
+	if cfg.PrometheusUrl == "" {
+		cfg.PrometheusUrl = internal.DefaultPrometheusUrl
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	configs[dir] = cfg
+	return cfg, nil
+}
+
+func checkFile(pass *analysis.Pass, ctx internal.GeneratorContext, file *ast.File, path string, source []byte) {
+	moduleName := pass.Pkg.Name()
+	ctx.PackagePath = pass.Pkg.Path()
+	ctx.ErrorResults = generate.ErrorResults(file, pass.TypesInfo)
+
+	functions, err := generate.InspectSource(ctx, path, string(source), moduleName)
+	if err != nil {
+		pass.Reportf(file.Name.Pos(), "%s", err)
+		return
+	}
+
+	directives := make(map[int]generate.FunctionInfo, len(functions))
+	for _, function := range functions {
+		directives[function.Line] = function
+	}
+
+	var funcDeclarations []*ast.FuncDecl
+	for _, decl := range file.Decls {
+		if funcDeclaration, ok := decl.(*ast.FuncDecl); ok {
+			funcDeclarations = append(funcDeclarations, funcDeclaration)
+		}
+	}
+
+	var stale map[*ast.FuncDecl]bool
+	generated, err := generate.GenerateDocumentationAndInstrumentation(ctx, string(source), moduleName)
+	if err != nil {
+		pass.Reportf(file.Name.Pos(), "%s", err)
+	} else if generated != string(source) {
+		stale = checkGeneratedCode(pass, file, source, generated, funcDeclarations, directives)
+	}
+
+	for _, funcDeclaration := range funcDeclarations {
+		function, ok := directives[pass.Fset.Position(funcDeclaration.Pos()).Line]
+		if !ok {
+			continue
+		}
+
+		if len(function.UnknownArguments) > 0 {
+			pass.Reportf(funcDeclaration.Name.Pos(),
+				"unknown arguments in the autometrics directive of %s are ignored: %s (known arguments are %s)",
+				funcDeclaration.Name.Name,
+				strings.Join(function.UnknownArguments, " "),
+				strings.Join(knownArguments, ", "))
+		}
+
+		// The fix of a stale function already rewrites the defer statement with the context the
+		// generator detects.
+		if deferStatement := generatedDeferStatement(pass.Fset, file, funcDeclaration); deferStatement != nil && !stale[funcDeclaration] {
+			checkContextPropagation(pass, funcDeclaration, deferStatement)
+		}
+	}
+}
+
+// checkContextPropagation reports the instrumented functions that take a context.Context, but
+// whose generated code does not pass it to autometrics.
+func checkContextPropagation(pass *analysis.Pass, funcDeclaration *ast.FuncDecl, deferStatement *ast.DeferStmt) {
+	argument := newContextArgument(deferStatement)
+	if ident, ok := argument.(*ast.Ident); !ok || ident.Name != "nil" {
+		return
+	}
+
+	for _, field := range funcDeclaration.Type.Params.List {
+		if !isContextType(pass.TypesInfo.TypeOf(field.Type)) {
+			continue
+		}
+
+		diagnostic := analysis.Diagnostic{
+			Pos:     field.Pos(),
+			End:     field.End(),
+			Message: fmt.Sprintf("%s takes a context.Context that is not passed to autometrics, so the caller and tracing information is lost", funcDeclaration.Name.Name),
+		}
+
+		for _, name := range field.Names {
+			if name.Name == "_" {
+				continue
+			}
+
+			diagnostic.SuggestedFixes = []analysis.SuggestedFix{{
+				Message:   fmt.Sprintf("Pass %s to autometrics", name.Name),
+				TextEdits: []analysis.TextEdit{{Pos: argument.Pos(), End: argument.End(), NewText: []byte(name.Name)}},
+			}}
+			break
+		}
+		if len(diagnostic.SuggestedFixes) == 0 {
+			diagnostic.Message += ": name the argument, and run go generate"
+		}
+
+		pass.Report(diagnostic)
+		return
+	}
+}
+
+// checkGeneratedCode reports the functions whose generated code differs from what the generator
+// would write now, with the generated code as a suggested fix. It returns the reported functions.
+func checkGeneratedCode(pass *analysis.Pass, file *ast.File, source []byte, generated string, funcDeclarations []*ast.FuncDecl, directives map[int]generate.FunctionInfo) map[*ast.FuncDecl]bool {
+	stale := make(map[*ast.FuncDecl]bool)
+
+	generatedFset := token.NewFileSet()
+	generatedFile, err := parser.ParseFile(generatedFset, "", generated, parser.ParseComments)
+	if err != nil {
+		pass.Reportf(file.Name.Pos(), "error parsing the generated code: %s", err)
+		return stale
+	}
+
+	var generatedDeclarations []*ast.FuncDecl
+	for _, decl := range generatedFile.Decls {
+		if funcDeclaration, ok := decl.(*ast.FuncDecl); ok {
+			generatedDeclarations = append(generatedDeclarations, funcDeclaration)
+		}
+	}
+	if len(generatedDeclarations) != len(funcDeclarations) {
+		pass.Reportf(file.Name.Pos(), "the generated autometrics code is not up-to-date, run go generate")
+		return stale
+	}
+
+	originalText := func(from, to token.Pos) string {
+		return string(source[pass.Fset.Position(from).Offset:pass.Fset.Position(to).Offset])
+	}
+	generatedText := func(from, to token.Pos) string {
+		return generated[generatedFset.Position(from).Offset:generatedFset.Position(to).Offset]
+	}
+
+	// The ranges end at the end of the line, to include the comments that follow the declarations.
+	end, generatedEnd := lineEnd(pass.Fset, source), lineEnd(generatedFset, []byte(generated))
+
+	for i, funcDeclaration := range funcDeclarations {
+		_, hasDirective := directives[pass.Fset.Position(funcDeclaration.Pos()).Line]
+		hasGeneratedCode := hasGeneratedDocumentation(funcDeclaration) || generatedDeferStatement(pass.Fset, file, funcDeclaration) != nil
+		if !hasDirective && !hasGeneratedCode {
+			continue
+		}
+
+		generatedDeclaration := generatedDeclarations[i]
+		docStart, generatedDocStart := docPos(funcDeclaration), docPos(generatedDeclaration)
+		doc, generatedDoc := originalText(docStart, funcDeclaration.Pos()), generatedText(generatedDocStart, generatedDeclaration.Pos())
+		staleDoc := formatted(doc) != formatted(generatedDoc)
+
+		// The generator only changes the results in the signature, to name the returned error, so the
+		// results and the body are edited separately from the doc comment.
+		var body, generatedBody string
+		if funcDeclaration.Body != nil && generatedDeclaration.Body != nil {
+			body = originalText(funcDeclaration.Body.Lbrace, end(funcDeclaration.End()))
+			generatedBody = generatedText(generatedDeclaration.Body.Lbrace, generatedEnd(generatedDeclaration.End()))
+		}
+		var results, generatedResults string
+		resultsStale := false
+		if funcDeclaration.Type.Results != nil && generatedDeclaration.Type.Results != nil {
+			results = originalText(funcDeclaration.Type.Results.Pos(), funcDeclaration.Type.Results.End())
+			generatedResults = generatedText(generatedDeclaration.Type.Results.Pos(), generatedDeclaration.Type.Results.End())
+			resultsStale = formatted(results) != formatted(generatedResults)
+		}
+		staleBody := resultsStale || formatted(body) != formatted(generatedBody)
+
+		if !staleDoc && !staleBody {
+			continue
+		}
+		stale[funcDeclaration] = true
+
+		name := funcDeclaration.Name.Name
+		var message string
+		switch {
+		case !hasDirective:
+			message = fmt.Sprintf("%s has generated autometrics code but no directive, run go generate", name)
+		case !hasGeneratedCode:
+			message = fmt.Sprintf("%s has an autometrics directive but no generated code, run go generate", name)
+		case staleDoc && staleBody:
+			message = fmt.Sprintf("the generated code of %s does not match its autometrics directive, run go generate", name)
+		case staleDoc:
+			message = fmt.Sprintf("the generated documentation of %s does not match its autometrics directive, run go generate", name)
+		default:
+			message = fmt.Sprintf("the generated defer statement of %s does not match its autometrics directive, run go generate", name)
+		}
+
+		var edits []analysis.TextEdit
+		if staleDoc {
+			edits = append(edits, analysis.TextEdit{Pos: docStart, End: funcDeclaration.Pos(), NewText: []byte(generatedDoc)})
+		}
+		if resultsStale {
+			edits = append(edits, analysis.TextEdit{Pos: funcDeclaration.Type.Results.Pos(), End: funcDeclaration.Type.Results.End(), NewText: []byte(generatedResults)})
+		}
+		if staleBody {
+			edits = append(edits, analysis.TextEdit{Pos: funcDeclaration.Body.Lbrace, End: end(funcDeclaration.End()), NewText: []byte(generatedBody)})
+		}
+
+		pass.Report(analysis.Diagnostic{
+			Pos:     funcDeclaration.Name.Pos(),
+			End:     funcDeclaration.Name.End(),
+			Message: message,
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   fmt.Sprintf("Regenerate the autometrics code of %s", name),
+				TextEdits: edits,
+			}},
+		})
+	}
+
+	// The generator adds the autometrics import when it instruments the first function of a file.
+	imports, generatedImports := importsRange(file), importsRange(generatedFile)
+	imports[1], generatedImports[1] = end(imports[1]), generatedEnd(generatedImports[1])
+	if generatedImports[0].IsValid() && formatted(originalText(imports[0], imports[1])) != formatted(generatedText(generatedImports[0], generatedImports[1])) {
+		newText := generatedText(generatedImports[0], generatedImports[1])
+		if !imports[0].IsValid() {
+			imports = [2]token.Pos{file.Name.End(), file.Name.End()}
+			newText = "\n\n" + newText
+		}
+
+		pass.Report(analysis.Diagnostic{
+			Pos:     imports[0],
+			End:     imports[1],
+			Message: "the imports do not match the generated autometrics code, run go generate",
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   "Update the imports for the autometrics code",
+				TextEdits: []analysis.TextEdit{{Pos: imports[0], End: imports[1], NewText: []byte(newText)}},
+			}},
+		})
+	}
+
+	return stale
+}
+
+// lineEnd returns a function giving the end of the line of a position in the source code parsed with fset.
+// Invalid positions are left as is.
+func lineEnd(fset *token.FileSet, source []byte) func(token.Pos) token.Pos {
+	return func(pos token.Pos) token.Pos {
+		if !pos.IsValid() {
+			return pos
+		}
+
+		file := fset.File(pos)
+		offset := file.Offset(pos)
+		if newline := bytes.IndexByte(source[offset:], '\n'); newline >= 0 {
+			return file.Pos(offset + newline)
+		}
+
+		return file.Pos(len(source))
+	}
+}
+
+// generatedDeferStatement returns the defer statement injected by the generator in the function, if any.
+func generatedDeferStatement(fset *token.FileSet, file *ast.File, funcDeclaration *ast.FuncDecl) *ast.DeferStmt {
+	if funcDeclaration.Body == nil || len(funcDeclaration.Body.List) == 0 {
+		return nil
+	}
+
+	deferStatement, ok := funcDeclaration.Body.List[0].(*ast.DeferStmt)
+	if !ok {
+		return nil
+	}
+
+	line := fset.Position(deferStatement.End()).Line
+	for _, commentGroup := range file.Comments {
+		for _, comment := range commentGroup.List {
+			if comment.Text == "//autometrics:defer" && fset.Position(comment.Pos()).Line == line {
+				return deferStatement
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasGeneratedDocumentation returns true if the doc comment of the function contains generated documentation.
+func hasGeneratedDocumentation(funcDeclaration *ast.FuncDecl) bool {
+	if funcDeclaration.Doc == nil {
+		return false
+	}
+
+	for _, comment := range funcDeclaration.Doc.List {
+		if strings.Contains(comment.Text, "autometrics:doc-start") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newContextArgument returns the context.Context argument of the generated defer statement, which
+// has the form Instrument(PreInstrument(NewContext(argument, ...)), ...).
+func newContextArgument(deferStatement *ast.DeferStmt) ast.Expr {
+	var expr ast.Expr = deferStatement.Call
+	for depth := 0; depth < 3; depth++ {
+		call, ok := expr.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return nil
+		}
+		expr = call.Args[0]
+	}
+
+	return expr
+}
+
+func isContextType(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "context" && obj.Name() == "Context"
+}
+
+// docPos returns the start of the function declaration, including its doc comment.
+func docPos(funcDeclaration *ast.FuncDecl) token.Pos {
+	if funcDeclaration.Doc != nil {
+		return funcDeclaration.Doc.Pos()
+	}
+
+	return funcDeclaration.Pos()
+}
+
+// importsRange returns the range covering all the import declarations of the file, or invalid
+// positions if the file has no imports.
+func importsRange(file *ast.File) [2]token.Pos {
+	var ret [2]token.Pos
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+
+		if !ret[0].IsValid() {
+			ret[0] = genDecl.Pos()
+		}
+		ret[1] = genDecl.End()
+	}
+
+	return ret
+}
+
+// formatted returns the code formatted by gofmt, so that formatting differences between the
+// source code and the generated code are ignored.
+func formatted(code string) string {
+	out, err := format.Source([]byte(code))
+	if err != nil {
+		return code
+	}
+
+	return string(bytes.TrimSpace(out))
+}
@@ -0,0 +1,135 @@
+package a
+
+import (
+	"context"
+
+	"github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+//autometrics:inst --no-doc
+func UpToDate(ctx context.Context) (err error) {
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		ctx,
+		autometrics.WithFunctionName("UpToDate"),
+		autometrics.WithModuleName("a"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+	)), &err) //autometrics:defer
+
+	return nil
+}
+
+//autometrics:inst --no-doc --succes-target 99
+func Unknown() { // want `unknown arguments in the autometrics directive of Unknown are ignored: --succes-target 99`
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithFunctionName("Unknown"),
+		autometrics.WithModuleName("a"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+	)), nil) //autometrics:defer
+
+}
+
+//autometrics:inst --no-doc
+func Unnamed() (int, error) { // want `the generated defer statement of Unnamed does not match its autometrics directive`
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithFunctionName("Unnamed"),
+		autometrics.WithModuleName("a"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+	)), nil) //autometrics:defer
+
+	return 0, nil
+}
+
+//autometrics:inst --no-doc
+func Grouped(parent, ctx context.Context) { // want `the generated defer statement of Grouped does not match its autometrics directive`
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithFunctionName("Grouped"),
+		autometrics.WithModuleName("a"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+	)), nil) //autometrics:defer
+
+}
+
+//autometrics:inst --no-doc
+func Ignored(_ context.Context) { // want `Ignored takes a context.Context that is not passed to autometrics, so the caller and tracing information is lost: name the argument, and run go generate`
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithFunctionName("Ignored"),
+		autometrics.WithModuleName("a"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+	)), nil) //autometrics:defer
+
+}
+
+//autometrics:inst --no-doc --slo "API" --success-target 99
+func Stale() { // want `the generated defer statement of Stale does not match its autometrics directive`
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithFunctionName("Stale"),
+		autometrics.WithModuleName("a"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+	)), nil) //autometrics:defer
+
+}
+
+//autometrics:inst --no-doc
+func Missing() { // want `Missing has an autometrics directive but no generated code`
+}
+
+// Leftover is not instrumented anymore.
+func Leftover() { // want `Leftover has generated autometrics code but no directive`
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithFunctionName("Leftover"),
+		autometrics.WithModuleName("a"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+	)), nil) //autometrics:defer
+
+}
+
+type ErrNotFound struct {
+	Key string
+}
+
+func (e *ErrNotFound) Error() string {
+	return e.Key + " not found"
+}
+
+type ParseError struct {
+	Line int
+}
+
+//autometrics:inst --no-doc
+func Lookup(key string) (string, *ErrNotFound) { // want `the generated defer statement of Lookup does not match its autometrics directive`
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithFunctionName("Lookup"),
+		autometrics.WithModuleName("a"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+	)), nil) //autometrics:defer
+
+	return key, nil
+}
+
+//autometrics:inst --no-doc
+func Parse(input string) (int, ParseError) {
+	defer autometrics.Instrument(autometrics.PreInstrument(autometrics.NewContext(
+		nil,
+		autometrics.WithFunctionName("Parse"),
+		autometrics.WithModuleName("a"),
+		autometrics.WithConcurrentCalls(true),
+		autometrics.WithCallerName(true),
+	)), nil) //autometrics:defer
+
+	return len(input), ParseError{}
+}
@@ -0,0 +1,8 @@
+package b
+
+import "fmt" // want `the imports do not match the generated autometrics code`
+
+//autometrics:inst --no-doc
+func Print() { // want `Print has an autometrics directive but no generated code`
+	fmt.Println("instrumented")
+}
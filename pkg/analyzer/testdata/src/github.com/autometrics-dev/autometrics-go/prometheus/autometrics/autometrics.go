@@ -0,0 +1,27 @@
+// Package autometrics is a stub of the Prometheus implementation of autometrics, with
+// the functions used by the generated code.
+package autometrics
+
+import "context"
+
+type Option interface{}
+
+func NewContext(ctx context.Context, opts ...Option) context.Context { return ctx }
+
+func PreInstrument(ctx context.Context) context.Context { return ctx }
+
+func Instrument(ctx context.Context, err *error) {}
+
+func InstrumentCustomError[E any](ctx context.Context, err *E) {}
+
+func WithFunctionName(name string) Option { return nil }
+
+func WithModuleName(name string) Option { return nil }
+
+func WithConcurrentCalls(enabled bool) Option { return nil }
+
+func WithCallerName(enabled bool) Option { return nil }
+
+func WithSloName(name string) Option { return nil }
+
+func WithAlertSuccess(objective float64) Option { return nil }
@@ -0,0 +1,94 @@
+package autometrics // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SeriesKey identifies the series of the metrics recorded for the calls of an instrumented function:
+// the function and its caller, the build, and the objective.
+//
+// It only contains comparable values, so that the implementations can cache the resolved series,
+// and the labels or attributes they are built from, in a [SeriesCache].
+type SeriesKey struct {
+	CallInfo  CallInfo
+	BuildInfo BuildInfo
+	// SloName is the name of the objective, the latency and success objectives are only set when it is.
+	SloName string
+	// LatencyTarget and LatencyObjective are the latency objective, if HasLatency is true.
+	LatencyTarget    time.Duration
+	LatencyObjective float64
+	HasLatency       bool
+	// SuccessObjective is the success rate objective, if HasSuccess is true.
+	SuccessObjective float64
+	HasSuccess       bool
+}
+
+// GetSeriesKey returns the [SeriesKey] of the call in the context.
+//
+// It does not allocate.
+func GetSeriesKey(c context.Context) SeriesKey {
+	key := SeriesKey{CallInfo: GetCallInfo(c), BuildInfo: GetBuildInfo(c)}
+
+	slo := GetAlertConfiguration(c)
+	if slo.ServiceName == "" {
+		return key
+	}
+
+	key.SloName = slo.ServiceName
+	if slo.Latency != nil {
+		key.LatencyTarget = slo.Latency.Target
+		key.LatencyObjective = slo.Latency.Objective
+		key.HasLatency = true
+	}
+	if slo.Success != nil {
+		key.SuccessObjective = slo.Success.Objective
+		key.HasSuccess = true
+	}
+
+	return key
+}
+
+// SeriesCache is a concurrency-safe cache of the series (or of any value computed once per series) of a metric.
+//
+// Getting a cached value does not allocate, so the implementations only build the labels of a series and
+// resolve it the first time it is recorded.
+//
+// The zero value is an empty cache ready to use.
+type SeriesCache[K comparable, V any] struct {
+	lock   sync.RWMutex
+	values map[K]V
+}
+
+// Get returns the value cached for the key, or caches and returns the value computed by create.
+func (c *SeriesCache[K, V]) Get(key K, create func(K) V) V {
+	c.lock.RLock()
+	value, ok := c.values[key]
+	c.lock.RUnlock()
+	if ok {
+		return value
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if value, ok := c.values[key]; ok {
+		return value
+	}
+	if c.values == nil {
+		c.values = make(map[K]V)
+	}
+	value = create(key)
+	c.values[key] = value
+
+	return value
+}
+
+// Reset removes all the cached values, it must be called when the metrics are created again.
+func (c *SeriesCache[K, V]) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.values = nil
+}
@@ -2,27 +2,17 @@ package autometrics // import "github.com/autometrics-dev/autometrics-go/pkg/aut
 
 import (
 	"context"
+	"errors"
 	"log"
-	"math/rand"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type contextKey int
 
-const (
-	currentTraceIdKey contextKey = iota
-	currentSpanIdKey
-	currentParentSpanIdKey
-	currentTrackConcurrentCallsKey
-	currentTrackCallerNameKey
-	currentAlertConfigurationKey
-	currentStartTimeKey
-	currentCallInfoKey
-	currentBuildInfoKey
-	currentValidHttpCodeRangesKey
-)
-
-var randSource *rand.Rand
+// callStateKey is the only key autometrics uses in contexts, all the per-call state is in a [callState].
+const callStateKey contextKey = iota
 
 // Open Telemetry-compatible trace ID
 type TraceID [16]byte
@@ -30,6 +20,57 @@ type TraceID [16]byte
 // Open Telemetry-compatible span ID
 type SpanID [8]byte
 
+// callState is the state of a call of an instrumented function, stored in the context under [callStateKey].
+//
+// A callState is never modified once it is in a context that can be seen by other calls: the setters
+// copy the state and store the copy in a new context.
+type callState struct {
+	traceID        TraceID
+	hasTraceID     bool
+	spanID         SpanID
+	hasSpanID      bool
+	parentSpanID   SpanID
+	hasParentSpan  bool
+	traceSampled   bool
+	hasSampling    bool
+	startTime      time.Time
+	hasStartTime   bool
+	callInfo       CallInfo
+	buildInfo      BuildInfo
+	alertConfig    AlertConfiguration
+	validHttpCodes []InclusiveIntRange
+	hasHttpCodes   bool
+	classifier     ErrorClassifier
+	// The tracking flags are stored negated, so that the zero value has the default behaviour.
+	noConcurrentCalls bool
+	noCallerName      bool
+	// functionName and moduleName are the identity of the instrumented function, when it is known
+	// statically (see [WithFunctionName]).
+	functionName string
+	moduleName   string
+}
+
+// stateOf returns the state of the context, or nil if there is none.
+//
+// It does not allocate, so the getters can be used on the hot path of instrumented calls.
+func stateOf(c context.Context) *callState {
+	if c == nil {
+		return nil
+	}
+	state, _ := c.Value(callStateKey).(*callState)
+	return state
+}
+
+// withState returns a copy of the context, with a copy of its state changed by update.
+func withState(ctx context.Context, update func(*callState)) context.Context {
+	var state callState
+	if current := stateOf(ctx); current != nil {
+		state = *current
+	}
+	update(&state)
+	return context.WithValue(ctx, callStateKey, &state)
+}
+
 // NewContext is a constructor taking the parent context as argument.
 //
 // It accepts 'nil' as the parent context. In this case the constructor
@@ -38,18 +79,31 @@ func NewContext(parentCtx context.Context) context.Context {
 	if parentCtx == nil {
 		parentCtx = context.Background()
 	}
-	ctx := SetTrackConcurrentCalls(parentCtx, true)
-	ctx = SetTrackCallerName(ctx, true)
-	ctx = SetValidHttpCodeRanges(ctx, []InclusiveIntRange{{Min: 100, Max: 399}})
-	return ctx
+	return withState(parentCtx, resetCallState)
+}
+
+// resetCallState sets the defaults of a new call, keeping the tracing information of the parent call.
+//
+// The error classifier of the parent call is not kept either, as it is specific to the parent function
+// (see [WithErrorClassifier]).
+func resetCallState(state *callState) {
+	state.classifier = nil
+	state.noConcurrentCalls = false
+	state.noCallerName = false
+	state.functionName = ""
+	state.moduleName = ""
+	state.validHttpCodes = defaultValidHttpCodes
+	state.hasHttpCodes = true
 }
 
+var defaultValidHttpCodes = []InclusiveIntRange{{Min: 100, Max: 399}}
+
 // SetTrackConcurrentCalls sets a flag in the context deciding whether to track how many concurrent calls the instrumented functions observe.
 //
 // TrackConcurrentCalls triggers the collection of the gauge for concurrent calls of the function.
 // The flag defaults to true.
 func SetTrackConcurrentCalls(ctx context.Context, track bool) context.Context {
-	return context.WithValue(ctx, currentTrackConcurrentCallsKey, track)
+	return withState(ctx, func(state *callState) { state.noConcurrentCalls = !track })
 }
 
 // GetTrackConcurrentCalls returns whether autometrics should track how many concurrent calls the instrumented function observe.
@@ -57,16 +111,8 @@ func SetTrackConcurrentCalls(ctx context.Context, track bool) context.Context {
 // TrackConcurrentCalls triggers the collection of the gauge for concurrent calls of the function.
 // It defaults to true.
 func GetTrackConcurrentCalls(c context.Context) bool {
-	if c == nil {
-		return true
-	}
-
-	track, ok := c.Value(currentTrackConcurrentCallsKey).(bool)
-	if !ok {
-		return true
-	}
-
-	return track
+	state := stateOf(c)
+	return state == nil || !state.noConcurrentCalls
 }
 
 // SetTrackCallerName sets a flag in the context deciding whether to track the names of the callers of instrumented functions.
@@ -74,7 +120,7 @@ func GetTrackConcurrentCalls(c context.Context) bool {
 // TrackCallerName adds a label with the caller name in all the collected metrics.
 // The flag defaults to true.
 func SetTrackCallerName(ctx context.Context, track bool) context.Context {
-	return context.WithValue(ctx, currentTrackCallerNameKey, track)
+	return withState(ctx, func(state *callState) { state.noCallerName = !track })
 }
 
 // GetTrackCallerName returns default information if the context did not contain any build information.
@@ -82,69 +128,49 @@ func SetTrackCallerName(ctx context.Context, track bool) context.Context {
 // TrackCallerName adds a label with the caller name in all the collected metrics.
 // It defaults to true.
 func GetTrackCallerName(c context.Context) bool {
-	if c == nil {
-		return true
-	}
-
-	track, ok := c.Value(currentTrackCallerNameKey).(bool)
-	if !ok {
-		return true
-	}
-
-	return track
+	state := stateOf(c)
+	return state == nil || !state.noCallerName
 }
 
 // SetAlertConfiguration sets the context's [AlertConfiguration]
 //
 // AlertConfiguration is an optional configuration to add alerting capabilities to the metrics.
 func SetAlertConfiguration(ctx context.Context, slo AlertConfiguration) context.Context {
-	return context.WithValue(ctx, currentAlertConfigurationKey, slo)
+	return withState(ctx, func(state *callState) { state.alertConfig = slo })
 }
 
 // GetAlertConfiguration returns default information if the context did not contain any alerting configuration.
 //
 // AlertConfiguration is an optional configuration to add alerting capabilities to the metrics.
 func GetAlertConfiguration(c context.Context) AlertConfiguration {
-	if c == nil {
-		return AlertConfiguration{}
+	if state := stateOf(c); state != nil {
+		return state.alertConfig
 	}
-
-	slo, ok := c.Value(currentAlertConfigurationKey).(AlertConfiguration)
-	if !ok {
-		return AlertConfiguration{}
-	}
-
-	return slo
+	return AlertConfiguration{}
 }
 
 // SetCallInfo sets the context's [CallInfo]
 //
 // CallInfo contains all the relevant data for caller information.
 func SetCallInfo(ctx context.Context, build CallInfo) context.Context {
-	return context.WithValue(ctx, currentCallInfoKey, build)
+	return withState(ctx, func(state *callState) { state.callInfo = build })
 }
 
 // GetCallInfo returns default information if the context did not contain any build information.
 //
 // CallInfo contains all the relevant data for caller information.
 func GetCallInfo(c context.Context) CallInfo {
-	if c == nil {
-		return CallInfo{}
-	}
-
-	build, ok := c.Value(currentCallInfoKey).(CallInfo)
-	if !ok {
-		return CallInfo{}
+	if state := stateOf(c); state != nil {
+		return state.callInfo
 	}
-
-	return build
+	return CallInfo{}
 }
 
 // SetStartTime sets the context's [StartTime]
 //
 // StartTime is the start time of a single function execution.
 func SetStartTime(ctx context.Context, newStartTime time.Time) context.Context {
-	return context.WithValue(ctx, currentStartTimeKey, newStartTime)
+	return withState(ctx, func(state *callState) { state.startTime, state.hasStartTime = newStartTime, true })
 }
 
 // GetStartTime returns default current time if the context did not contain any start time.
@@ -155,124 +181,144 @@ func GetStartTime(c context.Context) time.Time {
 		return time.Now()
 	}
 
-	readStartTime, ok := c.Value(currentStartTimeKey).(time.Time)
-	if !ok {
+	state := stateOf(c)
+	if state == nil || !state.hasStartTime {
 		log.Printf("Warning: startTime is not a time.")
 		return time.Now()
 	}
 
-	return readStartTime
+	return state.startTime
 }
 
 // SetBuildInfo sets the context's [BuildInfo]
 //
 // BuildInfo contains all the relevant data for caller information.
 func SetBuildInfo(ctx context.Context, build BuildInfo) context.Context {
-	return context.WithValue(ctx, currentBuildInfoKey, build)
+	return withState(ctx, func(state *callState) { state.buildInfo = build })
 }
 
 // GetBuildInfo returns default information if the context did not contain any build information.
 //
 // BuildInfo contains all the relevant data for caller information.
 func GetBuildInfo(c context.Context) BuildInfo {
-	if c == nil {
-		return BuildInfo{}
-	}
-
-	build, ok := c.Value(currentBuildInfoKey).(BuildInfo)
-	if !ok {
-		return BuildInfo{}
+	if state := stateOf(c); state != nil {
+		return state.buildInfo
 	}
-
-	return build
+	return BuildInfo{}
 }
 
 // SetTraceID sets the context's [TraceID]
 func SetTraceID(ctx context.Context, tid TraceID) context.Context {
-	return context.WithValue(ctx, currentTraceIdKey, tid)
+	return withState(ctx, func(state *callState) { state.traceID, state.hasTraceID = tid, true })
 }
 
 // GetTraceID returns (_, false) if the context did not contain any trace id.
 func GetTraceID(c context.Context) (TraceID, bool) {
-	if c == nil {
-		return TraceID{}, false
+	if state := stateOf(c); state != nil {
+		return state.traceID, state.hasTraceID
 	}
-	tid, ok := c.Value(currentTraceIdKey).(TraceID)
-	return tid, ok
+	return TraceID{}, false
 }
 
 // SetSpanID sets the context's [SpanID]
 func SetSpanID(ctx context.Context, sid SpanID) context.Context {
-	return context.WithValue(ctx, currentSpanIdKey, sid)
+	return withState(ctx, func(state *callState) { state.spanID, state.hasSpanID = sid, true })
 }
 
 // GetSpanID returns (_, false) if the context did not contain the current span id.
 func GetSpanID(c context.Context) (SpanID, bool) {
-	if c == nil {
-		return SpanID{}, false
+	if state := stateOf(c); state != nil {
+		return state.spanID, state.hasSpanID
 	}
-	sid, ok := c.Value(currentSpanIdKey).(SpanID)
-	return sid, ok
+	return SpanID{}, false
 }
 
 // SetParentSpanID sets the context's span's parent [SpanID]
 func SetParentSpanID(ctx context.Context, sid SpanID) context.Context {
-	return context.WithValue(ctx, currentParentSpanIdKey, sid)
+	return withState(ctx, func(state *callState) { state.parentSpanID, state.hasParentSpan = sid, true })
 }
 
 // GetParentSpanID returns (_, false) if the context did not contain the parent's span id (including when we are in the root span).
 func GetParentSpanID(c context.Context) (SpanID, bool) {
-	if c == nil {
-		return SpanID{}, false
+	if state := stateOf(c); state != nil {
+		return state.parentSpanID, state.hasParentSpan
+	}
+	return SpanID{}, false
+}
+
+// SetTraceSampled sets whether the trace of the context is sampled, i.e. whether it is recorded by a tracing backend.
+func SetTraceSampled(ctx context.Context, sampled bool) context.Context {
+	return withState(ctx, func(state *callState) { state.traceSampled, state.hasSampling = sampled, true })
+}
+
+// GetTraceSampled returns (_, false) if the context did not contain any sampling decision,
+// which is the case when the trace and span IDs do not come from an actual trace.
+func GetTraceSampled(c context.Context) (bool, bool) {
+	if state := stateOf(c); state != nil {
+		return state.traceSampled, state.hasSampling
 	}
-	sid, ok := c.Value(currentParentSpanIdKey).(SpanID)
-	return sid, ok
+	return false, false
 }
 
 // FillTracingInfo ensures the context has a traceID and a spanID.
-// If they do not have this information, this method adds randomly
-// generated IDs in the context to be used later for exemplars
 //
-// The random generator is a PRNG, seeded with the timestamp of the first time new IDs are needed.
+// If the context carries a valid OpenTelemetry [trace.SpanContext], its IDs and sampling
+// decision are used, so that the exemplars point to the actual trace. Otherwise this method
+// adds randomly generated IDs in the context to be used later for exemplars
+//
+// The random generator is a PRNG seeded from crypto/rand. The random IDs are not generated if
+// they are disabled with [SetSyntheticTraceIDs].
 func FillTracingInfo(ctx context.Context) context.Context {
-	// We are using a PRNG because FillTracingInfo is expected to be called in PreInstrument.
-	// Therefore it can have a noticeable impact on the performance of instrumented code.
-	// Pseudo randomness should be enough for our use cases, true randomness might introduce too much latency.
-	// randSource is initialized with a timestamp from the first time it is accessed in nanoseconds, which should
-	// be enough precision to avoid accidental collisions (imagine multiple services starting "at the same time" in a deployment).
-	if randSource == nil {
-		randSource = rand.New(rand.NewSource(time.Now().UnixNano()))
+	return withState(ctx, func(state *callState) { state.fillTracingInfo(ctx) })
+}
+
+func (state *callState) fillTracingInfo(ctx context.Context) {
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		// The parent of the active span is not known from its context, so the parent span ID is
+		// reset to the invalid, all zeros, span ID.
+		state.parentSpanID, state.hasParentSpan = SpanID{}, true
+		state.traceID, state.hasTraceID = TraceID(spanCtx.TraceID()), true
+		state.spanID, state.hasSpanID = SpanID(spanCtx.SpanID()), true
+		state.traceSampled, state.hasSampling = spanCtx.IsSampled(), true
+		return
 	}
 
-	if parentSpanId, ok := GetSpanID(ctx); ok {
-		ctx = SetParentSpanID(ctx, parentSpanId)
+	// Without synthetic IDs, only the IDs coming from the context or the options are used.
+	if !GetSyntheticTraceIDs() {
+		return
 	}
 
-	sid := SpanID{}
-	_, _ = randSource.Read(sid[:])
-	ctx = SetSpanID(ctx, sid)
+	if state.hasSpanID {
+		state.parentSpanID, state.hasParentSpan = state.spanID, true
+	}
 
-	if _, ok := GetTraceID(ctx); !ok {
-		tid := TraceID{}
-		_, _ = randSource.Read(tid[:])
-		ctx = SetTraceID(ctx, tid)
+	state.spanID, state.hasSpanID = newSpanID(), true
+
+	if !state.hasTraceID {
+		state.traceID, state.hasTraceID = newTraceID(), true
 	}
+}
 
-	return ctx
+// PrepareCall sets all the information about a call of an instrumented function in the context at once:
+// the [CallInfo], the build information (see [FillBuildInfo]), the tracing information (see [FillTracingInfo]),
+// and the current time as [StartTime].
+//
+// It is meant to be called by the PreInstrument functions of the implementations, and only adds one value
+// to the context.
+func PrepareCall(ctx context.Context, callInfo CallInfo) context.Context {
+	return withState(ctx, func(state *callState) {
+		state.callInfo = callInfo
+		state.fillBuildInfo()
+		state.fillTracingInfo(ctx)
+		state.startTime, state.hasStartTime = time.Now(), true
+	})
 }
 
 // GenerateTraceId generates a new TraceID with a Pseudo-random number generator.
 //
-// The generator is seeded with the timestamp of the first time a new traceID is needed.
+// It is safe for concurrent use, see [newTraceID].
 func GenerateTraceId() TraceID {
-	if randSource == nil {
-		randSource = rand.New(rand.NewSource(time.Now().UnixNano()))
-	}
-
-	tid := TraceID{}
-	randSource.Read(tid[:])
-
-	return tid
+	return newTraceID()
 }
 
 // WithNewTraceId returns a copy of the passed context, with a newly generated traceID accessible for autometrics.
@@ -280,19 +326,21 @@ func WithNewTraceId(ctx context.Context) context.Context {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	return context.WithValue(ctx, currentTraceIdKey, GenerateTraceId())
+	return SetTraceID(ctx, GenerateTraceId())
 }
 
 // FillBuildInfo adds the relevant build information to the current context.
 func FillBuildInfo(ctx context.Context) context.Context {
-	b := BuildInfo{
+	return withState(ctx, (*callState).fillBuildInfo)
+}
+
+func (state *callState) fillBuildInfo() {
+	state.buildInfo = BuildInfo{
 		Version: GetVersion(),
 		Commit:  GetCommit(),
 		Branch:  GetBranch(),
 		Service: GetService(),
 	}
-
-	return SetBuildInfo(ctx, b)
 }
 
 type InclusiveIntRange struct {
@@ -315,7 +363,7 @@ func (r InclusiveIntRange) Contains(value int) bool {
 //
 // This setting is only useful when used in conjunction with the [github.com/autometrics-dev/autometrics-go/pkg/middleware/http/middleware.Autometrics] wrapper.
 func SetValidHttpCodeRanges(ctx context.Context, ranges []InclusiveIntRange) context.Context {
-	return context.WithValue(ctx, currentParentSpanIdKey, ranges)
+	return withState(ctx, func(state *callState) { state.validHttpCodes, state.hasHttpCodes = ranges, true })
 }
 
 // GetValidHttpCodeRanges returns the list of values that should be considered as "ok" by Autometrics when computing the success rate of a handler.
@@ -329,10 +377,51 @@ func GetValidHttpCodeRanges(c context.Context) []InclusiveIntRange {
 		}}
 	}
 
-	ranges, ok := c.Value(currentParentSpanIdKey).([]InclusiveIntRange)
-	if !ok {
+	state := stateOf(c)
+	if state == nil || !state.hasHttpCodes {
 		return []InclusiveIntRange{}
 	}
 
-	return ranges
+	return state.validHttpCodes
+}
+
+// SetErrorClassifier sets the context's [ErrorClassifier]
+//
+// ErrorClassifier decides whether the errors returned by the function are failures.
+func SetErrorClassifier(ctx context.Context, classifier ErrorClassifier) context.Context {
+	return withState(ctx, func(state *callState) { state.classifier = classifier })
+}
+
+// GetErrorClassifier returns the default classifier (see [GetDefaultErrorClassifier]) if the context
+// did not contain any error classifier.
+//
+// ErrorClassifier decides whether the errors returned by the function are failures.
+func GetErrorClassifier(c context.Context) ErrorClassifier {
+	if state := stateOf(c); state != nil && state.classifier != nil {
+		return state.classifier
+	}
+
+	return GetDefaultErrorClassifier()
+}
+
+// ClassifyError returns the [Result] of a call that returned err, using the [ErrorClassifier] of the context.
+//
+// A nil error is always a success, and a panic (see [PanicError]) is always a failure. The other errors are
+// failures if there is no classifier.
+func ClassifyError(c context.Context, err error) Result {
+	if err == nil {
+		return ResultOk
+	}
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return ResultError
+	}
+
+	classifier := GetErrorClassifier(c)
+	if classifier == nil {
+		return ResultError
+	}
+
+	return classifier(err)
 }
@@ -10,16 +10,45 @@ type Option interface {
 	Apply(context.Context) context.Context
 }
 
-type optionFunc func(context.Context) context.Context
+// optionFunc is an [Option] of this package, which changes the state of the call directly.
+type optionFunc func(*callState)
 
 func (fn optionFunc) Apply(ctx context.Context) context.Context {
-	return fn(ctx)
+	return withState(ctx, fn)
 }
 
+func (fn optionFunc) apply(state *callState) {
+	fn(state)
+}
+
+// stateOption is implemented by the options of this package, which [NewContextWithOpts] applies to the
+// state it owns.
+type stateOption interface {
+	apply(*callState)
+}
+
+// NewContextWithOpts is [NewContext] followed by the application of the options.
+//
+// The options of this package are applied to the state added by NewContext, so that the
+// returned context only has one more value than ctx.
 func NewContextWithOpts(ctx context.Context, opts ...Option) context.Context {
 	amCtx := NewContext(ctx)
+	owned := stateOf(amCtx)
 
 	for _, o := range opts {
+		if option, ok := o.(stateOption); ok {
+			// A custom option might have returned a context with another state, which
+			// cannot be changed in place.
+			if state := stateOf(amCtx); state != owned {
+				owned = &callState{}
+				if state != nil {
+					*owned = *state
+				}
+				amCtx = context.WithValue(amCtx, callStateKey, owned)
+			}
+			option.apply(owned)
+			continue
+		}
 		amCtx = o.Apply(amCtx)
 	}
 
@@ -27,72 +56,152 @@ func NewContextWithOpts(ctx context.Context, opts ...Option) context.Context {
 }
 
 func WithTraceID(tid []byte) Option {
-	return optionFunc(func(ctx context.Context) context.Context {
+	return optionFunc(func(state *callState) {
 		if tid != nil {
 			var truncatedTid TraceID
 			copy(truncatedTid[:], tid)
-			return SetTraceID(ctx, truncatedTid)
+			state.traceID, state.hasTraceID = truncatedTid, true
 		}
-		return ctx
 	})
 }
 
 func WithSpanID(sid []byte) Option {
-	return optionFunc(func(ctx context.Context) context.Context {
+	return optionFunc(func(state *callState) {
 		if sid != nil {
 			var truncatedSid SpanID
 			copy(truncatedSid[:], sid)
-			return SetSpanID(ctx, truncatedSid)
+			state.spanID, state.hasSpanID = truncatedSid, true
 		}
-		return ctx
 	})
 }
 
 func WithAlertLatency(target time.Duration, objective float64) Option {
-	return optionFunc(func(ctx context.Context) context.Context {
-		latencySlo := &LatencySlo{
+	return optionFunc(func(state *callState) {
+		state.alertConfig.Latency = &LatencySlo{
 			Target:    target,
 			Objective: objective,
 		}
-		slo := GetAlertConfiguration(ctx)
-		slo.Latency = latencySlo
-		return SetAlertConfiguration(ctx, slo)
 	})
 }
 
 func WithAlertSuccess(objective float64) Option {
-	return optionFunc(func(ctx context.Context) context.Context {
-		successSlo := &SuccessSlo{
+	return optionFunc(func(state *callState) {
+		state.alertConfig.Success = &SuccessSlo{
 			Objective: objective,
 		}
-		slo := GetAlertConfiguration(ctx)
-		slo.Success = successSlo
-		return SetAlertConfiguration(ctx, slo)
 	})
 }
 
 func WithSloName(name string) Option {
-	return optionFunc(func(ctx context.Context) context.Context {
-		slo := GetAlertConfiguration(ctx)
-		slo.ServiceName = name
-		return SetAlertConfiguration(ctx, slo)
+	return optionFunc(func(state *callState) {
+		state.alertConfig.ServiceName = name
 	})
 }
 
 func WithConcurrentCalls(enabled bool) Option {
-	return optionFunc(func(ctx context.Context) context.Context {
-		return SetTrackConcurrentCalls(ctx, enabled)
+	return optionFunc(func(state *callState) {
+		state.noConcurrentCalls = !enabled
 	})
 }
 
 func WithCallerName(enabled bool) Option {
-	return optionFunc(func(ctx context.Context) context.Context {
-		return SetTrackCallerName(ctx, enabled)
+	return optionFunc(func(state *callState) {
+		state.noCallerName = !enabled
 	})
 }
 
+// WithFunctionName sets the name of the instrumented function, so that PreInstrument does not have to
+// find it in the call stack (see [ResolveCallInfo]).
+//
+// The generator adds it to the instrumented code, with the name of the declaration of the function.
+func WithFunctionName(name string) Option {
+	return functionNameOption(name)
+}
+
+// WithModuleName sets the module name of the instrumented function: the package path, followed by the
+// receiver type name for methods (see [CallerInfo]).
+//
+// The generator adds it to the instrumented code when it knows the path of the package.
+func WithModuleName(name string) Option {
+	return moduleNameOption(name)
+}
+
+// functionNameOption and moduleNameOption are strings instead of optionFuncs, so that the options the
+// generator adds to every instrumented call do not allocate.
+type (
+	functionNameOption string
+	moduleNameOption   string
+)
+
+func (name functionNameOption) Apply(ctx context.Context) context.Context {
+	return withState(ctx, name.apply)
+}
+
+func (name functionNameOption) apply(state *callState) {
+	state.functionName = string(name)
+}
+
+func (name moduleNameOption) Apply(ctx context.Context) context.Context {
+	return withState(ctx, name.apply)
+}
+
+func (name moduleNameOption) apply(state *callState) {
+	state.moduleName = string(name)
+}
+
 func WithValidHttpCodes(ranges []InclusiveIntRange) Option {
-	return optionFunc(func(ctx context.Context) context.Context {
-		return SetValidHttpCodeRanges(ctx, ranges)
+	return optionFunc(func(state *callState) {
+		state.validHttpCodes, state.hasHttpCodes = ranges, true
+	})
+}
+
+// WithErrorClassifier sets the [ErrorClassifier] deciding whether the errors returned by the function are failures,
+// instead of the default one set at Init.
+func WithErrorClassifier(classifier ErrorClassifier) Option {
+	return optionFunc(func(state *callState) {
+		state.classifier = classifier
+	})
+}
+
+// InitOption is an option of the process-wide configuration of autometrics, set in the Init function
+// of the implementations.
+type InitOption interface {
+	// ApplyInit applies the option to the process-wide configuration
+	ApplyInit()
+}
+
+type initOptionFunc func()
+
+func (fn initOptionFunc) ApplyInit() {
+	fn()
+}
+
+// WithDefaultErrorClassifier sets the [ErrorClassifier] of the instrumented functions that do not set one
+// with [WithErrorClassifier].
+func WithDefaultErrorClassifier(classifier ErrorClassifier) InitOption {
+	return initOptionFunc(func() {
+		SetDefaultErrorClassifier(classifier)
+	})
+}
+
+// WithErrorTypes enables the error type label on the calls counter, resolved with the registered types
+// (see [ErrorTypeOf]).
+//
+// The registered types bound the cardinality of the label: the errors matching none of them are
+// recorded as [OtherErrorType].
+func WithErrorTypes(types ...ErrorType) InitOption {
+	return initOptionFunc(func() {
+		SetErrorTypes(types, true)
+	})
+}
+
+// WithSyntheticTraceIDs enables or disables the random trace and span IDs generated for the calls that
+// have no tracing information in their context (see [FillTracingInfo]).
+//
+// Without them, the exemplars are only attached to the calls with a trace, which saves the generation
+// of the IDs when exemplars are not needed.
+func WithSyntheticTraceIDs(enabled bool) InitOption {
+	return initOptionFunc(func() {
+		SetSyntheticTraceIDs(enabled)
 	})
 }
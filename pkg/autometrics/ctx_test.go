@@ -0,0 +1,96 @@
+package autometrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testOptions = []Option{
+	WithConcurrentCalls(true),
+	WithCallerName(true),
+	WithSloName("API"),
+	WithAlertSuccess(99.9),
+	WithAlertLatency(500*time.Millisecond, 99),
+}
+
+// TestCallState makes sure that the values set in the context do not overwrite each other.
+func TestCallState(t *testing.T) {
+	ranges := []InclusiveIntRange{{Min: 200, Max: 299}}
+	parentSpanID := SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+
+	ctx := NewContextWithOpts(context.Background(), append(testOptions, WithValidHttpCodes(ranges))...)
+	ctx = SetParentSpanID(ctx, parentSpanID)
+
+	assert.Equal(t, ranges, GetValidHttpCodeRanges(ctx))
+	psid, ok := GetParentSpanID(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, parentSpanID, psid)
+	assert.Equal(t, "API", GetAlertConfiguration(ctx).ServiceName)
+	assert.Equal(t, 99.9, GetAlertConfiguration(ctx).Success.Objective)
+	assert.Equal(t, 500*time.Millisecond, GetAlertConfiguration(ctx).Latency.Target)
+}
+
+// TestCallStateIsolation makes sure that changing the state of a context does not change the state
+// of its parent.
+func TestCallStateIsolation(t *testing.T) {
+	parent := NewContextWithOpts(context.Background(), WithSloName("parent"), WithConcurrentCalls(false))
+	child := NewContextWithOpts(parent, WithSloName("child"))
+
+	assert.Equal(t, "parent", GetAlertConfiguration(parent).ServiceName)
+	assert.False(t, GetTrackConcurrentCalls(parent))
+	assert.Equal(t, "child", GetAlertConfiguration(child).ServiceName)
+	assert.True(t, GetTrackConcurrentCalls(child), "The tracking flags should be reset for a new call.")
+
+	call := PrepareCall(child, CallInfo{FuncName: "child"})
+	parentCall := PrepareCall(parent, CallInfo{FuncName: "parent"})
+	assert.Equal(t, "child", GetCallInfo(call).FuncName)
+	assert.Equal(t, "parent", GetCallInfo(parentCall).FuncName)
+	assert.Equal(t, CallInfo{}, GetCallInfo(child))
+}
+
+func TestGettersDoNotAllocate(t *testing.T) {
+	ctx := PrepareCall(NewContextWithOpts(context.Background(), testOptions...), CallInfo{FuncName: "test"})
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = GetCallInfo(ctx)
+		_ = GetBuildInfo(ctx)
+		_ = GetAlertConfiguration(ctx)
+		_ = GetStartTime(ctx)
+		_ = GetTrackConcurrentCalls(ctx)
+		_ = GetTrackCallerName(ctx)
+		_ = GetValidHttpCodeRanges(ctx)
+		_ = GetErrorClassifier(ctx)
+		_, _ = GetTraceID(ctx)
+		_, _ = GetSpanID(ctx)
+		_, _ = GetParentSpanID(ctx)
+		_, _ = GetTraceSampled(ctx)
+	})
+
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkNewContext(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ctx := NewContextWithOpts(context.Background(), testOptions...)
+		_ = PrepareCall(ctx, CallInfo{FuncName: "benchmark"})
+	}
+}
+
+func BenchmarkGetters(b *testing.B) {
+	ctx := PrepareCall(NewContextWithOpts(context.Background(), testOptions...), CallInfo{FuncName: "benchmark"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = GetCallInfo(ctx)
+		_ = GetBuildInfo(ctx)
+		_ = GetAlertConfiguration(ctx)
+		_ = GetStartTime(ctx)
+		_ = GetTrackConcurrentCalls(ctx)
+		_, _ = GetTraceID(ctx)
+	}
+}
@@ -1,5 +1,7 @@
 package autometrics // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
 
+import "errors"
+
 // These variables are describing the state of the application being autometricized,
 // _not_ the build information of the binary
 
@@ -21,6 +23,12 @@ var (
 	service     string
 	pushJobName string
 	pushJobURL  string
+
+	defaultErrorClassifier ErrorClassifier
+	errorTypes             []ErrorType
+	errorTypeLabel         bool
+	// Stored negated, so that synthetic IDs are enabled by default.
+	noSyntheticTraceIDs bool
 )
 
 // GetVersion returns the version of the codebase being instrumented.
@@ -82,3 +90,69 @@ func GetPushJobURL() string {
 func SetPushJobURL(newPushJobURL string) {
 	pushJobURL = newPushJobURL
 }
+
+// GetDefaultErrorClassifier returns the [ErrorClassifier] used by the instrumented functions that do not set one.
+//
+// It is nil if no default classifier has been set, in which case all errors are failures.
+func GetDefaultErrorClassifier() ErrorClassifier {
+	return defaultErrorClassifier
+}
+
+// SetDefaultErrorClassifier sets the [ErrorClassifier] used by the instrumented functions that do not set one.
+//
+// It is meant to be set once, before any instrumented function is called: use the
+// [WithDefaultErrorClassifier] option of Init.
+func SetDefaultErrorClassifier(classifier ErrorClassifier) {
+	defaultErrorClassifier = classifier
+}
+
+// GetErrorTypes returns the registered [ErrorType]s, and whether the error type label is enabled.
+func GetErrorTypes() ([]ErrorType, bool) {
+	return errorTypes, errorTypeLabel
+}
+
+// SetErrorTypes registers the [ErrorType]s used to resolve the error type label, and enables or disables the label.
+//
+// It is meant to be set once, before the metrics are created: use the [WithErrorTypes] option of Init.
+func SetErrorTypes(types []ErrorType, enabled bool) {
+	errorTypes = types
+	errorTypeLabel = enabled
+}
+
+// GetSyntheticTraceIDs returns whether random trace and span IDs are generated for the calls that have no
+// tracing information.
+func GetSyntheticTraceIDs() bool {
+	return !noSyntheticTraceIDs
+}
+
+// SetSyntheticTraceIDs enables or disables the generation of random trace and span IDs for the calls that
+// have no tracing information. It is enabled by default.
+//
+// It is meant to be set once, before any instrumented function is called: use the [WithSyntheticTraceIDs]
+// option of Init.
+func SetSyntheticTraceIDs(enabled bool) {
+	noSyntheticTraceIDs = !enabled
+}
+
+// ErrorTypeOf returns the value of the error type label for err: the name of the first registered
+// [ErrorType] that matches err, [PanicErrorType] for panics, or [OtherErrorType] otherwise.
+//
+// It returns an empty string for nil errors.
+func ErrorTypeOf(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	for _, errorType := range errorTypes {
+		if errorType.Match(err) {
+			return errorType.Name
+		}
+	}
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return PanicErrorType
+	}
+
+	return OtherErrorType
+}
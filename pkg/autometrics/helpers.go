@@ -1,9 +1,11 @@
 package autometrics // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
+	"reflect"
 
 	"github.com/oklog/ulid/v2"
 )
@@ -37,3 +39,55 @@ func GetOutboundIP() (net.IP, error) {
 func DefaultJobName() string {
 	return ulid.Make().String()
 }
+
+// CustomErrorValue returns the error held in the result of a function whose error result has a custom type.
+//
+// It returns nil if the value does not implement error, or if it is a nil pointer (or any other nil value)
+// of a type implementing error, as a function returning such a value did not fail. The zero value of
+// comparable error types is also considered a success.
+func CustomErrorValue[E any](result E) error {
+	err, ok := interface{}(result).(error)
+	if !ok {
+		return nil
+	}
+
+	value := reflect.ValueOf(result)
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		if value.IsNil() {
+			return nil
+		}
+	default:
+		if value.IsZero() {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// DecodeValue decodes a hex-encoded string stored in an untyped value, like the locals of a fiber.Ctx or
+// the user values of a fasthttp.RequestCtx.
+//
+// It returns nil if the value is not a valid hex-encoded string.
+func DecodeValue(v interface{}) []byte {
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	res, err := hex.DecodeString(s)
+	if err != nil {
+		return nil
+	}
+	return res
+}
+
+// PanicError is the error recorded for the calls of instrumented functions that panicked.
+type PanicError struct {
+	// Value is the value the function panicked with.
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
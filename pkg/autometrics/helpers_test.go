@@ -0,0 +1,37 @@
+package autometrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPointerError struct{}
+
+func (e *testPointerError) Error() string { return "pointer error" }
+
+type testValueError struct{ code int }
+
+func (e testValueError) Error() string { return "value error" }
+
+func TestCustomErrorValue(t *testing.T) {
+	assert.Nil(t, CustomErrorValue((*testPointerError)(nil)))
+	assert.Nil(t, CustomErrorValue(testValueError{}))
+	assert.Nil(t, CustomErrorValue(error(nil)))
+	assert.Nil(t, CustomErrorValue(42))
+
+	pointerErr := &testPointerError{}
+	assert.Equal(t, pointerErr, CustomErrorValue(pointerErr))
+	assert.Equal(t, testValueError{code: 1}, CustomErrorValue(testValueError{code: 1}))
+
+	err := errors.New("failure")
+	assert.Equal(t, err, CustomErrorValue(err))
+}
+
+func TestDecodeValue(t *testing.T) {
+	assert.Equal(t, []byte{0x01, 0xab}, DecodeValue("01ab"))
+	assert.Nil(t, DecodeValue("not hex"))
+	assert.Nil(t, DecodeValue([]byte("01ab")))
+	assert.Nil(t, DecodeValue(nil))
+}
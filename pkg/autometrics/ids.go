@@ -0,0 +1,60 @@
+package autometrics // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// randPool holds the pseudo-random generators used for the synthetic trace and span IDs.
+//
+// We are using PRNGs because the IDs are generated in PreInstrument, so they can have a noticeable
+// impact on the performance of instrumented code, and pseudo randomness is enough for our use cases.
+// A *rand.Rand is not safe for concurrent use: the pool gives each goroutine its own generator
+// (a sync.Pool keeps a cache per processor), without any lock on the hot path.
+var randPool = sync.Pool{
+	New: func() interface{} {
+		return rand.New(rand.NewSource(randomSeed()))
+	},
+}
+
+// randomSeed returns a seed from crypto/rand, so that the generators of the pool, and of the instances of
+// a service started at the same time, do not generate the same IDs.
+//
+// It falls back to the current time if crypto/rand fails.
+func randomSeed() int64 {
+	var seed [8]byte
+	if _, err := cryptorand.Read(seed[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.LittleEndian.Uint64(seed[:]))
+}
+
+// randomUint64 returns a non-zero pseudo-random number.
+//
+// It is safe for concurrent use.
+func randomUint64() uint64 {
+	generator := randPool.Get().(*rand.Rand)
+	value := generator.Uint64()
+	for value == 0 {
+		value = generator.Uint64()
+	}
+	randPool.Put(generator)
+
+	return value
+}
+
+// newTraceID generates a new, valid (non-zero), [TraceID].
+func newTraceID() (tid TraceID) {
+	binary.BigEndian.PutUint64(tid[:8], randomUint64())
+	binary.BigEndian.PutUint64(tid[8:], randomUint64())
+	return tid
+}
+
+// newSpanID generates a new, valid (non-zero), [SpanID].
+func newSpanID() (sid SpanID) {
+	binary.BigEndian.PutUint64(sid[:], randomUint64())
+	return sid
+}
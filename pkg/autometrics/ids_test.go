@@ -0,0 +1,72 @@
+package autometrics
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConcurrentIDs generates IDs from many goroutines; run with -race to check the generator.
+func TestConcurrentIDs(t *testing.T) {
+	const goroutines, perGoroutine = 8, 1000
+
+	ids := make([][]TraceID, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				ids[g] = append(ids[g], GenerateTraceId())
+				_ = FillTracingInfo(context.Background())
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	seen := make(map[TraceID]bool)
+	for _, generated := range ids {
+		for _, tid := range generated {
+			assert.NotEqual(t, TraceID{}, tid, "The generated IDs must be valid.")
+			assert.False(t, seen[tid], "The generated IDs must be unique.")
+			seen[tid] = true
+		}
+	}
+}
+
+func TestSyntheticTraceIDs(t *testing.T) {
+	defer SetSyntheticTraceIDs(true)
+	SetSyntheticTraceIDs(false)
+
+	ctx := FillTracingInfo(context.Background())
+	_, ok := GetTraceID(ctx)
+	assert.False(t, ok, "No trace ID should be generated.")
+	_, ok = GetSpanID(ctx)
+	assert.False(t, ok, "No span ID should be generated.")
+
+	tid := TraceID{1}
+	ctx = FillTracingInfo(SetTraceID(context.Background(), tid))
+	actual, _ := GetTraceID(ctx)
+	assert.Equal(t, tid, actual, "The trace ID of the context should be kept.")
+}
+
+func BenchmarkGenerateTraceIdParallel(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = GenerateTraceId()
+		}
+	})
+}
+
+func BenchmarkFillTracingInfoParallel(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		for pb.Next() {
+			_ = FillTracingInfo(ctx)
+		}
+	})
+}
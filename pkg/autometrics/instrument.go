@@ -1,6 +1,7 @@
 package autometrics
 
 import (
+	"context"
 	"reflect"
 	"runtime"
 	"strings"
@@ -10,40 +11,92 @@ import (
 //
 // It also returns the information about its grandparent.
 //
-// The module name and the parent module names are cropped to their last part, because the generator we use
-// only has access to the last "package" name in `GOPACKAGE` environment variable.
-//
-// If there is a way to obtain programmatically the fully qualified package name in go-generate arguments,
-// then we can lift this artificial limitation here and use the full "module name" from the caller information.
-// Currently this compromise is the only way to have the documentation links generator creating correct
-// queries.
+// The function names are the names of the declarations, the same as the ones the generator uses in
+// the documentation links: the type arguments of generic functions are removed, methods only keep the
+// method name, and closures are attributed to the function that declares them. The module name is the
+// package path, followed by the receiver type for methods. See [ReflectFunctionModuleName] for the
+// same naming applied to a function value.
 func CallerInfo() (callInfo CallInfo) {
-	programCounters := make([]uintptr, 15)
-
-	// skip 3 frames to start with:
+	// skip 4 frames to start with:
 	// frame 0: internal function called by `runtime.Callers`
-	// frame 1: us calling `runtime.Callers` (this function)
-	// frame 2: Instrument() calling this function -- we don't really care about our own library code
-	entries := runtime.Callers(3, programCounters)
+	// frame 1: callerInfo calling `runtime.Callers`
+	// frame 2: us calling callerInfo (this function)
+	// frame 3: PreInstrument() calling this function -- we don't really care about our own library code
+	return callerInfo(4)
+}
 
-	frames := runtime.CallersFrames(programCounters[:entries])
-	frame, hasParent := frames.Next()
+// ResolveCallInfo returns the [CallInfo] of the instrumented function that called the function that called
+// this function, like [CallerInfo].
+//
+// The identity of the function is taken from the context when the instrumented code sets it (see
+// [WithFunctionName] and [WithModuleName]), so that only the caller is looked up in the call stack.
+//
+// The caller is left empty if the context disables the tracking of the caller name (see [WithCallerName]),
+// and the call stack is not walked at all if the identity of the function is set as well.
+func ResolveCallInfo(ctx context.Context) (callInfo CallInfo) {
+	state := stateOf(ctx)
+	if state == nil {
+		return callerInfo(4)
+	}
+	if state.functionName == "" || state.moduleName == "" {
+		callInfo = callerInfo(4)
+		if state.functionName != "" {
+			callInfo.FuncName = state.functionName
+		}
+		if state.moduleName != "" {
+			callInfo.ModuleName = state.moduleName
+		}
+		if state.noCallerName {
+			callInfo.ParentFuncName, callInfo.ParentModuleName = "", ""
+		}
+		return callInfo
+	}
+
+	if !state.noCallerName {
+		// skip 4 frames to get the caller of the instrumented function directly:
+		// frame 0: internal function called by `runtime.Callers`
+		// frame 1: us calling `runtime.Callers` (this function)
+		// frame 2: PreInstrument() calling this function
+		// frame 3: the instrumented function
+		var programCounters [1]uintptr
+		runtime.Callers(4, programCounters[:])
+
+		callInfo = parentInfoCache.Get(programCounters[0], parentInfoOf)
+	}
 
-	functionName := frame.Function
-	index := strings.LastIndex(functionName, ".")
+	callInfo.FuncName, callInfo.ModuleName = state.functionName, state.moduleName
 
-	if index == -1 {
-		callInfo.FuncName = functionName
-	} else {
-		callInfo.ModuleName = strings.ReplaceAll(strings.ReplaceAll(strings.ReplaceAll(
-			functionName[:index],
-			"(", ""),
-			")", ""),
-			"*", "")
+	return callInfo
+}
+
+// callerInfo returns the [CallInfo] of the function skip frames up the call stack, as counted by runtime.Callers.
+func callerInfo(skip int) CallInfo {
+	var programCounters [2]uintptr
+
+	// The program counters of the function and of its caller are enough to get both frames, even
+	// when the function is inlined in its caller.
+	runtime.Callers(skip, programCounters[:])
 
-		callInfo.FuncName = functionName[index+1:]
+	return callerInfoCache.Get(programCounters, callInfoOf)
+}
+
+// callerInfoCache caches the [CallInfo] of the call sites, keyed by the program counters of the
+// function and of its caller, as resolving the frames and splitting the names is the most costly
+// part of [CallerInfo].
+var callerInfoCache SeriesCache[[2]uintptr, CallInfo]
+
+// callInfoOf returns the [CallInfo] of the frames of the program counters returned by runtime.Callers.
+func callInfoOf(programCounters [2]uintptr) (callInfo CallInfo) {
+	entries := len(programCounters)
+	for entries > 0 && programCounters[entries-1] == 0 {
+		entries--
 	}
 
+	frames := runtime.CallersFrames(programCounters[:entries])
+	frame, hasParent := frames.Next()
+
+	callInfo.ModuleName, callInfo.FuncName = splitFunctionName(frame.Function)
+
 	if !hasParent {
 		return
 	}
@@ -51,22 +104,24 @@ func CallerInfo() (callInfo CallInfo) {
 	// Do the same with the parent
 	parentFrame, _ := frames.Next()
 
-	parentFunctionName := parentFrame.Function
-	index = strings.LastIndex(parentFunctionName, ".")
-
-	if index == -1 {
-		callInfo.ParentFuncName = parentFunctionName
-	} else {
-		moduleIndex := strings.LastIndex(parentFunctionName[:index], ".")
-		if moduleIndex == -1 {
-			callInfo.ParentModuleName = parentFunctionName[:index]
-		} else {
-			callInfo.ParentModuleName = parentFunctionName[moduleIndex+1 : index]
-		}
+	callInfo.ParentModuleName, callInfo.ParentFuncName = splitFunctionName(parentFrame.Function)
+
+	return
+}
 
-		callInfo.ParentFuncName = parentFunctionName[index+1:]
+// parentInfoCache caches the caller part of the [CallInfo] of the call sites, keyed by the program
+// counter of the caller, for the instrumented functions that know their own name (see [ResolveCallInfo]).
+var parentInfoCache SeriesCache[uintptr, CallInfo]
+
+// parentInfoOf returns a [CallInfo] with only the caller fields set, from the program counter of the caller.
+func parentInfoOf(programCounter uintptr) (callInfo CallInfo) {
+	if programCounter == 0 {
+		return
 	}
 
+	frame, _ := runtime.CallersFrames([]uintptr{programCounter}).Next()
+	callInfo.ParentModuleName, callInfo.ParentFuncName = splitFunctionName(frame.Function)
+
 	return
 }
 
@@ -78,18 +133,97 @@ func CallerInfo() (callInfo CallInfo) {
 func ReflectFunctionModuleName(f interface{}) (callInfo CallInfo) {
 	functionName := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
 
-	index := strings.LastIndex(functionName, ".")
-	if index == -1 {
-		callInfo.FuncName = functionName
-	} else {
-		moduleIndex := strings.LastIndex(functionName[:index], ".")
-		if moduleIndex == -1 {
-			callInfo.ModuleName = functionName[:index]
-		} else {
-			callInfo.ModuleName = functionName[moduleIndex+1 : index]
+	callInfo.ModuleName, callInfo.FuncName = splitFunctionName(functionName)
+
+	return callInfo
+}
+
+// splitFunctionName splits the fully qualified name of a function, as given by [runtime.Frame.Function],
+// into a module name and a function name.
+//
+// The fully qualified names have the forms:
+//   - "path/to/pkg.Func" for functions,
+//   - "path/to/pkg.Type.Method" and "path/to/pkg.(*Type).Method" for methods,
+//   - "path/to/pkg.Func[...]" and "path/to/pkg.(*Type[...]).Method" for generic code (older
+//     versions of Go list the type shapes instead of "..."),
+//   - "path/to/pkg.Func.func1", "path/to/pkg.Func.func1.2" or "path/to/pkg.Func.gowrap1" for closures,
+//   - "path/to/pkg.(*Type).Method-fm" for method values.
+//
+// The module name is the package path, followed by the receiver type name for methods, and
+// the function name is the name of the declaration, so "Method" for all the method forms and
+// "Func" for the closures declared in Func.
+func splitFunctionName(fullName string) (moduleName, funcName string) {
+	fullName = strings.TrimSuffix(removeTypeArguments(fullName), "-fm")
+
+	// The last element of the package path is the only one that can contain dots, which
+	// are escaped as "%2e" by the compiler.
+	packageEnd := strings.LastIndex(fullName, "/") + 1
+	dot := strings.Index(fullName[packageEnd:], ".")
+	if dot == -1 {
+		return "", fullName
+	}
+	packagePath := fullName[:packageEnd+dot]
+
+	elements := strings.Split(fullName[packageEnd+dot+1:], ".")
+	declaration := elements
+	for len(declaration) > 1 && isClosureName(declaration[len(declaration)-1]) {
+		declaration = declaration[:len(declaration)-1]
+	}
+	// Closures declared in package-level variables are in the "glob." pseudo function,
+	// they keep their generated name as there is no declaration to attribute them to.
+	if declaration[len(declaration)-1] == "" {
+		declaration = elements
+	}
+
+	funcName = declaration[len(declaration)-1]
+	moduleName = packagePath
+	if len(declaration) > 1 && declaration[0] != "glob" {
+		receiver := strings.TrimPrefix(strings.TrimSuffix(strings.TrimPrefix(declaration[0], "("), ")"), "*")
+		moduleName = packagePath + "." + receiver
+	}
+
+	return moduleName, funcName
+}
+
+// removeTypeArguments removes all the type arguments between square brackets in a function name.
+func removeTypeArguments(name string) string {
+	if !strings.Contains(name, "[") {
+		return name
+	}
+
+	var builder strings.Builder
+	depth := 0
+	for _, char := range name {
+		switch {
+		case char == '[':
+			depth++
+		case char == ']' && depth > 0:
+			depth--
+		case depth == 0:
+			builder.WriteRune(char)
 		}
-		callInfo.FuncName = functionName[index+1:]
 	}
 
-	return callInfo
+	return builder.String()
+}
+
+// isClosureName returns true if the element of a function name is generated by the compiler
+// for a closure, like "func1", "gowrap1", "deferwrap1", or "2" for nested closures.
+func isClosureName(element string) bool {
+	for _, prefix := range []string{"func", "gowrap", "deferwrap"} {
+		if strings.HasPrefix(element, prefix) && len(element) > len(prefix) {
+			element = element[len(prefix):]
+			break
+		}
+	}
+	if element == "" {
+		return false
+	}
+	for _, char := range element {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,211 @@
+package autometrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPackagePath = "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+
+func TestSplitFunctionName(t *testing.T) {
+	tests := []struct {
+		fullName   string
+		moduleName string
+		funcName   string
+	}{
+		{fullName: "main.main", moduleName: "main", funcName: "main"},
+		{fullName: "github.com/org/project/pkg.Func", moduleName: "github.com/org/project/pkg", funcName: "Func"},
+		{fullName: "gopkg.in/yaml%2ev3.Marshal", moduleName: "gopkg.in/yaml%2ev3", funcName: "Marshal"},
+		{fullName: "github.com/org/project/pkg.Server.Handle", moduleName: "github.com/org/project/pkg.Server", funcName: "Handle"},
+		{fullName: "github.com/org/project/pkg.(*Server).Handle", moduleName: "github.com/org/project/pkg.Server", funcName: "Handle"},
+		{fullName: "github.com/org/project/pkg.Func[...]", moduleName: "github.com/org/project/pkg", funcName: "Func"},
+		{fullName: "github.com/org/project/pkg.Func[go.shape.int,go.shape.string]", moduleName: "github.com/org/project/pkg", funcName: "Func"},
+		{fullName: "github.com/org/project/pkg.Func[go.shape.[]go.shape.map[string]int]", moduleName: "github.com/org/project/pkg", funcName: "Func"},
+		{fullName: "github.com/org/project/pkg.(*Stack[...]).Push", moduleName: "github.com/org/project/pkg.Stack", funcName: "Push"},
+		{fullName: "github.com/org/project/pkg.Stack[...].Len", moduleName: "github.com/org/project/pkg.Stack", funcName: "Len"},
+		{fullName: "github.com/org/project/pkg.(*Stack[go.shape.*uint8]).Push", moduleName: "github.com/org/project/pkg.Stack", funcName: "Push"},
+		{fullName: "github.com/org/project/pkg.Func.func1", moduleName: "github.com/org/project/pkg", funcName: "Func"},
+		{fullName: "github.com/org/project/pkg.Func.func1.2", moduleName: "github.com/org/project/pkg", funcName: "Func"},
+		{fullName: "github.com/org/project/pkg.Func.gowrap1", moduleName: "github.com/org/project/pkg", funcName: "Func"},
+		{fullName: "github.com/org/project/pkg.Func[...].func1", moduleName: "github.com/org/project/pkg", funcName: "Func"},
+		{fullName: "github.com/org/project/pkg.(*Server).Handle.func3", moduleName: "github.com/org/project/pkg.Server", funcName: "Handle"},
+		{fullName: "github.com/org/project/pkg.(*Stack[...]).Push.func1", moduleName: "github.com/org/project/pkg.Stack", funcName: "Push"},
+		{fullName: "github.com/org/project/pkg.(*Stack[...]).Push-fm", moduleName: "github.com/org/project/pkg.Stack", funcName: "Push"},
+		{fullName: "github.com/org/project/pkg.func1", moduleName: "github.com/org/project/pkg", funcName: "func1"},
+		{fullName: "github.com/org/project/pkg.glob..func1", moduleName: "github.com/org/project/pkg", funcName: "func1"},
+		{fullName: "runtime.goexit", moduleName: "runtime", funcName: "goexit"},
+		{fullName: "", moduleName: "", funcName: ""},
+	}
+
+	for _, test := range tests {
+		moduleName, funcName := splitFunctionName(test.fullName)
+		assert.Equal(t, test.moduleName, moduleName, "Unexpected module name for %s", test.fullName)
+		assert.Equal(t, test.funcName, funcName, "Unexpected function name for %s", test.fullName)
+	}
+}
+
+type genericStack[T any] struct {
+	items []T
+}
+
+func instrumentedFunction() CallInfo {
+	return preInstrument()
+}
+
+func instrumentedGenericFunction[T any](_ T) CallInfo {
+	return preInstrument()
+}
+
+func (s *genericStack[T]) instrumentedMethod() CallInfo {
+	return preInstrument()
+}
+
+func (s genericStack[T]) instrumentedValueMethod() CallInfo {
+	return preInstrument()
+}
+
+// preInstrument stands for the PreInstrument function of the implementations, that calls CallerInfo.
+//
+//go:noinline
+func preInstrument() CallInfo {
+	return CallerInfo()
+}
+
+func TestCallerInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		call       func() CallInfo
+		moduleName string
+		funcName   string
+	}{
+		{name: "function", call: instrumentedFunction, moduleName: testPackagePath, funcName: "instrumentedFunction"},
+		{
+			name:       "generic function",
+			call:       func() CallInfo { return instrumentedGenericFunction(42) },
+			moduleName: testPackagePath,
+			funcName:   "instrumentedGenericFunction",
+		},
+		{
+			name:       "generic function with a composite type",
+			call:       func() CallInfo { return instrumentedGenericFunction(map[string][]int{}) },
+			moduleName: testPackagePath,
+			funcName:   "instrumentedGenericFunction",
+		},
+		{
+			name:       "method on a generic type",
+			call:       (&genericStack[string]{}).instrumentedMethod,
+			moduleName: testPackagePath + ".genericStack",
+			funcName:   "instrumentedMethod",
+		},
+		{
+			name:       "value method on a generic type",
+			call:       genericStack[int]{}.instrumentedValueMethod,
+			moduleName: testPackagePath + ".genericStack",
+			funcName:   "instrumentedValueMethod",
+		},
+	}
+
+	for _, test := range tests {
+		callInfo := test.call()
+		assert.Equal(t, test.moduleName, callInfo.ModuleName, "Unexpected module name for the %s", test.name)
+		assert.Equal(t, test.funcName, callInfo.FuncName, "Unexpected function name for the %s", test.name)
+		// The caller is the closure in the test table, or the test function itself
+		assert.Equal(t, testPackagePath, callInfo.ParentModuleName, "Unexpected caller module name for the %s", test.name)
+		assert.Equal(t, "TestCallerInfo", callInfo.ParentFuncName, "Unexpected caller function name for the %s", test.name)
+	}
+}
+
+func instrumentedWithContext(ctx context.Context) CallInfo {
+	return preInstrumentWithContext(ctx)
+}
+
+// preInstrumentWithContext stands for the PreInstrument function of the implementations, that calls ResolveCallInfo.
+//
+//go:noinline
+func preInstrumentWithContext(ctx context.Context) CallInfo {
+	return ResolveCallInfo(ctx)
+}
+
+func TestResolveCallInfo(t *testing.T) {
+	static := []Option{WithFunctionName("Handle"), WithModuleName("example.com/api.Server")}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected CallInfo
+	}{
+		{
+			name: "call stack",
+			ctx:  NewContext(context.Background()),
+			expected: CallInfo{
+				FuncName:         "instrumentedWithContext",
+				ModuleName:       testPackagePath,
+				ParentFuncName:   "TestResolveCallInfo",
+				ParentModuleName: testPackagePath,
+			},
+		},
+		{
+			name: "static identity",
+			ctx:  NewContextWithOpts(context.Background(), static...),
+			expected: CallInfo{
+				FuncName:         "Handle",
+				ModuleName:       "example.com/api.Server",
+				ParentFuncName:   "TestResolveCallInfo",
+				ParentModuleName: testPackagePath,
+			},
+		},
+		{
+			name:     "static identity without caller",
+			ctx:      NewContextWithOpts(context.Background(), append(static, WithCallerName(false))...),
+			expected: CallInfo{FuncName: "Handle", ModuleName: "example.com/api.Server"},
+		},
+		{
+			name:     "call stack without caller",
+			ctx:      NewContextWithOpts(context.Background(), WithCallerName(false)),
+			expected: CallInfo{FuncName: "instrumentedWithContext", ModuleName: testPackagePath},
+		},
+		{
+			name: "static function name only",
+			ctx:  NewContextWithOpts(context.Background(), WithFunctionName("Handle")),
+			expected: CallInfo{
+				FuncName:         "Handle",
+				ModuleName:       testPackagePath,
+				ParentFuncName:   "TestResolveCallInfo",
+				ParentModuleName: testPackagePath,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, instrumentedWithContext(test.ctx), "Unexpected call info for the %s", test.name)
+	}
+
+	parent := NewContextWithOpts(context.Background(), static...)
+	assert.Equal(t, "instrumentedWithContext", instrumentedWithContext(NewContext(parent)).FuncName,
+		"The identity of the function should not be inherited from the parent call.")
+}
+
+func TestReflectFunctionModuleName(t *testing.T) {
+	tests := []struct {
+		name       string
+		function   interface{}
+		moduleName string
+		funcName   string
+	}{
+		{name: "function", function: instrumentedFunction, moduleName: testPackagePath, funcName: "instrumentedFunction"},
+		{name: "generic function", function: instrumentedGenericFunction[int], moduleName: testPackagePath, funcName: "instrumentedGenericFunction"},
+		{name: "method expression on a generic type", function: (*genericStack[int]).instrumentedMethod, moduleName: testPackagePath + ".genericStack", funcName: "instrumentedMethod"},
+		{name: "method value on a generic type", function: (&genericStack[int]{}).instrumentedMethod, moduleName: testPackagePath + ".genericStack", funcName: "instrumentedMethod"},
+		{name: "closure", function: func() {}, moduleName: testPackagePath, funcName: "TestReflectFunctionModuleName"},
+	}
+
+	for _, test := range tests {
+		callInfo := ReflectFunctionModuleName(test.function)
+		assert.Equal(t, test.moduleName, callInfo.ModuleName, "Unexpected module name for the %s", test.name)
+		assert.Equal(t, test.funcName, callInfo.FuncName, "Unexpected function name for the %s", test.name)
+		assert.Empty(t, callInfo.ParentFuncName)
+		assert.Empty(t, callInfo.ParentModuleName)
+	}
+}
@@ -1,6 +1,7 @@
 package autometrics // import "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
 
 import (
+	"errors"
 	"time"
 )
 
@@ -12,6 +13,10 @@ var (
 )
 
 const (
+	// AllowCustomLatenciesFlag is the generator flag that disables the validation of latency thresholds.
+	//
+	// Deprecated: declare the histogram buckets with the --buckets flag or in the configuration file of the
+	// generator instead, so that the latency thresholds are still validated.
 	AllowCustomLatenciesFlag = "-custom-latency"
 )
 
@@ -99,3 +104,78 @@ type SuccessSlo struct {
 	// Objective is the success rate allowed for the given function, from 0 to 1.
 	Objective float64
 }
+
+// Result is the outcome of a call of an instrumented function.
+type Result int
+
+const (
+	// ResultOk is a successful call, recorded with the "ok" result.
+	ResultOk Result = iota
+	// ResultError is a failed call, recorded with the "error" result. It counts against the
+	// success rate objectives.
+	ResultError
+	// ResultIgnored is a call that is not recorded in the calls counter and the latency
+	// histogram at all, so it counts neither as a success nor as a failure.
+	ResultIgnored
+)
+
+// String returns the value of the result label for the result.
+func (r Result) String() string {
+	switch r {
+	case ResultOk:
+		return "ok"
+	case ResultError:
+		return "error"
+	case ResultIgnored:
+		return "ignored"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrorClassifier decides the [Result] of a call of an instrumented function from the non-nil
+// error it returned.
+//
+// For example, a classifier can record the calls returning [context.Canceled] (a client hanging up)
+// or sql.ErrNoRows as successful, so that they do not consume the error budget.
+type ErrorClassifier func(error) Result
+
+const (
+	// OtherErrorType is the error type of the errors that match none of the registered [ErrorType]s.
+	OtherErrorType = "other"
+	// PanicErrorType is the error type of the calls that panicked, unless a registered [ErrorType] matches
+	// the [PanicError].
+	PanicErrorType = "panic"
+)
+
+// ErrorType is a kind of error, recorded as the error type of the failed calls when the error type
+// label is enabled.
+type ErrorType struct {
+	// Name is the value of the error type label for the matching errors.
+	Name string
+	// Match returns true if the error is of this type.
+	Match func(error) bool
+}
+
+// ErrorSentinel returns the [ErrorType] of the errors that match the target according to [errors.Is],
+// like io.EOF or sql.ErrNoRows.
+func ErrorSentinel(name string, target error) ErrorType {
+	return ErrorType{
+		Name: name,
+		Match: func(err error) bool {
+			return errors.Is(err, target)
+		},
+	}
+}
+
+// ErrorAs returns the [ErrorType] of the errors that can be assigned to a T according to [errors.As],
+// like *fs.PathError.
+func ErrorAs[T error](name string) ErrorType {
+	return ErrorType{
+		Name: name,
+		Match: func(err error) bool {
+			var target T
+			return errors.As(err, &target)
+		},
+	}
+}
@@ -0,0 +1,48 @@
+// Package midfasthttp contains the middleware that makes the tracing information of the requests available
+// to the code autometrics generates for github.com/valyala/fasthttp handlers.
+//
+// The package does not depend on fasthttp: the middleware is generic over the type of the context, and
+// is instantiated with *fasthttp.RequestCtx.
+package midfasthttp // import "github.com/autometrics-dev/autometrics-go/pkg/midfasthttp"
+
+import (
+	"context"
+	"encoding/hex"
+
+	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+// Ctx contains the methods of *fasthttp.RequestCtx that the middleware uses.
+type Ctx interface {
+	context.Context
+	UserValue(key interface{}) interface{}
+	SetUserValue(key interface{}, value interface{})
+}
+
+// Autometrics wraps the handler with a middleware that stores the trace ID and span ID of the request in the
+// user values of the fasthttp.RequestCtx, hex-encoded under the [am.MiddlewareTraceIDKey] and
+// [am.MiddlewareSpanIDKey] keys, where the code generated for handlers taking a *fasthttp.RequestCtx reads them.
+//
+// The IDs are the ones in the context of the request if they are set, otherwise a new trace ID is generated,
+// unless the synthetic IDs are disabled (see [am.SetSyntheticTraceIDs]).
+//
+// The returned function is a fasthttp.RequestHandler when C is *fasthttp.RequestCtx:
+//
+//	fasthttp.ListenAndServe(":8080", midfasthttp.Autometrics(handler))
+func Autometrics[C Ctx](next func(C)) func(C) {
+	return func(c C) {
+		tid, ok := am.GetTraceID(c)
+		if !ok && am.GetSyntheticTraceIDs() {
+			tid, ok = am.GenerateTraceId(), true
+		}
+		if ok {
+			c.SetUserValue(am.MiddlewareTraceIDKey, hex.EncodeToString(tid[:]))
+		}
+
+		if sid, ok := am.GetSpanID(c); ok {
+			c.SetUserValue(am.MiddlewareSpanIDKey, hex.EncodeToString(sid[:]))
+		}
+
+		next(c)
+	}
+}
@@ -0,0 +1,65 @@
+package midfasthttp
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// testCtx implements the methods of *fasthttp.RequestCtx used by the middleware.
+type testCtx struct {
+	context.Context
+	userValues map[interface{}]interface{}
+}
+
+func newTestCtx(ctx context.Context) *testCtx {
+	return &testCtx{Context: ctx, userValues: make(map[interface{}]interface{})}
+}
+
+func (c *testCtx) UserValue(key interface{}) interface{} {
+	return c.userValues[key]
+}
+
+func (c *testCtx) SetUserValue(key interface{}, value interface{}) {
+	c.userValues[key] = value
+}
+
+// TestAutometrics tests that the IDs stored by the middleware are the ones the generated code reads.
+func TestAutometrics(t *testing.T) {
+	traceID := am.GenerateTraceId()
+	spanID := am.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+
+	var seenTraceID, seenSpanID []byte
+
+	middleware := Autometrics(func(c *testCtx) {
+		seenTraceID = prom.DecodeValue(c.UserValue(am.MiddlewareTraceIDKey))
+		seenSpanID = prom.DecodeValue(c.UserValue(am.MiddlewareSpanIDKey))
+	})
+
+	middleware(newTestCtx(am.SetSpanID(am.SetTraceID(context.Background(), traceID), spanID)))
+
+	assert.Equal(t, traceID[:], seenTraceID, "The trace ID of the context must be stored.")
+	assert.Equal(t, spanID[:], seenSpanID, "The span ID of the context must be stored.")
+
+	middleware(newTestCtx(context.Background()))
+
+	assert.Len(t, seenTraceID, len(am.TraceID{}), "A trace ID must be generated.")
+	assert.NotEqual(t, hex.EncodeToString(traceID[:]), hex.EncodeToString(seenTraceID), "A new trace ID must be generated.")
+	assert.Nil(t, seenSpanID, "No span ID must be stored without a span.")
+}
+
+// TestAutometricsWithoutSyntheticTraceIDs tests that the middleware does not generate trace IDs when they are disabled.
+func TestAutometricsWithoutSyntheticTraceIDs(t *testing.T) {
+	defer am.SetSyntheticTraceIDs(true)
+	am.SetSyntheticTraceIDs(false)
+
+	c := newTestCtx(context.Background())
+	Autometrics(func(*testCtx) {})(c)
+
+	assert.Nil(t, c.UserValue(am.MiddlewareTraceIDKey), "No trace ID must be stored without tracing information.")
+}
@@ -0,0 +1,53 @@
+// Package midfiber contains the middleware that makes the tracing information of the requests available
+// to the code autometrics generates for github.com/gofiber/fiber/v2 handlers.
+//
+// The package does not depend on Fiber: the middleware is generic over the type of the context, and
+// is instantiated with *fiber.Ctx.
+package midfiber // import "github.com/autometrics-dev/autometrics-go/pkg/midfiber"
+
+import (
+	"context"
+	"encoding/hex"
+
+	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+// Ctx contains the methods of *fiber.Ctx that the middleware uses.
+type Ctx interface {
+	UserContext() context.Context
+	SetUserContext(ctx context.Context)
+	Locals(key interface{}, value ...interface{}) interface{}
+	Next() error
+}
+
+// Autometrics returns a middleware that stores the trace ID and span ID of the request in the locals of
+// the fiber.Ctx, hex-encoded under the [am.MiddlewareTraceIDKey] and [am.MiddlewareSpanIDKey] keys, where
+// the code generated for handlers taking a *fiber.Ctx reads them.
+//
+// The IDs are the ones in the user context of the request if they are set, otherwise a new trace ID is
+// generated and also added to the user context, unless the synthetic IDs are disabled (see
+// [am.SetSyntheticTraceIDs]).
+//
+// The returned function is a fiber.Handler when C is *fiber.Ctx:
+//
+//	app.Use(midfiber.Autometrics[*fiber.Ctx]())
+func Autometrics[C Ctx]() func(C) error {
+	return func(c C) error {
+		ctx := c.UserContext()
+
+		tid, ok := am.GetTraceID(ctx)
+		if !ok && am.GetSyntheticTraceIDs() {
+			tid, ok = am.GenerateTraceId(), true
+			c.SetUserContext(am.SetTraceID(ctx, tid))
+		}
+		if ok {
+			c.Locals(am.MiddlewareTraceIDKey, hex.EncodeToString(tid[:]))
+		}
+
+		if sid, ok := am.GetSpanID(ctx); ok {
+			c.Locals(am.MiddlewareSpanIDKey, hex.EncodeToString(sid[:]))
+		}
+
+		return c.Next()
+	}
+}
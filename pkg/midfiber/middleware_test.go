@@ -0,0 +1,103 @@
+package midfiber
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	prom "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+)
+
+// testCtx implements the methods of *fiber.Ctx used by the middleware.
+type testCtx struct {
+	userContext context.Context
+	locals      map[interface{}]interface{}
+	handler     func(*testCtx) error
+}
+
+func (c *testCtx) UserContext() context.Context {
+	if c.userContext == nil {
+		return context.Background()
+	}
+	return c.userContext
+}
+
+func (c *testCtx) SetUserContext(ctx context.Context) {
+	c.userContext = ctx
+}
+
+func (c *testCtx) Locals(key interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 {
+		return c.locals[key]
+	}
+	c.locals[key] = value[0]
+	return value[0]
+}
+
+func (c *testCtx) Next() error {
+	return c.handler(c)
+}
+
+// TestAutometrics tests that the IDs stored by the middleware are the ones the generated code reads.
+func TestAutometrics(t *testing.T) {
+	traceID := am.GenerateTraceId()
+	spanID := am.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+
+	var seenTraceID, seenSpanID []byte
+	var contextTraceID am.TraceID
+
+	handler := func(c *testCtx) error {
+		seenTraceID = prom.DecodeValue(c.Locals(am.MiddlewareTraceIDKey))
+		seenSpanID = prom.DecodeValue(c.Locals(am.MiddlewareSpanIDKey))
+		contextTraceID, _ = am.GetTraceID(c.UserContext())
+		return nil
+	}
+	middleware := Autometrics[*testCtx]()
+
+	err := middleware(&testCtx{
+		userContext: am.SetSpanID(am.SetTraceID(context.Background(), traceID), spanID),
+		locals:      make(map[interface{}]interface{}),
+		handler:     handler,
+	})
+	if err != nil {
+		t.Fatalf("error running the middleware: %s", err)
+	}
+
+	assert.Equal(t, traceID[:], seenTraceID, "The trace ID of the user context must be stored.")
+	assert.Equal(t, spanID[:], seenSpanID, "The span ID of the user context must be stored.")
+
+	err = middleware(&testCtx{locals: make(map[interface{}]interface{}), handler: handler})
+	if err != nil {
+		t.Fatalf("error running the middleware: %s", err)
+	}
+
+	assert.Len(t, seenTraceID, len(am.TraceID{}), "A trace ID must be generated.")
+	assert.Equal(t, hex.EncodeToString(contextTraceID[:]), hex.EncodeToString(seenTraceID), "The generated trace ID must be in the user context.")
+	assert.Nil(t, seenSpanID, "No span ID must be stored without a span.")
+}
+
+// TestAutometricsWithoutSyntheticTraceIDs tests that the middleware does not generate trace IDs when they are disabled.
+func TestAutometricsWithoutSyntheticTraceIDs(t *testing.T) {
+	defer am.SetSyntheticTraceIDs(true)
+	am.SetSyntheticTraceIDs(false)
+
+	var seenTraceID interface{}
+	var hasContextTraceID bool
+
+	handler := func(c *testCtx) error {
+		seenTraceID = c.Locals(am.MiddlewareTraceIDKey)
+		_, hasContextTraceID = am.GetTraceID(c.UserContext())
+		return nil
+	}
+
+	err := Autometrics[*testCtx]()(&testCtx{locals: make(map[interface{}]interface{}), handler: handler})
+	if err != nil {
+		t.Fatalf("error running the middleware: %s", err)
+	}
+
+	assert.Nil(t, seenTraceID, "No trace ID must be stored without tracing information.")
+	assert.False(t, hasContextTraceID, "No trace ID must be added to the user context.")
+}
@@ -0,0 +1,183 @@
+package midhttp
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+const (
+	// TraceparentHeader is the header of the W3C Trace Context propagation format.
+	TraceparentHeader = "Traceparent"
+	// B3Header is the header of the single header B3 propagation format.
+	B3Header = "B3"
+	// B3TraceIdHeader is the trace ID header of the multiple headers B3 propagation format.
+	B3TraceIdHeader = "X-B3-Traceid"
+	// B3SpanIdHeader is the span ID header of the multiple headers B3 propagation format.
+	B3SpanIdHeader = "X-B3-Spanid"
+	// B3SampledHeader is the sampling decision header of the multiple headers B3 propagation format.
+	B3SampledHeader = "X-B3-Sampled"
+	// B3FlagsHeader is the debug flag header of the multiple headers B3 propagation format.
+	B3FlagsHeader = "X-B3-Flags"
+)
+
+// TraceParent is the tracing information propagated by a caller in the headers of a request.
+type TraceParent struct {
+	TraceID am.TraceID
+	// SpanID is the ID of the span of the caller, which is the parent of the spans of the request.
+	SpanID am.SpanID
+	// Sampled is the sampling decision of the caller.
+	Sampled bool
+	// SamplingKnown is false when the caller deferred the sampling decision, which is only possible with B3.
+	SamplingKnown bool
+}
+
+// ParseTraceparent parses the value of a W3C `traceparent` header.
+//
+// It returns false if the value is not valid, following the rules of https://www.w3.org/TR/trace-context/#traceparent-header
+func ParseTraceparent(value string) (TraceParent, bool) {
+	fields := strings.Split(strings.TrimSpace(value), "-")
+	if len(fields) < 4 {
+		return TraceParent{}, false
+	}
+
+	version, ok := decodeHex(fields[0], 1)
+	// Version ff is forbidden, and version 00 has exactly 4 fields. Later versions can add fields.
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(fields) != 4) {
+		return TraceParent{}, false
+	}
+
+	traceID, okTrace := decodeHex(fields[1], len(am.TraceID{}))
+	spanID, okSpan := decodeHex(fields[2], len(am.SpanID{}))
+	flags, okFlags := decodeHex(fields[3], 1)
+	if !okTrace || !okSpan || !okFlags {
+		return TraceParent{}, false
+	}
+
+	parent := TraceParent{Sampled: flags[0]&1 == 1, SamplingKnown: true}
+	copy(parent.TraceID[:], traceID)
+	copy(parent.SpanID[:], spanID)
+
+	return parent, parent.valid()
+}
+
+// FormatTraceparent returns the value of the W3C `traceparent` header for a span.
+func FormatTraceparent(tid am.TraceID, sid am.SpanID, sampled bool) string {
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+
+	return "00-" + hex.EncodeToString(tid[:]) + "-" + hex.EncodeToString(sid[:]) + "-" + flags
+}
+
+// ParseB3 parses the B3 headers of a request, in the single header format first, and in the multiple
+// headers format otherwise.
+//
+// 64-bit trace IDs are left-padded with zeros. It returns false if no valid B3 header is found.
+func ParseB3(header http.Header) (TraceParent, bool) {
+	if value := header.Get(B3Header); value != "" {
+		// b3: {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}, where the last 2 fields are optional.
+		fields := strings.Split(strings.TrimSpace(value), "-")
+		if len(fields) < 2 || len(fields) > 4 {
+			return TraceParent{}, false
+		}
+		sampling := ""
+		if len(fields) > 2 {
+			sampling = fields[2]
+		}
+
+		return parseB3(fields[0], fields[1], sampling)
+	}
+
+	sampling := header.Get(B3SampledHeader)
+	if header.Get(B3FlagsHeader) == "1" {
+		sampling = "d"
+	}
+
+	return parseB3(header.Get(B3TraceIdHeader), header.Get(B3SpanIdHeader), sampling)
+}
+
+func parseB3(traceIdValue, spanIdValue, sampling string) (TraceParent, bool) {
+	var parent TraceParent
+
+	if len(traceIdValue) == 16 {
+		traceIdValue = strings.Repeat("0", 16) + traceIdValue
+	}
+	traceID, okTrace := decodeHex(traceIdValue, len(am.TraceID{}))
+	spanID, okSpan := decodeHex(spanIdValue, len(am.SpanID{}))
+	if !okTrace || !okSpan {
+		return TraceParent{}, false
+	}
+	copy(parent.TraceID[:], traceID)
+	copy(parent.SpanID[:], spanID)
+
+	switch sampling {
+	case "1", "d", "true":
+		parent.Sampled = true
+		parent.SamplingKnown = true
+	case "0", "false":
+		parent.SamplingKnown = true
+	}
+
+	return parent, parent.valid()
+}
+
+// ExtractTracingInfo adds the tracing information propagated in the headers of a request to the context,
+// so that the instrumentation of the request continues the trace of the caller, and has the span of the
+// caller as parent.
+//
+// The W3C `traceparent` header is used first, and the B3 headers otherwise. The context is left untouched
+// when no valid header is found.
+func ExtractTracingInfo(ctx context.Context, header http.Header) context.Context {
+	parent, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		parent, ok = ParseB3(header)
+	}
+	if !ok {
+		return ctx
+	}
+
+	ctx = am.SetTraceID(ctx, parent.TraceID)
+	// The instrumentation moves the span ID of the context to the parent span ID when it starts the span
+	// of the request.
+	ctx = am.SetSpanID(ctx, parent.SpanID)
+	if parent.SamplingKnown {
+		ctx = am.SetTraceSampled(ctx, parent.Sampled)
+	}
+
+	return ctx
+}
+
+// InjectTracingInfo sets the W3C `traceparent` header of the span of the context in header.
+//
+// Spans with an unknown sampling decision are reported as not sampled.
+func InjectTracingInfo(ctx context.Context, header http.Header) {
+	tid, okTrace := am.GetTraceID(ctx)
+	sid, okSpan := am.GetSpanID(ctx)
+	if !okTrace || !okSpan {
+		return
+	}
+	sampled, _ := am.GetTraceSampled(ctx)
+
+	header.Set(TraceparentHeader, FormatTraceparent(tid, sid, sampled))
+}
+
+func (p TraceParent) valid() bool {
+	return p.TraceID != (am.TraceID{}) && p.SpanID != (am.SpanID{})
+}
+
+// decodeHex decodes a lowercase hexadecimal value of exactly size bytes.
+func decodeHex(value string, size int) ([]byte, bool) {
+	if len(value) != 2*size || strings.ToLower(value) != value {
+		return nil, false
+	}
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
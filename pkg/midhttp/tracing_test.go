@@ -0,0 +1,108 @@
+package midhttp
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+)
+
+var (
+	testTraceID = am.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	testSpanID  = am.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+)
+
+func TestParseTraceparent(t *testing.T) {
+	parent, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.True(t, ok)
+	assert.Equal(t, TraceParent{TraceID: testTraceID, SpanID: testSpanID, Sampled: true, SamplingKnown: true}, parent)
+
+	parent, ok = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	assert.True(t, ok)
+	assert.False(t, parent.Sampled)
+
+	_, ok = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future")
+	assert.True(t, ok, "Later versions can have more fields.")
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+	} {
+		_, ok := ParseTraceparent(invalid)
+		assert.False(t, ok, "%q should be invalid", invalid)
+	}
+}
+
+func TestFormatTraceparent(t *testing.T) {
+	value := FormatTraceparent(testTraceID, testSpanID, true)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", value)
+
+	parent, ok := ParseTraceparent(FormatTraceparent(testTraceID, testSpanID, false))
+	assert.True(t, ok)
+	assert.Equal(t, TraceParent{TraceID: testTraceID, SpanID: testSpanID, SamplingKnown: true}, parent)
+}
+
+func TestParseB3(t *testing.T) {
+	header := http.Header{}
+	header.Set(B3Header, "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1-05e3ac9a4f6e3b90")
+	parent, ok := ParseB3(header)
+	assert.True(t, ok)
+	assert.Equal(t, TraceParent{TraceID: testTraceID, SpanID: testSpanID, Sampled: true, SamplingKnown: true}, parent)
+
+	header = http.Header{}
+	header.Set(B3TraceIdHeader, "a3ce929d0e0e4736")
+	header.Set(B3SpanIdHeader, "00f067aa0ba902b7")
+	parent, ok = ParseB3(header)
+	assert.True(t, ok)
+	assert.Equal(t, am.TraceID{8: 0xa3, 9: 0xce, 10: 0x92, 11: 0x9d, 12: 0x0e, 13: 0x0e, 14: 0x47, 15: 0x36}, parent.TraceID, "64-bit trace IDs should be padded.")
+	assert.False(t, parent.SamplingKnown, "The sampling decision is deferred without the sampled header.")
+
+	header.Set(B3SampledHeader, "0")
+	parent, ok = ParseB3(header)
+	assert.True(t, ok)
+	assert.True(t, parent.SamplingKnown)
+	assert.False(t, parent.Sampled)
+
+	header.Set(B3FlagsHeader, "1")
+	parent, _ = ParseB3(header)
+	assert.True(t, parent.Sampled, "Debug requests are sampled.")
+
+	_, ok = ParseB3(http.Header{})
+	assert.False(t, ok)
+}
+
+func TestExtractTracingInfo(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(B3Header, "a3ce929d0e0e4736-00f067aa0ba902b7-0")
+
+	ctx := am.FillTracingInfo(ExtractTracingInfo(context.Background(), header))
+
+	tid, _ := am.GetTraceID(ctx)
+	assert.Equal(t, testTraceID, tid, "The trace of the caller should be continued.")
+	psid, _ := am.GetParentSpanID(ctx)
+	assert.Equal(t, testSpanID, psid, "The span of the caller should be the parent span.")
+	sid, _ := am.GetSpanID(ctx)
+	assert.NotEqual(t, testSpanID, sid, "A new span should be started.")
+	sampled, ok := am.GetTraceSampled(ctx)
+	assert.True(t, ok && sampled, "The traceparent header should have priority over B3.")
+
+	response := http.Header{}
+	InjectTracingInfo(ctx, response)
+	parent, ok := ParseTraceparent(response.Get(TraceparentHeader))
+	assert.True(t, ok)
+	assert.Equal(t, TraceParent{TraceID: testTraceID, SpanID: sid, Sampled: true, SamplingKnown: true}, parent)
+
+	ctx = ExtractTracingInfo(context.Background(), http.Header{})
+	_, ok = am.GetTraceID(ctx)
+	assert.False(t, ok, "The context should be untouched without tracing headers.")
+}
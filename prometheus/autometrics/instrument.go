@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"log"
-	"strconv"
+	"sync"
 	"time"
 
 	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
@@ -18,92 +18,71 @@ import (
 //
 // The first argument SHOULD be a call to PreInstrument so that
 // the "concurrent calls" gauge is correctly setup.
+//
+// If the function panics, the call is recorded as an error (so it counts
+// against the success rate objectives), and Instrument panics again with
+// the same value. Instrument must then be the deferred function itself,
+// and not be called from another deferred function.
 func Instrument(ctx context.Context, err *error) {
-	if amCtx.Err() != nil {
-		return
+	if recovered := recover(); recovered != nil {
+		instrument(ctx, &am.PanicError{Value: recovered})
+		panic(recovered)
 	}
 
-	result := "ok"
-
-	if err != nil && *err != nil {
-		result = "error"
+	var resultErr error
+	if err != nil {
+		resultErr = *err
 	}
 
-	var sloName, latencyTarget, latencyObjective, successObjective string
+	instrument(ctx, resultErr)
+}
 
-	callInfo := am.GetCallInfo(ctx)
-	buildInfo := am.GetBuildInfo(ctx)
-	slo := am.GetAlertConfiguration(ctx)
+// InstrumentCustomError is [Instrument] for functions whose error result has a custom type implementing
+// error, like *ValidationError. The generator uses it instead of Instrument for those functions.
+//
+// A nil pointer of the custom type is a successful call, see [am.CustomErrorValue].
+func InstrumentCustomError[E any](ctx context.Context, err *E) {
+	// recover only stops the panic when called directly by the deferred function,
+	// so this cannot be delegated to Instrument.
+	if recovered := recover(); recovered != nil {
+		instrument(ctx, &am.PanicError{Value: recovered})
+		panic(recovered)
+	}
+
+	var resultErr error
+	if err != nil {
+		resultErr = am.CustomErrorValue(*err)
+	}
 
-	if slo.ServiceName != "" {
-		sloName = slo.ServiceName
+	instrument(ctx, resultErr)
+}
 
-		if slo.Latency != nil {
-			latencyTarget = strconv.FormatFloat(slo.Latency.Target.Seconds(), 'f', -1, 64)
-			latencyObjective = strconv.FormatFloat(slo.Latency.Objective, 'f', -1, 64)
-		}
+// instrument records the call of the function with the error it returned.
+func instrument(ctx context.Context, err error) {
+	if amCtx.Err() != nil {
+		return
+	}
 
-		if slo.Success != nil {
-			successObjective = strconv.FormatFloat(slo.Success.Objective, 'f', -1, 64)
-		}
+	result := am.ClassifyError(ctx, err)
+	var errorType string
+	if result == am.ResultError {
+		errorType = am.ErrorTypeOf(err)
 	}
 
+	key := am.GetSeriesKey(ctx)
 	info := exemplars(ctx)
-
-	functionCallsCount.With(prometheus.Labels{
-		FunctionLabel:          callInfo.FuncName,
-		ModuleLabel:            callInfo.ModuleName,
-		CallerFunctionLabel:    callInfo.ParentFuncName,
-		CallerModuleLabel:      callInfo.ParentModuleName,
-		ResultLabel:            result,
-		TargetSuccessRateLabel: successObjective,
-		SloNameLabel:           sloName,
-		BranchLabel:            buildInfo.Branch,
-		CommitLabel:            buildInfo.Commit,
-		VersionLabel:           buildInfo.Version,
-		ServiceNameLabel:       buildInfo.Service,
-		// REVIEW: This clear mode label is added to make the metrics work when
-		// pushing metrics to a gravel gateway. To reconsider once
-		// https://github.com/sinkingpoint/prometheus-gravel-gateway/issues/28
-		// is solved
-		ClearModeLabel: ClearModeFamily,
-	}).(prometheus.ExemplarAdder).AddWithExemplar(1, info)
-
-	functionCallsDuration.With(prometheus.Labels{
-		FunctionLabel:          callInfo.FuncName,
-		ModuleLabel:            callInfo.ModuleName,
-		CallerFunctionLabel:    callInfo.ParentFuncName,
-		CallerModuleLabel:      callInfo.ParentModuleName,
-		TargetLatencyLabel:     latencyTarget,
-		TargetSuccessRateLabel: latencyObjective,
-		SloNameLabel:           sloName,
-		BranchLabel:            buildInfo.Branch,
-		CommitLabel:            buildInfo.Commit,
-		VersionLabel:           buildInfo.Version,
-		ServiceNameLabel:       buildInfo.Service,
-		// REVIEW: This clear mode label is added to make the metrics work when
-		// pushing metrics to a gravel gateway. To reconsider once
-		// https://github.com/sinkingpoint/prometheus-gravel-gateway/issues/28
-		// is solved
-		ClearModeLabel: ClearModeFamily,
-	}).(prometheus.ExemplarObserver).ObserveWithExemplar(time.Since(am.GetStartTime(ctx)).Seconds(), info)
+	defer releaseExemplars(info)
+
+	// Ignored calls are neither successes nor failures, only the concurrent calls are tracked for them.
+	if result != am.ResultIgnored {
+		callsCountSeries.Get(callsKey{SeriesKey: key, result: result, errorType: errorType}, newCallsCountSeries).
+			AddWithExemplar(1, info)
+		callsDurationSeries.Get(key, newCallsDurationSeries).
+			ObserveWithExemplar(time.Since(am.GetStartTime(ctx)).Seconds(), info)
+	}
 
 	if am.GetTrackConcurrentCalls(ctx) {
-		functionCallsConcurrent.With(prometheus.Labels{
-			FunctionLabel:       callInfo.FuncName,
-			ModuleLabel:         callInfo.ModuleName,
-			CallerFunctionLabel: callInfo.ParentFuncName,
-			CallerModuleLabel:   callInfo.ParentModuleName,
-			BranchLabel:         buildInfo.Branch,
-			CommitLabel:         buildInfo.Commit,
-			VersionLabel:        buildInfo.Version,
-			ServiceNameLabel:    buildInfo.Service,
-			// REVIEW: This clear mode label is added to make the metrics work when
-			// pushing metrics to a gravel gateway. To reconsider once
-			// https://github.com/sinkingpoint/prometheus-gravel-gateway/issues/28
-			// is solved
-			ClearModeLabel: ClearModeFamily,
-		}).Add(-1)
+		concurrentCalls(key).Dec()
 	}
 
 	if pusher != nil {
@@ -137,28 +116,11 @@ func PreInstrument(ctx context.Context) context.Context {
 		return nil
 	}
 
-	callInfo := am.CallerInfo()
-	ctx = am.SetCallInfo(ctx, callInfo)
-	ctx = am.FillBuildInfo(ctx)
-	ctx = am.FillTracingInfo(ctx)
-	buildInfo := am.GetBuildInfo(ctx)
+	callInfo := am.ResolveCallInfo(ctx)
+	ctx = am.PrepareCall(ctx, callInfo)
 
 	if am.GetTrackConcurrentCalls(ctx) {
-		functionCallsConcurrent.With(prometheus.Labels{
-			FunctionLabel:       callInfo.FuncName,
-			ModuleLabel:         callInfo.ModuleName,
-			CallerFunctionLabel: callInfo.ParentFuncName,
-			CallerModuleLabel:   callInfo.ParentModuleName,
-			BranchLabel:         buildInfo.Branch,
-			CommitLabel:         buildInfo.Commit,
-			VersionLabel:        buildInfo.Version,
-			ServiceNameLabel:    buildInfo.Service,
-			// REVIEW: This clear mode label is added to make the metrics work when
-			// pushing metrics to a gravel gateway. To reconsider once
-			// https://github.com/sinkingpoint/prometheus-gravel-gateway/issues/28
-			// is solved
-			ClearModeLabel: ClearModeFamily,
-		}).Add(1)
+		concurrentCalls(am.GetSeriesKey(ctx)).Inc()
 	}
 
 	if pusher != nil {
@@ -179,26 +141,74 @@ func PreInstrument(ctx context.Context) context.Context {
 		}(amCtx)
 	}
 
-	ctx = am.SetStartTime(ctx, time.Now())
-
 	return ctx
 }
 
+// exemplarLabels pools the label maps of the exemplars, as the metrics copy the labels of the exemplars
+// they keep.
+var exemplarLabels = sync.Pool{New: func() interface{} { return make(prometheus.Labels, 3) }}
+
 // Extract exemplars to add to metrics from the context
+//
+// No exemplars are returned for a trace that is not sampled, as it cannot be found
+// in the tracing backend.
+//
+// The returned labels must be released with [releaseExemplars] once the metrics are recorded. The IDs are
+// encoded in a single string, so that attaching the exemplars only allocates that string; the metrics still
+// allocate their copy of each exemplar.
 func exemplars(ctx context.Context) prometheus.Labels {
-	labels := make(prometheus.Labels)
+	if sampled, ok := am.GetTraceSampled(ctx); ok && !sampled {
+		return nil
+	}
 
-	if tid, ok := am.GetTraceID(ctx); ok {
-		labels[traceIdExemplar] = hex.EncodeToString(tid[:])
+	tid, hasTraceID := am.GetTraceID(ctx)
+	sid, hasSpanID := am.GetSpanID(ctx)
+	psid, hasParentSpanID := am.GetParentSpanID(ctx)
+	hasParentSpanID = hasParentSpanID && psid != (am.SpanID{})
+
+	// Without synthetic IDs, the calls without tracing information have no exemplar.
+	if !hasTraceID && !hasSpanID && !hasParentSpanID {
+		return nil
 	}
 
-	if sid, ok := am.GetSpanID(ctx); ok {
-		labels[spanIdExemplar] = hex.EncodeToString(sid[:])
+	var buffer [2 * (len(am.TraceID{}) + 2*len(am.SpanID{}))]byte
+	traceEnd := 0
+	if hasTraceID {
+		traceEnd = hex.Encode(buffer[:], tid[:])
 	}
+	spanEnd := traceEnd
+	if hasSpanID {
+		spanEnd += hex.Encode(buffer[traceEnd:], sid[:])
+	}
+	parentSpanEnd := spanEnd
+	if hasParentSpanID {
+		parentSpanEnd += hex.Encode(buffer[spanEnd:], psid[:])
+	}
+	ids := string(buffer[:parentSpanEnd])
 
-	if psid, ok := am.GetParentSpanID(ctx); ok {
-		labels[parentSpanIdExemplar] = hex.EncodeToString(psid[:])
+	labels := exemplarLabels.Get().(prometheus.Labels)
+
+	if hasTraceID {
+		labels[traceIdExemplar] = ids[:traceEnd]
+	}
+
+	if hasSpanID {
+		labels[spanIdExemplar] = ids[traceEnd:spanEnd]
+	}
+
+	if hasParentSpanID {
+		labels[parentSpanIdExemplar] = ids[spanEnd:parentSpanEnd]
 	}
 
 	return labels
 }
+
+// releaseExemplars returns the labels of [exemplars] to the pool.
+func releaseExemplars(labels prometheus.Labels) {
+	if labels == nil {
+		return
+	}
+
+	clear(labels)
+	exemplarLabels.Put(labels)
+}
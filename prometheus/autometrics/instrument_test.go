@@ -0,0 +1,352 @@
+package autometrics
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+
+	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type testError struct{}
+
+func (e *testError) Error() string { return "test error" }
+
+func panicking() (err error) {
+	defer Instrument(PreInstrument(NewContext(nil)), &err)
+
+	panic("boom")
+}
+
+func panickingCustomError() (err *testError) {
+	defer InstrumentCustomError(PreInstrument(NewContext(nil)), &err)
+
+	panic("boom")
+}
+
+var errValidation = errors.New("validation error")
+
+func failing(failure error, opts ...am.Option) (err error) {
+	defer Instrument(PreInstrument(NewContext(nil, opts...)), &err)
+
+	return failure
+}
+
+// callsCount returns the value of the calls counter of the function for the result.
+func callsCount(t *testing.T, reg *prometheus.Registry, function, result string) float64 {
+	return callsCountWithLabel(t, reg, function, result, ResultLabel, result)
+}
+
+// callsCountWithLabel returns the value of the calls counter of the function for the result
+// and the value of another label.
+func callsCountWithLabel(t *testing.T, reg *prometheus.Registry, function, result, labelName, labelValue string) float64 {
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("error gathering the metrics: %s", err)
+	}
+
+	var count float64
+	for _, family := range families {
+		if family.GetName() != FunctionCallsCountName {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels[FunctionLabel] == function && labels[ResultLabel] == result && labels[labelName] == labelValue {
+				count += metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return count
+}
+
+func TestInstrumentPanic(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	shutdown, err := Init(reg, DefBuckets, BuildInfo{}, nil)
+	if err != nil {
+		t.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	assert.PanicsWithValue(t, "boom", func() { _ = panicking() }, "The panic should be propagated.")
+	assert.PanicsWithValue(t, "boom", func() { _ = panickingCustomError() }, "The panic should be propagated.")
+
+	assert.Equal(t, float64(1), callsCount(t, reg, "panicking", "error"), "The panic should be recorded as an error.")
+	assert.Equal(t, float64(1), callsCount(t, reg, "panickingCustomError", "error"), "The panic should be recorded as an error.")
+	assert.Equal(t, float64(0), callsCount(t, reg, "panicking", "ok"))
+}
+
+func TestErrorClassifier(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	shutdown, err := Init(reg, DefBuckets, BuildInfo{}, nil, WithDefaultErrorClassifier(func(err error) Result {
+		if errors.Is(err, context.Canceled) {
+			return ResultIgnored
+		}
+		return ResultError
+	}))
+	if err != nil {
+		t.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	_ = failing(context.Canceled)
+	_ = failing(errValidation)
+	_ = failing(errValidation, WithErrorClassifier(func(err error) Result {
+		if errors.Is(err, errValidation) {
+			return ResultOk
+		}
+		return ResultError
+	}))
+	_ = failing(nil)
+
+	assert.Equal(t, float64(1), callsCount(t, reg, "failing", "error"), "Only the validation error without classifier should be an error.")
+	assert.Equal(t, float64(2), callsCount(t, reg, "failing", "ok"), "The validation error with a classifier should be a success.")
+	assert.Equal(t, float64(0), callsCount(t, reg, "failing", "ignored"), "The ignored calls should not be recorded.")
+}
+
+// outerClassified passes its instrumented context to the function it calls, like the midhttp handlers.
+func outerClassified(ctx context.Context, failure error) (err error) {
+	amCtx := PreInstrument(NewContext(ctx, WithErrorClassifier(func(error) Result { return ResultOk })))
+	defer Instrument(amCtx, &err)
+
+	_ = innerUnclassified(amCtx, failure)
+	return failure
+}
+
+func innerUnclassified(ctx context.Context, failure error) (err error) {
+	defer Instrument(PreInstrument(NewContext(ctx)), &err)
+
+	return failure
+}
+
+// TestErrorClassifierNotInherited makes sure that the error classifier of a function does not apply to the
+// instrumented functions it calls with its context.
+func TestErrorClassifierNotInherited(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	shutdown, err := Init(reg, DefBuckets, BuildInfo{}, nil)
+	if err != nil {
+		t.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	_ = outerClassified(context.Background(), errValidation)
+
+	assert.Equal(t, float64(1), callsCount(t, reg, "outerClassified", "ok"), "The classifier of the outer function should apply to it.")
+	assert.Equal(t, float64(1), callsCount(t, reg, "innerUnclassified", "error"), "The inner function should use the default classifier.")
+	assert.Equal(t, float64(0), callsCount(t, reg, "innerUnclassified", "ok"))
+}
+
+type testNotFoundError struct{}
+
+func (e testNotFoundError) Error() string { return "not found" }
+
+func TestErrorTypes(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	shutdown, err := Init(reg, DefBuckets, BuildInfo{}, nil, WithErrorTypes(
+		ErrorSentinel("validation", errValidation),
+		ErrorAs[testNotFoundError]("not_found"),
+	))
+	if err != nil {
+		t.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	_ = failing(fmt.Errorf("wrapped: %w", errValidation))
+	_ = failing(fmt.Errorf("wrapped: %w", testNotFoundError{}))
+	_ = failing(errors.New("unknown"))
+	_ = failing(nil)
+	assert.Panics(t, func() { _ = panicking() })
+
+	assert.Equal(t, float64(1), callsCountWithLabel(t, reg, "failing", "error", ErrorTypeLabel, "validation"))
+	assert.Equal(t, float64(1), callsCountWithLabel(t, reg, "failing", "error", ErrorTypeLabel, "not_found"))
+	assert.Equal(t, float64(1), callsCountWithLabel(t, reg, "failing", "error", ErrorTypeLabel, "other"))
+	assert.Equal(t, float64(1), callsCountWithLabel(t, reg, "failing", "ok", ErrorTypeLabel, ""))
+	assert.Equal(t, float64(1), callsCountWithLabel(t, reg, "panicking", "error", ErrorTypeLabel, "panic"))
+}
+
+func TestExemplarsFromSpanContext(t *testing.T) {
+	traceID := trace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	spanID := trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+
+	sampledCtx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	labels := exemplars(am.FillTracingInfo(sampledCtx))
+	assert.Equal(t, prometheus.Labels{
+		traceIdExemplar: traceID.String(),
+		spanIdExemplar:  spanID.String(),
+	}, labels, "The exemplars should use the IDs of the active span.")
+
+	unsampledCtx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	assert.Nil(t, exemplars(am.FillTracingInfo(unsampledCtx)), "No exemplars should be attached for unsampled spans.")
+
+	labels = exemplars(am.FillTracingInfo(context.Background()))
+	assert.Contains(t, labels, traceIdExemplar, "Random IDs should be used without an active span.")
+	assert.Contains(t, labels, spanIdExemplar, "Random IDs should be used without an active span.")
+}
+
+func instrumented(ctx context.Context) (err error) {
+	defer Instrument(PreInstrument(NewContext(
+		ctx,
+		WithFunctionName("instrumented"),
+		WithModuleName("github.com/autometrics-dev/autometrics-go/prometheus/autometrics"),
+		WithConcurrentCalls(true),
+		WithCallerName(true),
+		WithSloName("API"),
+		WithAlertSuccess(99.9),
+	)), &err)
+
+	return nil
+}
+
+func BenchmarkInstrument(b *testing.B) {
+	shutdown, err := Init(prometheus.NewRegistry(), DefBuckets, BuildInfo{}, nil)
+	if err != nil {
+		b.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = instrumented(ctx)
+	}
+}
+
+func BenchmarkInstrumentParallel(b *testing.B) {
+	shutdown, err := Init(prometheus.NewRegistry(), DefBuckets, BuildInfo{}, nil)
+	if err != nil {
+		b.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		for pb.Next() {
+			_ = instrumented(ctx)
+		}
+	})
+}
+
+// TestRecordingDoesNotAllocate makes sure that recording the calls of a function does not allocate
+// once its series are resolved, when no exemplar is attached: the metrics allocate a copy of each
+// exemplar (see [TestExemplarsAllocateOnce] for the default configuration).
+func TestRecordingDoesNotAllocate(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	shutdown, err := Init(reg, DefBuckets, BuildInfo{}, nil, WithSyntheticTraceIDs(false))
+	if err != nil {
+		t.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	ctx := PreInstrument(NewContext(nil, WithSloName("API"), WithAlertSuccess(99.9)))
+	instrument(ctx, nil)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		concurrentCalls(am.GetSeriesKey(ctx)).Inc()
+		instrument(ctx, nil)
+	})
+
+	assert.Zero(t, allocs)
+	// The first call, the warm-up run of AllocsPerRun and the 100 measured runs.
+	assert.Equal(t, float64(102), callsCount(t, reg, "TestRecordingDoesNotAllocate", "ok"))
+}
+
+// BenchmarkRecordCall measures the recording of the metrics of a call, once its series are resolved.
+func BenchmarkRecordCall(b *testing.B) {
+	shutdown, err := Init(prometheus.NewRegistry(), DefBuckets, BuildInfo{}, nil, WithSyntheticTraceIDs(false))
+	if err != nil {
+		b.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	ctx := PreInstrument(NewContext(nil, WithSloName("API"), WithAlertSuccess(99.9)))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		concurrentCalls(am.GetSeriesKey(ctx)).Inc()
+		instrument(ctx, nil)
+	}
+}
+
+// TestExemplarsAllocateOnce makes sure that the exemplars of a call with the default configuration, which
+// generates synthetic trace IDs, only allocate the string of the encoded IDs.
+func TestExemplarsAllocateOnce(t *testing.T) {
+	shutdown, err := Init(prometheus.NewRegistry(), DefBuckets, BuildInfo{}, nil)
+	if err != nil {
+		t.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	ctx := PreInstrument(NewContext(am.SetSpanID(context.Background(), am.SpanID{1, 2, 3, 4, 5, 6, 7, 8})))
+	releaseExemplars(exemplars(ctx))
+
+	allocs := testing.AllocsPerRun(100, func() {
+		releaseExemplars(exemplars(ctx))
+	})
+
+	assert.Equal(t, float64(1), allocs)
+
+	labels := exemplars(ctx)
+	defer releaseExemplars(labels)
+	traceID, _ := am.GetTraceID(ctx)
+	spanID, _ := am.GetSpanID(ctx)
+	assert.Equal(t, prometheus.Labels{
+		traceIdExemplar:      hex.EncodeToString(traceID[:]),
+		spanIdExemplar:       hex.EncodeToString(spanID[:]),
+		parentSpanIdExemplar: "0102030405060708",
+	}, labels)
+}
+
+// BenchmarkRecordCallWithExemplars measures the recording of the metrics of a call with the default
+// configuration, once its series are resolved. The synthetic trace IDs of the call are attached as
+// exemplars, which the metrics copy.
+func BenchmarkRecordCallWithExemplars(b *testing.B) {
+	shutdown, err := Init(prometheus.NewRegistry(), DefBuckets, BuildInfo{}, nil)
+	if err != nil {
+		b.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	ctx := PreInstrument(NewContext(nil, WithSloName("API"), WithAlertSuccess(99.9)))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		concurrentCalls(am.GetSeriesKey(ctx)).Inc()
+		instrument(ctx, nil)
+	}
+}
+
+func BenchmarkInstrumentWithoutExemplars(b *testing.B) {
+	shutdown, err := Init(prometheus.NewRegistry(), DefBuckets, BuildInfo{}, nil, WithSyntheticTraceIDs(false))
+	if err != nil {
+		b.Fatalf("error initializing autometrics: %s", err)
+	}
+	defer shutdown(nil)
+
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = instrumented(ctx)
+	}
+}
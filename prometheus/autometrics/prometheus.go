@@ -54,6 +54,10 @@ const (
 	CallerModuleLabel = "caller_module"
 	// ResultLabel is the prometheus label that describes whether a function call is successful.
 	ResultLabel = "result"
+	// ErrorTypeLabel is the prometheus label that describes the type of the error of a failed call.
+	//
+	// It is only added to the calls counter when enabled with [WithErrorTypes].
+	ErrorTypeLabel = "error_type"
 	// TargetLatencyLabel is the prometheus label that describes the latency to respect to match
 	// the Service Level Objective.
 	TargetLatencyLabel = "objective_latency_threshold"
@@ -97,6 +101,36 @@ const (
 // the current (prometheus) package imported at the call site.
 type BuildInfo = autometrics.BuildInfo
 
+// InitOption is an option of the process-wide configuration set in [Init].
+//
+// This is a reexport of the autometrics type to allow [Init] to work with only
+// the current (prometheus) package imported at the call site.
+type InitOption = autometrics.InitOption
+
+// Result is the outcome of a call of an instrumented function, decided by an [ErrorClassifier].
+//
+// This is a reexport of the autometrics type to allow classifiers to work with only
+// the current (prometheus) package imported.
+type Result = autometrics.Result
+
+const (
+	ResultOk      = autometrics.ResultOk
+	ResultError   = autometrics.ResultError
+	ResultIgnored = autometrics.ResultIgnored
+)
+
+// ErrorClassifier decides the [Result] of a call of an instrumented function from the non-nil error it returned.
+//
+// This is a reexport of the autometrics type to allow classifiers to work with only
+// the current (prometheus) package imported.
+type ErrorClassifier = autometrics.ErrorClassifier
+
+// ErrorType is a kind of error, recorded in the [ErrorTypeLabel] when enabled with [WithErrorTypes].
+//
+// This is a reexport of the autometrics type to allow [Init] to work with only
+// the current (prometheus) package imported at the call site.
+type ErrorType = autometrics.ErrorType
+
 // PushConfiguration holds meta information about the push-to-collector configuration of the instrumented code.
 //
 // This is a reexport of the autometrics type to allow [Init] to work with only
@@ -123,7 +157,10 @@ type PushConfiguration = autometrics.PushConfiguration
 // Make sure that all the latency targets you want to use for SLOs are
 // present in the histogramBuckets array, otherwise the alerts will fail
 // to work (they will never trigger.)
-func Init(reg *prometheus.Registry, histogramBuckets []float64, buildInformation BuildInfo, pushConfiguration *PushConfiguration) (context.CancelCauseFunc, error) {
+//
+// The initOpts set the process-wide configuration, like the default [ErrorClassifier]
+// with [WithDefaultErrorClassifier].
+func Init(reg *prometheus.Registry, histogramBuckets []float64, buildInformation BuildInfo, pushConfiguration *PushConfiguration, initOpts ...InitOption) (context.CancelCauseFunc, error) {
 	newCtx, cancelFunc := context.WithCancelCause(context.Background())
 	amCtx = newCtx
 
@@ -131,6 +168,13 @@ func Init(reg *prometheus.Registry, histogramBuckets []float64, buildInformation
 	autometrics.SetVersion(buildInformation.Version)
 	autometrics.SetBranch(buildInformation.Branch)
 
+	autometrics.SetDefaultErrorClassifier(nil)
+	autometrics.SetErrorTypes(nil, false)
+	autometrics.SetSyntheticTraceIDs(true)
+	for _, opt := range initOpts {
+		opt.ApplyInit()
+	}
+
 	pusher = nil
 	if pushConfiguration != nil {
 		log.Printf("autometrics: Init: detected push configuration to %s", pushConfiguration.CollectorURL)
@@ -160,9 +204,14 @@ func Init(reg *prometheus.Registry, histogramBuckets []float64, buildInformation
 		autometrics.SetService(buildInformation.Service)
 	}
 
+	callsCountLabels := []string{FunctionLabel, ModuleLabel, CallerFunctionLabel, CallerModuleLabel, ResultLabel, TargetSuccessRateLabel, SloNameLabel, CommitLabel, VersionLabel, BranchLabel, ServiceNameLabel, ClearModeLabel}
+	if _, enabled := autometrics.GetErrorTypes(); enabled {
+		callsCountLabels = append(callsCountLabels, ErrorTypeLabel)
+	}
+	resetSeries()
 	functionCallsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: FunctionCallsCountName,
-	}, []string{FunctionLabel, ModuleLabel, CallerFunctionLabel, CallerModuleLabel, ResultLabel, TargetSuccessRateLabel, SloNameLabel, CommitLabel, VersionLabel, BranchLabel, ServiceNameLabel, ClearModeLabel})
+	}, callsCountLabels)
 
 	functionCallsDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    FunctionCallsDurationName,
@@ -0,0 +1,122 @@
+package autometrics // import "github.com/autometrics-dev/autometrics-go/prometheus/autometrics"
+
+import (
+	"strconv"
+
+	am "github.com/autometrics-dev/autometrics-go/pkg/autometrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// callsKey identifies a series of the calls counter.
+type callsKey struct {
+	am.SeriesKey
+	result    am.Result
+	errorType string
+}
+
+// The series of the metrics are resolved once per function, caller and objective, so that recording
+// a call neither builds the labels nor looks up the vectors. The caches are reset in Init.
+var (
+	callsCountSeries      am.SeriesCache[callsKey, prometheus.ExemplarAdder]
+	callsDurationSeries   am.SeriesCache[am.SeriesKey, prometheus.ExemplarObserver]
+	callsConcurrentSeries am.SeriesCache[am.SeriesKey, prometheus.Gauge]
+)
+
+func resetSeries() {
+	callsCountSeries.Reset()
+	callsDurationSeries.Reset()
+	callsConcurrentSeries.Reset()
+}
+
+// concurrentCalls returns the series of the concurrent calls gauge of the function.
+func concurrentCalls(key am.SeriesKey) prometheus.Gauge {
+	// The objective is not a label of the gauge.
+	return callsConcurrentSeries.Get(am.SeriesKey{CallInfo: key.CallInfo, BuildInfo: key.BuildInfo}, newConcurrentCallsSeries)
+}
+
+func newCallsCountSeries(key callsKey) prometheus.ExemplarAdder {
+	sloName, _, _, successObjective := sloLabels(key.SeriesKey)
+
+	labels := prometheus.Labels{
+		FunctionLabel:          key.CallInfo.FuncName,
+		ModuleLabel:            key.CallInfo.ModuleName,
+		CallerFunctionLabel:    key.CallInfo.ParentFuncName,
+		CallerModuleLabel:      key.CallInfo.ParentModuleName,
+		ResultLabel:            key.result.String(),
+		TargetSuccessRateLabel: successObjective,
+		SloNameLabel:           sloName,
+		BranchLabel:            key.BuildInfo.Branch,
+		CommitLabel:            key.BuildInfo.Commit,
+		VersionLabel:           key.BuildInfo.Version,
+		ServiceNameLabel:       key.BuildInfo.Service,
+		// REVIEW: This clear mode label is added to make the metrics work when
+		// pushing metrics to a gravel gateway. To reconsider once
+		// https://github.com/sinkingpoint/prometheus-gravel-gateway/issues/28
+		// is solved
+		ClearModeLabel: ClearModeFamily,
+	}
+	if _, enabled := am.GetErrorTypes(); enabled {
+		labels[ErrorTypeLabel] = key.errorType
+	}
+
+	return functionCallsCount.With(labels).(prometheus.ExemplarAdder)
+}
+
+func newCallsDurationSeries(key am.SeriesKey) prometheus.ExemplarObserver {
+	sloName, latencyTarget, latencyObjective, _ := sloLabels(key)
+
+	return functionCallsDuration.With(prometheus.Labels{
+		FunctionLabel:          key.CallInfo.FuncName,
+		ModuleLabel:            key.CallInfo.ModuleName,
+		CallerFunctionLabel:    key.CallInfo.ParentFuncName,
+		CallerModuleLabel:      key.CallInfo.ParentModuleName,
+		TargetLatencyLabel:     latencyTarget,
+		TargetSuccessRateLabel: latencyObjective,
+		SloNameLabel:           sloName,
+		BranchLabel:            key.BuildInfo.Branch,
+		CommitLabel:            key.BuildInfo.Commit,
+		VersionLabel:           key.BuildInfo.Version,
+		ServiceNameLabel:       key.BuildInfo.Service,
+		// REVIEW: This clear mode label is added to make the metrics work when
+		// pushing metrics to a gravel gateway. To reconsider once
+		// https://github.com/sinkingpoint/prometheus-gravel-gateway/issues/28
+		// is solved
+		ClearModeLabel: ClearModeFamily,
+	}).(prometheus.ExemplarObserver)
+}
+
+func newConcurrentCallsSeries(key am.SeriesKey) prometheus.Gauge {
+	return functionCallsConcurrent.With(prometheus.Labels{
+		FunctionLabel:       key.CallInfo.FuncName,
+		ModuleLabel:         key.CallInfo.ModuleName,
+		CallerFunctionLabel: key.CallInfo.ParentFuncName,
+		CallerModuleLabel:   key.CallInfo.ParentModuleName,
+		BranchLabel:         key.BuildInfo.Branch,
+		CommitLabel:         key.BuildInfo.Commit,
+		VersionLabel:        key.BuildInfo.Version,
+		ServiceNameLabel:    key.BuildInfo.Service,
+		// REVIEW: This clear mode label is added to make the metrics work when
+		// pushing metrics to a gravel gateway. To reconsider once
+		// https://github.com/sinkingpoint/prometheus-gravel-gateway/issues/28
+		// is solved
+		ClearModeLabel: ClearModeFamily,
+	})
+}
+
+// sloLabels returns the values of the objective labels of the series.
+func sloLabels(key am.SeriesKey) (sloName, latencyTarget, latencyObjective, successObjective string) {
+	if key.SloName == "" {
+		return
+	}
+
+	sloName = key.SloName
+	if key.HasLatency {
+		latencyTarget = strconv.FormatFloat(key.LatencyTarget.Seconds(), 'f', -1, 64)
+		latencyObjective = strconv.FormatFloat(key.LatencyObjective, 'f', -1, 64)
+	}
+	if key.HasSuccess {
+		successObjective = strconv.FormatFloat(key.SuccessObjective, 'f', -1, 64)
+	}
+
+	return
+}